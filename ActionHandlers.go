@@ -0,0 +1,57 @@
+package crud
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+	"github.com/gorilla/mux"
+)
+
+// CreateCrudHandlerAction is used to perform a custom action on an entity. Route it as e.g. POST
+// /{resource}/{id}/actions/{name}. The request of the action is decoded from the body and validated, and the entity is
+// loaded using GetByID, before the action is performed. The result of the action is the reply.
+var CreateCrudHandlerAction = func(ctx servicefoundation.AppContext, svc Service, resourceName string, recoverFunc RecoverFunc, action *Action) http.HandlerFunc {
+	logger := ctx.Logger()
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer recoverFunc("crudHandler", ctx, w, r)
+
+		decode := startPhase(r, PhaseDecode, AttributeResource.String(resourceName), AttributeOperation.String(string(OperationAction)))
+		vars := mux.Vars(r)
+		idVar := vars["id"]
+
+		var request interface{}
+		if action.CreateRequest != nil {
+			request = action.CreateRequest()
+			if err := ReadEntityFromBody(r.Body, request); err != nil {
+				opResult := ValidationFailedResult(errors.New("Failed to parse request from HTTP body: " + err.Error()))
+				endPhase(decode, opResult.Error())
+				WriteOperationResult(w, r, opResult)
+				return
+			}
+			if v, ok := request.(validator); ok {
+				if err := v.Validate(); err != nil {
+					endPhase(decode, err)
+					WriteOperationResult(w, r, ValidationFailedResult(err))
+					return
+				}
+			}
+		}
+		endPhase(decode, nil)
+
+		loaded := traceService(r, resourceName, OperationGetByID, func() OperationResult {
+			return svc.GetByID(idVar)
+		}, keyAttribute(idVar))
+		if loaded.State() != Ok {
+			WriteOperationResult(w, r, loaded)
+			return
+		}
+
+		logger.Debug("CreateCrudHandlerAction", fmt.Sprintf("Interpreted as %v action. ID: %v Request: %v", action.Name, idVar, request))
+		opResult := traceService(r, resourceName, OperationAction, func() OperationResult {
+			return action.Perform(svc, idVar, loaded.Value(), request)
+		}, keyAttribute(idVar), AttributeAction.String(action.Name))
+		WriteOperationResult(w, r, opResult)
+	}
+}
@@ -0,0 +1,38 @@
+package crud
+
+import (
+	"errors"
+	"regexp"
+)
+
+// Action is a named operation on an entity that doesn't fit Add, Update or Delete, e.g. cancelling a booking. Actions
+// of a resource are routed as POST on the path of an entity followed by /actions/{name}.
+type Action struct {
+	// Name is the name of the action, which is also its path segment, e.g. "cancel".
+	Name string
+	// CreateRequest creates an empty request of the action, which the body is decoded into. If the request has a
+	// Validate() error method, it's validated as well. Optional; without it, the body is ignored and the request is nil.
+	CreateRequest func() interface{}
+	// Perform performs the action on the entity with the given key, as loaded using GetByID, returning the result to
+	// reply with. The Service is the one of the resource, bound to the parents for child resources, and runs the hooks of
+	// the resource around mutations.
+	Perform func(svc Service, id EntityKey, entity interface{}, request interface{}) OperationResult
+}
+
+var actionNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// Validate checks that the action has a name usable as a path segment, and can be performed.
+func (a *Action) Validate() error {
+	if !actionNamePattern.MatchString(a.Name) {
+		return errors.New("Invalid action name: " + a.Name)
+	}
+	if a.Perform == nil {
+		return errors.New("Action " + a.Name + " can't be performed")
+	}
+	return nil
+}
+
+// validator is implemented by requests of actions that can be validated
+type validator interface {
+	Validate() error
+}
@@ -0,0 +1,135 @@
+package crud_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type renameRequest struct {
+	Name string `json:"name"`
+}
+
+func (r *renameRequest) Validate() error {
+	if r.Name == "" {
+		return errors.New("A name is required")
+	}
+	return nil
+}
+
+var renameAction = &crud.Action{
+	Name:          "rename",
+	CreateRequest: func() interface{} { return &renameRequest{} },
+	Perform: func(svc crud.Service, id crud.EntityKey, entity interface{}, request interface{}) crud.OperationResult {
+		renamed := *entity.(*testEntity)
+		renamed.Name = request.(*renameRequest).Name
+		return svc.Update(id, &renamed)
+	},
+}
+
+var archiveAction = &crud.Action{
+	Name: "archive",
+	Perform: func(svc crud.Service, id crud.EntityKey, entity interface{}, request interface{}) crud.OperationResult {
+		archived := *entity.(*testEntity)
+		if archived.Status == "archived" {
+			return crud.ConflictResult(errors.New("Already archived"))
+		}
+		archived.Status = "archived"
+		svc.Update(id, &archived)
+		return crud.OkResult(map[string]string{"status": archived.Status})
+	},
+}
+
+func newActionRegistry() (*crud.Registry, *memoryService) {
+	things := newMemoryService()
+	things.Add(&testEntity{Name: "first"})
+	registry := crud.NewRegistry(newTestContext(), crud.Recovery)
+	registry.BasePath = "/api/"
+	registry.Register(&crud.Resource{
+		Name:       "things",
+		Service:    things,
+		CreateFunc: newTestEntity,
+		Actions:    []*crud.Action{renameAction, archiveAction, {Name: "../escape", Perform: archiveAction.Perform}},
+	})
+	return registry, things
+}
+
+func TestRegistry_RouteActions(t *testing.T) {
+	registry, things := newActionRegistry()
+	router := mux.NewRouter()
+	registry.Route(router)
+
+	w := serve(router, "POST", "/api/things/1/actions/rename", `{"name": "renamed"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id": 1, "name": "renamed", "status": ""}`, w.Body.String())
+	assert.Equal(t, "renamed", things.entities["1"].Name)
+
+	w = serve(router, "POST", "/api/things/1/actions/archive", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status": "archived"}`, w.Body.String())
+	w = serve(router, "POST", "/api/things/1/actions/archive", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"message": "Already archived"}`, w.Body.String())
+
+	// Requests are decoded and validated before the entity is loaded
+	w = serve(router, "POST", "/api/things/1/actions/rename", `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"message": "A name is required"}`, w.Body.String())
+	assert.Equal(t, http.StatusBadRequest, serve(router, "POST", "/api/things/42/actions/rename", `{"name":`).Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "POST", "/api/things/42/actions/rename", `{"name": "renamed"}`).Code)
+
+	assert.Equal(t, http.StatusNotFound, serve(router, "POST", "/api/things/1/actions/unknown", "").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(router, "GET", "/api/things/1/actions/archive", "").Code)
+}
+
+func TestRegistry_RouteActionsOfChild(t *testing.T) {
+	registry, bookings, _ := newNestedRegistry()
+	passengers, _ := registry.Resource("passengers")
+	passengers.Actions = []*crud.Action{renameAction}
+	router := mux.NewRouter()
+	registry.Route(router)
+
+	bookings.Add(&testEntity{Name: "booking"})
+	serve(router, "POST", "/api/bookings/1/passengers", `{"name": "passenger"}`)
+
+	w := serve(router, "POST", "/api/bookings/1/passengers/1/actions/rename", `{"name": "renamed"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id": 1, "name": "renamed", "status": ""}`, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, serve(router, "POST", "/api/bookings/2/passengers/1/actions/rename", `{"name": "renamed"}`).Code)
+}
+
+func TestResource_CapabilitiesWithActions(t *testing.T) {
+	registry, _ := newActionRegistry()
+	resource, _ := registry.Resource("things")
+
+	assert.Equal(t, []string{"rename", "archive"}, resource.Capabilities("/api/things").Actions)
+
+	document := crud.GenerateOpenAPI(registry, crud.OpenAPIInfo{Title: "Test", Version: "1.0"})
+	paths := document["paths"].(map[string]interface{})
+	assert.Contains(t, paths, "/api/things/{id}/actions/rename")
+	assert.Contains(t, paths, "/api/things/{id}/actions/archive")
+	assert.NotContains(t, paths, "/api/things/{id}/actions/../escape")
+	schemas := document["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	assert.Contains(t, schemas, "ThingsRenameRequest")
+}
+
+func TestRegistry_RouteActionsRunHooks(t *testing.T) {
+	registry, things := newActionRegistry()
+	resource, _ := registry.Resource("things")
+	resource.Hooks = &crud.Hooks{
+		BeforeUpdate: func(id crud.EntityKey, old, entity crud.Entity) crud.OperationResult {
+			return crud.ConflictResult(errors.New("Things can't be renamed"))
+		},
+	}
+	router := mux.NewRouter()
+	registry.Route(router)
+
+	w := serve(router, "POST", "/api/things/1/actions/rename", `{"name": "renamed"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"message": "Things can't be renamed"}`, w.Body.String())
+	assert.Equal(t, "first", things.entities["1"].Name)
+}
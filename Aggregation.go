@@ -0,0 +1,337 @@
+package crud
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AggregateFunction is the function used to calculate a measure
+type AggregateFunction string
+
+const (
+	// AggregateCount counts the entities in a group, or the entities with a value for the column, if one is given
+	AggregateCount AggregateFunction = "count"
+	// AggregateSum adds up the numeric values of a column
+	AggregateSum AggregateFunction = "sum"
+	// AggregateMin returns the lowest value of a column
+	AggregateMin AggregateFunction = "min"
+	// AggregateMax returns the highest value of a column
+	AggregateMax AggregateFunction = "max"
+	// AggregateAvg returns the average of the numeric values of a column
+	AggregateAvg AggregateFunction = "avg"
+)
+
+// dateGroupings truncate timestamps for grouping, e.g. day(createdAt) groups by the date of createdAt.
+var dateGroupings = map[string]string{
+	"year":  "2006",
+	"month": "2006-01",
+	"day":   "2006-01-02",
+	"hour":  "2006-01-02T15",
+}
+
+// Measure is a value calculated for every group of an aggregation
+type Measure struct {
+	// Function is the aggregate function.
+	Function AggregateFunction `json:"function"`
+	// Column is the column the function is applied to. Optional for count.
+	Column string `json:"column"`
+}
+
+// Name is the name of the measure in the rows of the result, e.g. "sum(amount)", or "count" for counting entities.
+func (m Measure) Name() string {
+	if m.Column == "" {
+		return string(m.Function)
+	}
+	return string(m.Function) + "(" + m.Column + ")"
+}
+
+// AggregationRequest describes the groups and measures to calculate over a set of entities
+type AggregationRequest struct {
+	// GroupBy are the columns to group the entities by. Timestamp columns can be grouped by a part of their value as
+	// year(column), month(column), day(column) or hour(column). Without any, all entities form a single group.
+	GroupBy []string `json:"groupBy"`
+	// Measures are the values to calculate for every group.
+	Measures []Measure `json:"measures"`
+	// Filters select the entities to aggregate, like the filters of a DataSetRequest.
+	Filters map[string]string `json:"filters"`
+}
+
+// AggregationResult holds the rows of an aggregation: one per group, ordered by the grouped columns
+type AggregationResult struct {
+	// Rows hold the values of the grouped columns and the measures, by their names.
+	Rows []map[string]interface{} `json:"rows"`
+}
+
+// Aggregator can be implemented by a Service to support aggregation requests
+type Aggregator interface {
+	// Aggregate calculates the measures of the request for every group. The value of the result is an *AggregationResult.
+	Aggregate(request *AggregationRequest) OperationResult
+}
+
+// Validate checks the request for unknown functions and malformed group-by columns
+func (r *AggregationRequest) Validate() error {
+	if len(r.Measures) == 0 {
+		return errors.New("At least one measure is required")
+	}
+	for _, m := range r.Measures {
+		switch m.Function {
+		case AggregateCount:
+		case AggregateSum, AggregateMin, AggregateMax, AggregateAvg:
+			if m.Column == "" {
+				return fmt.Errorf("Measure %v needs a column", m.Function)
+			}
+		default:
+			return fmt.Errorf("Unknown aggregate function: %v", m.Function)
+		}
+	}
+	for _, g := range r.GroupBy {
+		if _, _, err := parseGroupBy(g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MemoryAggregator implements Aggregator for any Service, by getting all entities matching the filters page by page and
+// aggregating them in memory. It's meant for small data sets; services backed by a database should aggregate there.
+type MemoryAggregator struct {
+	Service
+	// PageSize is the number of entities requested at once. Defaults to 500.
+	PageSize int
+}
+
+// NewMemoryAggregator adds in-memory aggregation to a Service.
+func NewMemoryAggregator(svc Service) *MemoryAggregator {
+	return &MemoryAggregator{Service: svc, PageSize: 500}
+}
+
+// Aggregate gets all entities matching the filters, and aggregates them
+func (a *MemoryAggregator) Aggregate(request *AggregationRequest) OperationResult {
+	if err := request.Validate(); err != nil {
+		return ValidationFailedResult(err)
+	}
+
+	items := make([]interface{}, 0)
+	for pageNumber := 1; ; pageNumber++ {
+		result := a.Service.GetAll(&DataSetRequest{
+			PageSize:      a.PageSize,
+			PageNumber:    pageNumber,
+			SortColumn:    "id",
+			SortDirection: string(Asc),
+			Filters:       request.Filters,
+		})
+		if result.State() != Ok {
+			return result
+		}
+		dataSet, ok := result.Value().(*DataSet)
+		if !ok || dataSet == nil {
+			return ErrorResult(fmt.Errorf("Unexpected result of GetAll: %T", result.Value()))
+		}
+		items = append(items, dataSet.Items...)
+		// The Service may cap the page size, so the end is detected by the size of the page it reports
+		pageSize := dataSet.PagingInfo.PageSize
+		if pageSize <= 0 || pageSize > a.PageSize {
+			pageSize = a.PageSize
+		}
+		if !dataSet.PagingInfo.SupportsPaging || len(dataSet.Items) == 0 || len(dataSet.Items) < pageSize ||
+			(dataSet.PagingInfo.DoesKnowTotalRecords && len(items) >= dataSet.PagingInfo.TotalRecordsCount) {
+			break
+		}
+	}
+
+	// The Service already applied the filters
+	unfiltered := *request
+	unfiltered.Filters = nil
+	aggregation, err := AggregateItems(items, &unfiltered)
+	if err != nil {
+		return ValidationFailedResult(err)
+	}
+	return OkResult(aggregation)
+}
+
+// EntityPipeline returns the pipeline of the wrapped Service
+func (a *MemoryAggregator) EntityPipeline() *EntityPipeline {
+	return pipelineFor(a.Service)
+}
+
+// ResourcePolicy returns the policy of the wrapped Service
+func (a *MemoryAggregator) ResourcePolicy() *ResourcePolicy {
+	return policyFor(a.Service)
+}
+
+// AggregateItems filters, groups and aggregates a collection of entities held in memory. Columns are addressed by their
+// JSON names.
+func AggregateItems(items []interface{}, request *AggregationRequest) (*AggregationResult, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	type group struct {
+		keys   []interface{}
+		values [][]interface{}
+		count  int
+	}
+	groups := make(map[string]*group)
+	order := make([]*group, 0)
+
+	for _, item := range items {
+		fields := entityFields(item)
+		if fields == nil || !matchesFilters(fields, request.Filters) {
+			continue
+		}
+
+		keys := make([]interface{}, len(request.GroupBy))
+		for i, g := range request.GroupBy {
+			keys[i] = groupValue(fields, g)
+		}
+		id := fmt.Sprintf("%#v", keys)
+		grp, ok := groups[id]
+		if !ok {
+			grp = &group{keys: keys, values: make([][]interface{}, len(request.Measures))}
+			groups[id] = grp
+			order = append(order, grp)
+		}
+		grp.count++
+		for i, m := range request.Measures {
+			if m.Column == "" {
+				continue
+			}
+			if v, ok := fieldValue(fields, m.Column); ok && v != nil {
+				grp.values[i] = append(grp.values[i], v)
+			}
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		for k := range request.GroupBy {
+			if c := compareValues(order[i].keys[k], order[j].keys[k]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	result := &AggregationResult{Rows: make([]map[string]interface{}, 0, len(order))}
+	for _, grp := range order {
+		row := make(map[string]interface{}, len(request.GroupBy)+len(request.Measures))
+		for i, g := range request.GroupBy {
+			row[g] = grp.keys[i]
+		}
+		for i, m := range request.Measures {
+			value, err := measure(m, grp.count, grp.values[i])
+			if err != nil {
+				return nil, err
+			}
+			row[m.Name()] = value
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, nil
+}
+
+// parseGroupBy splits a group-by column into the column and the layout its timestamps are truncated to, if any.
+func parseGroupBy(groupBy string) (string, string, error) {
+	open := strings.Index(groupBy, "(")
+	if open < 0 {
+		return groupBy, "", nil
+	}
+	layout, ok := dateGroupings[strings.ToLower(groupBy[:open])]
+	if !ok || !strings.HasSuffix(groupBy, ")") || open+2 > len(groupBy)-1 {
+		return "", "", errors.New("Invalid group-by column: " + groupBy)
+	}
+	return groupBy[open+1 : len(groupBy)-1], layout, nil
+}
+
+// groupValue returns the value of a flattened entity to group on.
+func groupValue(fields map[string]interface{}, groupBy string) interface{} {
+	column, layout, _ := parseGroupBy(groupBy)
+	value, _ := fieldValue(fields, column)
+	if layout == "" || value == nil {
+		return value
+	}
+	timestamp, err := time.Parse(time.RFC3339, fmt.Sprint(value))
+	if err != nil {
+		return nil
+	}
+	return timestamp.Format(layout)
+}
+
+// measure applies an aggregate function to the values of a group.
+func measure(m Measure, count int, values []interface{}) (interface{}, error) {
+	switch m.Function {
+	case AggregateCount:
+		if m.Column == "" {
+			return count, nil
+		}
+		return len(values), nil
+	case AggregateMin, AggregateMax:
+		var result interface{}
+		for _, v := range values {
+			c := compareValues(v, result)
+			if vf, ok := toFloat(v); ok {
+				if rf, ok := toFloat(result); ok {
+					c = compareValues(vf, rf)
+				}
+			}
+			if result == nil || (m.Function == AggregateMin && c < 0) || (m.Function == AggregateMax && c > 0) {
+				result = v
+			}
+		}
+		return result, nil
+	}
+
+	sum := 0.0
+	for _, v := range values {
+		f, err := numericValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("Can't calculate %v: %v", m.Name(), err)
+		}
+		sum += f
+	}
+	if m.Function == AggregateAvg {
+		if len(values) == 0 {
+			return nil, nil
+		}
+		return sum / float64(len(values)), nil
+	}
+	return sum, nil
+}
+
+// numericValue converts a value to a number for sums and averages. Numeric strings are accepted as well.
+func numericValue(v interface{}) (float64, error) {
+	if f, ok := toFloat(v); ok {
+		return f, nil
+	}
+	if s, ok := v.(string); ok {
+		return strconv.ParseFloat(s, 64)
+	}
+	return 0, fmt.Errorf("%v is not a number", v)
+}
+
+// toFloat converts numbers of any type to float64. Entities flattened from JSON only hold float64, but entities that
+// are maps already can hold any type.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
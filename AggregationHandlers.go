@@ -0,0 +1,137 @@
+package crud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+)
+
+// CreateCrudHandlerAggregate is used to aggregate the entities of a resource implementing Aggregator. Route it as e.g.
+// /{resource}/_aggregate. See ExtractAggregationRequestFromURI for the URI parameters. The policy of the Service is
+// applied, if it declares one (see ResourcePolicyProvider).
+var CreateCrudHandlerAggregate = func(ctx servicefoundation.AppContext, svc Service, resourceName string, recoverFunc RecoverFunc) http.HandlerFunc {
+	return CreateCrudHandlerAggregateWithPolicy(ctx, svc, resourceName, recoverFunc, policyFor(svc))
+}
+
+// CreateCrudHandlerAggregateWithPolicy is used to aggregate the entities of a resource implementing Aggregator, applying
+// the given policy: only the columns that can be filtered on can be grouped by and measured, and filters on other
+// columns are removed. The policy can be nil.
+var CreateCrudHandlerAggregateWithPolicy = func(ctx servicefoundation.AppContext, svc Service, resourceName string, recoverFunc RecoverFunc, policy *ResourcePolicy) http.HandlerFunc {
+	logger := ctx.Logger()
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer recoverFunc("crudHandler", ctx, w, r)
+
+		aggregator, ok := svc.(Aggregator)
+		if !ok || !implements(svc, aggregatorInterface) {
+			WriteOperationResult(w, r, NotSupportedByResourceResult())
+			return
+		}
+
+		request, err := ExtractAggregationRequestFromURI(r)
+		if err != nil {
+			WriteOperationResult(w, r, ValidationFailedResult(err))
+			return
+		}
+		if policy != nil && policy.FilterColumns != nil {
+			if err := checkAggregationColumns(request, policy.FilterColumns); err != nil {
+				WriteOperationResult(w, r, ValidationFailedResult(err))
+				return
+			}
+			filtered := &DataSetRequest{Filters: request.Filters}
+			ConstrainFilterColumns(filtered, policy.FilterColumns...)
+			request.Filters = filtered.Filters
+		}
+
+		logger.Debug("CreateCrudHandlerAggregate", fmt.Sprintf("Interpreted as Aggregate command. Arguments: %v", request))
+		opResult := traceService(r, resourceName, OperationAggregate, func() OperationResult {
+			return aggregator.Aggregate(request)
+		})
+		WriteOperationResult(w, r, opResult)
+	}
+}
+
+// checkAggregationColumns checks that the columns grouped by and measured are among the given columns.
+func checkAggregationColumns(request *AggregationRequest, columns []string) error {
+	for _, g := range request.GroupBy {
+		if column, _, _ := parseGroupBy(g); !containsString(columns, column) {
+			return errors.New("Can't group by column " + column)
+		}
+	}
+	for _, m := range request.Measures {
+		if m.Column != "" && !containsString(columns, m.Column) {
+			return errors.New("Can't aggregate column " + m.Column)
+		}
+	}
+	return nil
+}
+
+// ExtractAggregationRequestFromURI is a helper function to parse URI parameters into an aggregation request:
+//
+//	groupBy   comma-separated columns, e.g. status,day(createdAt)
+//	measures  comma-separated functions, e.g. count,sum(amount),avg(amount)
+//	filters   JSON object of column names and values, as for list requests
+func ExtractAggregationRequestFromURI(r *http.Request) (*AggregationRequest, error) {
+	request := &AggregationRequest{
+		GroupBy:  make([]string, 0),
+		Measures: make([]Measure, 0),
+	}
+
+	for k, v := range r.URL.Query() {
+		switch strings.ToLower(k) {
+		case "groupby":
+			request.GroupBy = append(request.GroupBy, splitList(v[0])...)
+		case "measures":
+			for _, m := range splitList(v[0]) {
+				measure, err := parseMeasure(m)
+				if err != nil {
+					return nil, err
+				}
+				request.Measures = append(request.Measures, measure)
+			}
+		case "filters":
+			filterKvs := make(map[string]string)
+			if err := json.Unmarshal([]byte(v[0]), &filterKvs); err != nil {
+				return nil, errors.New("Invalid filters, expected a JSON object: " + v[0])
+			}
+			request.Filters = filterKvs
+		}
+	}
+
+	if len(request.Measures) == 0 {
+		request.Measures = append(request.Measures, Measure{Function: AggregateCount})
+	}
+	return request, request.Validate()
+}
+
+// parseMeasure parses a measure like sum(amount). A count of entities can be written as count or count(*).
+func parseMeasure(m string) (Measure, error) {
+	open := strings.Index(m, "(")
+	if open < 0 {
+		return Measure{Function: AggregateFunction(strings.ToLower(m))}, nil
+	}
+	if !strings.HasSuffix(m, ")") {
+		return Measure{}, errors.New("Invalid measure: " + m)
+	}
+	measure := Measure{
+		Function: AggregateFunction(strings.ToLower(m[:open])),
+		Column:   strings.TrimSpace(m[open+1 : len(m)-1]),
+	}
+	if measure.Function == AggregateCount && measure.Column == "*" {
+		measure.Column = ""
+	}
+	return measure, nil
+}
+
+func splitList(list string) []string {
+	result := make([]string, 0)
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,165 @@
+package crud_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+var payments = []interface{}{
+	map[string]interface{}{"id": 1, "status": "paid", "amount": 10.5, "createdAt": "2017-03-01T10:00:00Z"},
+	map[string]interface{}{"id": 2, "status": "paid", "amount": 4.5, "createdAt": "2017-03-01T18:00:00Z"},
+	map[string]interface{}{"id": 3, "status": "open", "amount": 20, "createdAt": "2017-03-02T09:00:00Z"},
+	map[string]interface{}{"id": 4, "status": "paid", "amount": 5, "createdAt": "2017-03-02T11:00:00Z"},
+	map[string]interface{}{"id": 5, "status": "open", "createdAt": "2017-03-03T11:00:00Z"},
+}
+
+func TestAggregateItems_GroupBy(t *testing.T) {
+	result, err := crud.AggregateItems(payments, &crud.AggregationRequest{
+		GroupBy: []string{"status"},
+		Measures: []crud.Measure{
+			{Function: crud.AggregateCount},
+			{Function: crud.AggregateCount, Column: "amount"},
+			{Function: crud.AggregateSum, Column: "amount"},
+			{Function: crud.AggregateMin, Column: "amount"},
+			{Function: crud.AggregateMax, Column: "amount"},
+			{Function: crud.AggregateAvg, Column: "amount"},
+		},
+	})
+
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []map[string]interface{}{
+		{"status": "open", "count": 2, "count(amount)": 1, "sum(amount)": 20.0, "min(amount)": 20, "max(amount)": 20, "avg(amount)": 20.0},
+		{"status": "paid", "count": 3, "count(amount)": 3, "sum(amount)": 20.0, "min(amount)": 4.5, "max(amount)": 10.5, "avg(amount)": 20.0 / 3},
+	}, result.Rows)
+}
+
+func TestAggregateItems_GroupByDay(t *testing.T) {
+	result, err := crud.AggregateItems(payments, &crud.AggregationRequest{
+		GroupBy:  []string{"day(createdAt)"},
+		Measures: []crud.Measure{{Function: crud.AggregateSum, Column: "amount"}},
+		Filters:  map[string]string{"status": "paid"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]interface{}{
+		{"day(createdAt)": "2017-03-01", "sum(amount)": 15.0},
+		{"day(createdAt)": "2017-03-02", "sum(amount)": 5.0},
+	}, result.Rows)
+}
+
+func TestAggregateItems_WithoutGroups(t *testing.T) {
+	result, err := crud.AggregateItems(payments, &crud.AggregationRequest{Measures: []crud.Measure{{Function: crud.AggregateCount}}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]interface{}{{"count": 5}}, result.Rows)
+}
+
+func TestAggregateItems_InvalidRequest(t *testing.T) {
+	_, err := crud.AggregateItems(payments, &crud.AggregationRequest{Measures: []crud.Measure{{Function: "median", Column: "amount"}}})
+	assert.NotNil(t, err)
+
+	_, err = crud.AggregateItems(payments, &crud.AggregationRequest{Measures: []crud.Measure{{Function: crud.AggregateSum}}})
+	assert.NotNil(t, err)
+
+	_, err = crud.AggregateItems(payments, &crud.AggregationRequest{GroupBy: []string{"week(createdAt)"}, Measures: []crud.Measure{{Function: crud.AggregateCount}}})
+	assert.NotNil(t, err)
+
+	_, err = crud.AggregateItems(payments, &crud.AggregationRequest{Measures: []crud.Measure{{Function: crud.AggregateSum, Column: "status"}}})
+	assert.NotNil(t, err)
+}
+
+func TestExtractAggregationRequestFromURI(t *testing.T) {
+	r, _ := http.NewRequest("GET", `/payments/_aggregate?groupBy=status,day(createdAt)&measures=count(*),sum(amount)&filters={"status":"paid"}`, nil)
+
+	request, err := crud.ExtractAggregationRequestFromURI(r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"status", "day(createdAt)"}, request.GroupBy)
+	assert.Equal(t, []crud.Measure{{Function: crud.AggregateCount}, {Function: crud.AggregateSum, Column: "amount"}}, request.Measures)
+	assert.Equal(t, map[string]string{"status": "paid"}, request.Filters)
+}
+
+func TestRegistry_RouteAggregate(t *testing.T) {
+	svc := newMemoryService()
+	svc.Add(&testEntity{Name: "first", Status: "new"})
+	svc.Add(&testEntity{Name: "second", Status: "active"})
+	svc.Add(&testEntity{Name: "third", Status: "new"})
+	registry := newTestRegistry()
+	registry.Register(&crud.Resource{Name: "things", Service: crud.NewMemoryAggregator(svc), CreateFunc: newTestEntity})
+	router := mux.NewRouter()
+	registry.Route(router)
+
+	w := serve(router, "GET", "/api/things/_aggregate?groupBy=status", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var result crud.AggregationResult
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, []map[string]interface{}{
+		{"status": "active", "count": float64(1)},
+		{"status": "new", "count": float64(2)},
+	}, result.Rows)
+
+	w = serve(router, "GET", "/api/things/_aggregate?measures=median(id)", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Resources without an Aggregator don't get the route
+	w = serve(router, "GET", "/api/lookups/_aggregate", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	resource, _ := registry.Resource("things")
+	assert.Contains(t, resource.Capabilities("").Operations, crud.OperationAggregate)
+}
+
+func TestRegistry_RouteAggregateWithPolicy(t *testing.T) {
+	registry := newTestRegistry()
+	registry.Register(&crud.Resource{
+		Name:       "things",
+		Service:    crud.NewMemoryAggregator(newStatusService()),
+		CreateFunc: newTestEntity,
+		Policy:     &crud.ResourcePolicy{FilterColumns: []string{"status", "id"}},
+	})
+	router := mux.NewRouter()
+	registry.Route(router)
+
+	w := serve(router, "GET", "/api/things/_aggregate?groupBy=status&measures=count,max(id)", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	for _, query := range []string{"groupBy=name", "groupBy=day(name)", "measures=max(name)"} {
+		w = serve(router, "GET", "/api/things/_aggregate?"+query, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Contains(t, w.Body.String(), "name", query)
+	}
+}
+
+// cappingService pages the payments, capping the page size at 2
+type cappingService struct {
+	crud.Service
+}
+
+func (s *cappingService) GetAll(request *crud.DataSetRequest) crud.OperationResult {
+	pageSize := request.PageSize
+	if pageSize > 2 {
+		pageSize = 2
+	}
+	items := make([]interface{}, 0, pageSize)
+	for i := (request.PageNumber - 1) * pageSize; i < len(payments) && len(items) < pageSize; i++ {
+		items = append(items, payments[i])
+	}
+	return crud.OkResult(&crud.DataSet{Items: items, PagingInfo: crud.PagingInfo{SupportsPaging: true, PageSize: pageSize, PageNumber: request.PageNumber}})
+}
+
+func TestMemoryAggregator_PageSizeCappedByService(t *testing.T) {
+	aggregator := crud.NewMemoryAggregator(&cappingService{})
+
+	result := aggregator.Aggregate(&crud.AggregationRequest{Measures: []crud.Measure{{Function: crud.AggregateCount}}})
+
+	assert.Equal(t, crud.Ok, result.State())
+	assert.Equal(t, []map[string]interface{}{{"count": 5}}, result.Value().(*crud.AggregationResult).Rows)
+}
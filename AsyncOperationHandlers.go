@@ -0,0 +1,51 @@
+package crud
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+	"github.com/gorilla/mux"
+)
+
+// MaxAsyncOperationWait is the longest a client can wait for an operation to complete in a single status request.
+var MaxAsyncOperationWait = 60 * time.Second
+
+// CreateCrudHandlerGetAsyncOperation is used to get the status of an asynchronous operation. Route it under the base path
+// of the tracker, as e.g. /operations/{id}. Clients can wait for the operation to complete by passing the number of
+// seconds to wait as the 'wait' query parameter; the status is returned as soon as the operation completes, or the client
+// goes away.
+var CreateCrudHandlerGetAsyncOperation = func(ctx servicefoundation.AppContext, tracker *AsyncOperationTracker, recoverFunc RecoverFunc) http.HandlerFunc {
+	logger := ctx.Logger()
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer recoverFunc("crudHandler", ctx, w, r)
+
+		vars := mux.Vars(r)
+		idVar := vars["id"]
+
+		var wait time.Duration
+		if waitVar := r.URL.Query().Get("wait"); waitVar != "" {
+			seconds, err := strconv.Atoi(waitVar)
+			if err != nil || seconds < 0 {
+				WriteOperationResult(w, r, ValidationFailedResult(errors.New("Invalid number of seconds to wait: "+waitVar)))
+				return
+			}
+			// Clamped in seconds first, so large numbers don't overflow
+			if maxSeconds := int(MaxAsyncOperationWait / time.Second); seconds > maxSeconds {
+				seconds = maxSeconds
+			}
+			wait = time.Duration(seconds) * time.Second
+		}
+
+		logger.Debug("CreateCrudHandlerGetAsyncOperation", fmt.Sprintf("Interpreted as GetAsyncOperation command. ID: %v Wait: %v", idVar, wait))
+		operation, ok := tracker.WaitContext(r.Context(), idVar, wait)
+		if !ok {
+			WriteOperationResult(w, r, NotFoundResult())
+			return
+		}
+		WriteOperationResult(w, r, OkResult(operation))
+	}
+}
@@ -0,0 +1,247 @@
+package crud
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AsyncOperationStatus describes whether an asynchronous operation has completed
+type AsyncOperationStatus string
+
+const (
+	// AsyncOperationRunning means the work is still in progress
+	AsyncOperationRunning AsyncOperationStatus = "running"
+	// AsyncOperationCompleted means the work is done; the result tells whether it succeeded
+	AsyncOperationCompleted AsyncOperationStatus = "completed"
+)
+
+// AsyncOperationResult is the final result of an asynchronous operation, in a form that can be sent to clients
+type AsyncOperationResult struct {
+	// State is the state of the result.
+	State State `json:"state"`
+	// StatusCode is the HTTP status code the result would have had, had the operation been synchronous.
+	StatusCode int `json:"statusCode"`
+	// Value is the value of the result, if any.
+	Value interface{} `json:"value,omitempty"`
+	// Error describes the error of the result, if any.
+	Error *ErrorDetails `json:"error,omitempty"`
+}
+
+// OperationResult turns the result back into an OperationResult.
+func (r *AsyncOperationResult) OperationResult() OperationResult {
+	result := &crudOperationResult{
+		state: r.State,
+		value: r.Value,
+	}
+	if r.Error != nil {
+		result.error = errors.New(r.Error.Message)
+	}
+	return result
+}
+
+// AsyncOperation tracks work that continues after the request that started it was answered with Accepted
+type AsyncOperation struct {
+	// ID identifies the operation.
+	ID string `json:"id"`
+	// Resource is the name of the resource the operation was started on.
+	Resource string `json:"resource"`
+	// Status tells whether the operation has completed.
+	Status AsyncOperationStatus `json:"status"`
+	// Location is the path where the status of the operation can be requested.
+	Location string `json:"location"`
+	// StartedAt is the moment the operation was started.
+	StartedAt time.Time `json:"startedAt"`
+	// CompletedAt is the moment the operation completed, if it has.
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	// Result is the final result, once completed.
+	Result *AsyncOperationResult `json:"result,omitempty"`
+
+	done <-chan struct{}
+}
+
+// Done returns a channel that's closed when the operation completes. It's nil for operations that aren't tracked by an
+// AsyncOperationTracker.
+func (o *AsyncOperation) Done() <-chan struct{} {
+	return o.done
+}
+
+// AsyncOperationTracker keeps track of asynchronous operations, so that clients can request their status. Completed
+// operations are kept for the retention period.
+//
+// Services start operations from Add, Update or Delete, and return the Accepted result to the handler:
+//
+//	func (s *BookingService) Add(entity crud.Entity) crud.OperationResult {
+//		return s.tracker.Start("bookings", func() crud.OperationResult {
+//			return s.slowAdd(entity)
+//		})
+//	}
+type AsyncOperationTracker struct {
+	// Now returns the current time. Replaceable for testing purposes; defaults to time.Now.
+	Now func() time.Time
+	// BasePath is the path the status of operations is routed under; the location of an operation is the base path
+	// followed by its ID.
+	BasePath string
+	// Retention is how long completed operations are kept.
+	Retention time.Duration
+
+	mutex      sync.Mutex
+	operations map[string]*trackedOperation
+}
+
+type trackedOperation struct {
+	operation AsyncOperation
+	done      chan struct{}
+}
+
+// NewAsyncOperationTracker creates a tracker for operations routed under basePath, e.g. "/operations", keeping completed
+// operations for the given duration.
+func NewAsyncOperationTracker(basePath string, retention time.Duration) *AsyncOperationTracker {
+	return &AsyncOperationTracker{
+		Now:        time.Now,
+		BasePath:   basePath,
+		Retention:  retention,
+		operations: make(map[string]*trackedOperation),
+	}
+}
+
+// Track registers a new running operation. The returned function completes it with the final result; calling it more
+// than once has no effect.
+func (t *AsyncOperationTracker) Track(resource string) (*AsyncOperation, func(OperationResult)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.deleteExpired()
+	id := newAsyncOperationID()
+	tracked := &trackedOperation{
+		operation: AsyncOperation{
+			ID:        id,
+			Resource:  resource,
+			Status:    AsyncOperationRunning,
+			Location:  t.BasePath + "/" + id,
+			StartedAt: t.Now(),
+		},
+		done: make(chan struct{}),
+	}
+	tracked.operation.done = tracked.done
+	t.operations[id] = tracked
+
+	snapshot := tracked.operation
+	return &snapshot, func(result OperationResult) {
+		t.complete(tracked, result)
+	}
+}
+
+// Start runs the work in the background, and returns the Accepted result for the operation tracking it. Panics in the
+// work result in Error.
+func (t *AsyncOperationTracker) Start(resource string, work func() OperationResult) OperationResult {
+	operation, complete := t.Track(resource)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				complete(ErrorResult(fmt.Errorf("Operation failed: %v", r)))
+			}
+		}()
+		complete(work())
+	}()
+	return AcceptedResult(operation)
+}
+
+// Get returns the current status of an operation.
+func (t *AsyncOperationTracker) Get(id string) (*AsyncOperation, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	tracked, ok := t.operations[id]
+	if !ok {
+		return nil, false
+	}
+	snapshot := tracked.operation
+	return &snapshot, true
+}
+
+// Done returns a channel that's closed when the operation completes, or nil if the operation is unknown.
+func (t *AsyncOperationTracker) Done(id string) <-chan struct{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if tracked, ok := t.operations[id]; ok {
+		return tracked.done
+	}
+	return nil
+}
+
+// Wait returns the status of an operation once it completes, or when the timeout expires, whichever comes first.
+func (t *AsyncOperationTracker) Wait(id string, timeout time.Duration) (*AsyncOperation, bool) {
+	return t.WaitContext(context.Background(), id, timeout)
+}
+
+// WaitContext returns the status of an operation once it completes, when the timeout expires, or when the context is
+// done, whichever comes first.
+func (t *AsyncOperationTracker) WaitContext(ctx context.Context, id string, timeout time.Duration) (*AsyncOperation, bool) {
+	done := t.Done(id)
+	if done == nil {
+		return nil, false
+	}
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-done:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+	return t.Get(id)
+}
+
+func (t *AsyncOperationTracker) complete(tracked *trackedOperation, result OperationResult) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if tracked.operation.Status == AsyncOperationCompleted {
+		return
+	}
+	if result == nil {
+		result = OkResult(nil)
+	}
+	completedAt := t.Now()
+	tracked.operation.Status = AsyncOperationCompleted
+	tracked.operation.CompletedAt = &completedAt
+	tracked.operation.Result = &AsyncOperationResult{
+		State:      result.State(),
+		StatusCode: StatusCodeForState(result.State()),
+		Value:      result.Value(),
+	}
+	if result.Error() != nil {
+		details := &ErrorDetails{Message: result.Error().Error()}
+		if detailed, ok := result.Error().(DetailedError); ok {
+			details.Details = detailed.Details()
+		}
+		tracked.operation.Result.Error = details
+	}
+	close(tracked.done)
+}
+
+// deleteExpired forgets completed operations past their retention period. The caller holds the lock.
+func (t *AsyncOperationTracker) deleteExpired() {
+	now := t.Now()
+	for id, tracked := range t.operations {
+		completedAt := tracked.operation.CompletedAt
+		if completedAt != nil && !now.Before(completedAt.Add(t.Retention)) {
+			delete(t.operations, id)
+		}
+	}
+}
+
+func newAsyncOperationID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
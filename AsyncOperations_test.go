@@ -0,0 +1,167 @@
+package crud_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// asyncService adds entities in the background, once released
+type asyncService struct {
+	*memoryService
+	tracker *crud.AsyncOperationTracker
+	release chan struct{}
+}
+
+func (s *asyncService) Add(entity crud.Entity) crud.OperationResult {
+	return s.tracker.Start("things", func() crud.OperationResult {
+		<-s.release
+		return s.memoryService.Add(entity)
+	})
+}
+
+func newAsyncService() *asyncService {
+	return &asyncService{
+		memoryService: newMemoryService(),
+		tracker:       crud.NewAsyncOperationTracker("/operations", time.Hour),
+		release:       make(chan struct{}),
+	}
+}
+
+func TestAsyncOperationTracker_Start(t *testing.T) {
+	svc := newAsyncService()
+
+	result := svc.Add(&testEntity{Name: "first"})
+
+	assert.Equal(t, crud.Accepted, result.State())
+	operation := result.Value().(*crud.AsyncOperation)
+	assert.Equal(t, crud.AsyncOperationRunning, operation.Status)
+	assert.Equal(t, "/operations/"+operation.ID, operation.Location)
+
+	close(svc.release)
+	completed, ok := svc.tracker.Wait(operation.ID, time.Second)
+	assert.True(t, ok)
+	assert.Equal(t, crud.AsyncOperationCompleted, completed.Status)
+	assert.Equal(t, crud.Created, completed.Result.State)
+	assert.Equal(t, http.StatusCreated, completed.Result.StatusCode)
+	assert.Equal(t, crud.Created, completed.Result.OperationResult().State())
+	assert.NotNil(t, completed.CompletedAt)
+}
+
+func TestAsyncOperationTracker_RecoversPanics(t *testing.T) {
+	tracker := crud.NewAsyncOperationTracker("/operations", time.Hour)
+
+	result := tracker.Start("things", func() crud.OperationResult {
+		panic("boom")
+	})
+
+	operation, _ := tracker.Wait(result.Value().(*crud.AsyncOperation).ID, time.Second)
+	assert.Equal(t, crud.Error, operation.Result.State)
+	assert.Equal(t, "Operation failed: boom", operation.Result.Error.Message)
+}
+
+func TestAsyncOperationTracker_ForgetsExpiredOperations(t *testing.T) {
+	tracker := crud.NewAsyncOperationTracker("/operations", 90*time.Minute)
+	tracker.Now = newSteppingClock(historyStart)
+
+	first, complete := tracker.Track("things") // 13:00
+	complete(crud.OkResult(nil))               // 14:00, kept until 15:30
+	tracker.Track("things")                    // 15:00
+	_, ok := tracker.Get(first.ID)
+	assert.True(t, ok)
+
+	tracker.Track("things") // 16:00
+	_, ok = tracker.Get(first.ID)
+	assert.False(t, ok)
+}
+
+func TestCreateCrudHandlerCreateEntity_Accepted(t *testing.T) {
+	svc := newAsyncService()
+	router := mux.NewRouter()
+	router.HandleFunc("/things", crud.CreateCrudHandlerCreateEntity(newTestContext(), svc, "things", noRecovery, newTestEntity)).Methods("POST")
+	router.HandleFunc("/operations/{id}", crud.CreateCrudHandlerGetAsyncOperation(newTestContext(), svc.tracker, noRecovery)).Methods("GET")
+
+	r, _ := http.NewRequest("POST", "/things", strings.NewReader(`{"name":"first"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	location := w.Header().Get("Location")
+	assert.True(t, strings.HasPrefix(location, "/operations/"))
+
+	r, _ = http.NewRequest("GET", location, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"running"`)
+
+	close(svc.release)
+	r, _ = http.NewRequest("GET", location+"?wait=5", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	var operation crud.AsyncOperation
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &operation))
+	assert.Equal(t, crud.AsyncOperationCompleted, operation.Status)
+	assert.Equal(t, http.StatusCreated, operation.Result.StatusCode)
+	assert.Equal(t, float64(1), operation.Result.Value)
+}
+
+func TestCreateCrudHandlerGetAsyncOperation_NotFound(t *testing.T) {
+	tracker := crud.NewAsyncOperationTracker("/operations", time.Hour)
+	router := mux.NewRouter()
+	router.HandleFunc("/operations/{id}", crud.CreateCrudHandlerGetAsyncOperation(newTestContext(), tracker, noRecovery)).Methods("GET")
+	r, _ := http.NewRequest("GET", "/operations/unknown", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func newAsyncOperationRouter(tracker *crud.AsyncOperationTracker) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/operations/{id}", crud.CreateCrudHandlerGetAsyncOperation(newTestContext(), tracker, noRecovery)).Methods("GET")
+	return router
+}
+
+func TestCreateCrudHandlerGetAsyncOperation_WaitIsClamped(t *testing.T) {
+	svc := newAsyncService()
+	operation := svc.Add(&testEntity{Name: "first"}).Value().(*crud.AsyncOperation)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(svc.release)
+	}()
+
+	// Too many seconds to fit in a time.Duration
+	r, _ := http.NewRequest("GET", operation.Location+"?wait=9223372037", nil)
+	w := httptest.NewRecorder()
+	newAsyncOperationRouter(svc.tracker).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"completed"`)
+}
+
+func TestCreateCrudHandlerGetAsyncOperation_StopsWaitingWhenClientGoesAway(t *testing.T) {
+	svc := newAsyncService()
+	operation := svc.Add(&testEntity{Name: "first"}).Value().(*crud.AsyncOperation)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r, _ := http.NewRequest("GET", operation.Location+"?wait=60", nil)
+	w := httptest.NewRecorder()
+	started := time.Now()
+	newAsyncOperationRouter(svc.tracker).ServeHTTP(w, r.WithContext(ctx))
+
+	assert.True(t, time.Since(started) < time.Second)
+	assert.Contains(t, w.Body.String(), `"status":"running"`)
+	close(svc.release)
+}
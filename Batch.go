@@ -0,0 +1,251 @@
+package crud
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MaxBatchRequests is the maximum number of sub-requests in a single batch.
+var MaxBatchRequests = 100
+
+// BatchSubRequest is a single operation of a batch
+type BatchSubRequest struct {
+	// Ref names the sub-request, so that later sub-requests can refer to its response. Optional; sub-requests can be
+	// referred to by their zero-based index as well.
+	Ref string `json:"ref,omitempty"`
+	// Method is the HTTP method of the operation: GET, POST, PUT or DELETE.
+	Method string `json:"method"`
+	// Resource is the name of a registered resource.
+	Resource string `json:"resource"`
+	// Parents holds the keys of the parent entities of a child resource, by the names of their path variables.
+	Parents map[string]string `json:"parents,omitempty"`
+	// ID is the ID of the entity, for operations on a single entity.
+	ID string `json:"id,omitempty"`
+	// Body is the entity, for POST and PUT.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchRequest is a list of operations, performed in order. The ID, the parent keys and the strings in the body of a
+// sub-request can refer to the response of an earlier sub-request as {{ref}} for the whole response, or {{ref.field}}
+// for a field of it, e.g. {{newBooking}} for the ID returned when creating a booking. A string consisting of a single
+// reference is replaced by the referred value as is, so numbers remain numbers.
+type BatchRequest struct {
+	// Requests are the operations to perform.
+	Requests []BatchSubRequest `json:"requests"`
+	// ContinueOnError continues with the next sub-requests when one fails. By default, the remaining sub-requests are
+	// skipped.
+	ContinueOnError bool `json:"continueOnError"`
+}
+
+// BatchSubResponse is the response to a single operation of a batch
+type BatchSubResponse struct {
+	// Ref is the name of the sub-request, if it had one.
+	Ref string `json:"ref,omitempty"`
+	// Status is the HTTP status code of the response. Skipped sub-requests get 424 (Failed Dependency).
+	Status int `json:"status"`
+	// Location is the Location header of the response, if any.
+	Location string `json:"location,omitempty"`
+	// Body is the body of the response.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchResponse holds the responses to all operations of a batch, in the order of the requests
+type BatchResponse struct {
+	// Responses are the responses to the sub-requests.
+	Responses []BatchSubResponse `json:"responses"`
+}
+
+// Validate checks the number of sub-requests, and whether they refer to registered resources with supported methods
+func (b *BatchRequest) Validate(reg *Registry) error {
+	if len(b.Requests) == 0 {
+		return errors.New("At least one request is required")
+	}
+	if len(b.Requests) > MaxBatchRequests {
+		return fmt.Errorf("A batch can't hold more than %v requests", MaxBatchRequests)
+	}
+	refs := make(map[string]bool)
+	for i, sub := range b.Requests {
+		if _, ok := reg.Resource(sub.Resource); !ok {
+			return fmt.Errorf("Request %v: unknown resource %v", i, sub.Resource)
+		}
+		switch strings.ToUpper(sub.Method) {
+		case "GET", "POST":
+		case "PUT", "DELETE":
+			if sub.ID == "" {
+				return fmt.Errorf("Request %v: %v needs an id", i, sub.Method)
+			}
+		default:
+			return fmt.Errorf("Request %v: unsupported method %v", i, sub.Method)
+		}
+		if sub.Ref != "" {
+			if refs[sub.Ref] {
+				return fmt.Errorf("Request %v: duplicate ref %v", i, sub.Ref)
+			}
+			refs[sub.Ref] = true
+		}
+	}
+	return nil
+}
+
+// batchReference matches references to earlier responses, e.g. {{newBooking}} or {{0.id}}.
+var batchReference = regexp.MustCompile(`\{\{\s*([^{}\s]+)\s*\}\}`)
+
+// batchResults keeps the decoded responses of the sub-requests performed so far, to resolve references.
+type batchResults struct {
+	refs   map[string]int
+	values []interface{}
+	failed []bool
+}
+
+func newBatchResults() *batchResults {
+	return &batchResults{refs: make(map[string]int)}
+}
+
+// add records the response to a sub-request.
+func (b *batchResults) add(ref string, response *BatchSubResponse) {
+	var value interface{}
+	if len(response.Body) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(response.Body))
+		decoder.UseNumber()
+		decoder.Decode(&value)
+	}
+	if ref != "" {
+		b.refs[ref] = len(b.values)
+	}
+	b.values = append(b.values, value)
+	b.failed = append(b.failed, response.Status < 200 || response.Status >= 300)
+}
+
+// lookup resolves a reference such as newBooking or 0.id.
+func (b *batchResults) lookup(reference string) (interface{}, error) {
+	parts := strings.Split(reference, ".")
+	index, ok := b.refs[parts[0]]
+	if !ok {
+		var err error
+		if index, err = strconv.Atoi(parts[0]); err != nil || index < 0 || index >= len(b.values) {
+			return nil, fmt.Errorf("Unknown reference: %v", reference)
+		}
+	}
+	if b.failed[index] {
+		return nil, fmt.Errorf("Reference to failed request: %v", reference)
+	}
+
+	value := b.values[index]
+	for _, field := range parts[1:] {
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Unknown reference: %v", reference)
+		}
+		if value, ok = fieldValue(fields, field); !ok {
+			return nil, fmt.Errorf("Unknown reference: %v", reference)
+		}
+	}
+	return value, nil
+}
+
+// resolve replaces the references in a string. A string consisting of a single reference is replaced by the referred
+// value as is; otherwise, the referred values are formatted into the string.
+func (b *batchResults) resolve(s string) (interface{}, error) {
+	if match := batchReference.FindStringSubmatch(s); match != nil && match[0] == strings.TrimSpace(s) {
+		return b.lookup(match[1])
+	}
+
+	var err error
+	result := batchReference.ReplaceAllStringFunc(s, func(m string) string {
+		value, lookupErr := b.lookup(batchReference.FindStringSubmatch(m)[1])
+		if lookupErr != nil {
+			err = lookupErr
+			return m
+		}
+		return fmt.Sprint(value)
+	})
+	return result, err
+}
+
+// resolveString replaces the references in a string, formatting the result as a string.
+func (b *batchResults) resolveString(s string) (string, error) {
+	value, err := b.resolve(s)
+	if err != nil {
+		return "", err
+	}
+	if value == nil {
+		return "", nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// resolveBody replaces the references in the strings of a JSON body.
+func (b *batchResults) resolveBody(body json.RawMessage) (json.RawMessage, error) {
+	if len(body) == 0 || !batchReference.Match(body) {
+		return body, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	value, err := b.resolveValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+func (b *batchResults) resolveValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return b.resolve(v)
+	case []interface{}:
+		for i := range v {
+			resolved, err := b.resolveValue(v[i])
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+	case map[string]interface{}:
+		for k := range v {
+			resolved, err := b.resolveValue(v[k])
+			if err != nil {
+				return nil, err
+			}
+			v[k] = resolved
+		}
+	}
+	return value, nil
+}
+
+// batchResponseWriter records the response to a sub-request.
+type batchResponseWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func newBatchResponseWriter() *batchResponseWriter {
+	return &batchResponseWriter{header: make(http.Header)}
+}
+
+func (w *batchResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *batchResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+func (w *batchResponseWriter) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	return w.body.Write(b)
+}
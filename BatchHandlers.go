@@ -0,0 +1,112 @@
+package crud
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+)
+
+// CreateCrudHandlerBatch is used to perform several operations on the resources of a registry in one request. Route it
+// as e.g. POST /_batch. The body is a BatchRequest; the sub-requests are dispatched in order to the handler the
+// resources are routed on, with the headers of the batch request. The reply is a BatchResponse.
+var CreateCrudHandlerBatch = func(ctx servicefoundation.AppContext, registry *Registry, handler http.Handler, recoverFunc RecoverFunc) http.HandlerFunc {
+	logger := ctx.Logger()
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer recoverFunc("crudHandler", ctx, w, r)
+
+		batch := &BatchRequest{}
+		if err := ReadEntityFromBody(r.Body, batch); err != nil {
+			WriteOperationResult(w, r, ValidationFailedResult(errors.New("Failed to parse batch from HTTP body: "+err.Error())))
+			return
+		}
+		if err := batch.Validate(registry); err != nil {
+			WriteOperationResult(w, r, ValidationFailedResult(err))
+			return
+		}
+
+		logger.Debug("CreateCrudHandlerBatch", fmt.Sprintf("Interpreted as batch command. Requests: %v", len(batch.Requests)))
+		results := newBatchResults()
+		response := &BatchResponse{Responses: make([]BatchSubResponse, 0, len(batch.Requests))}
+		failed := false
+		for _, sub := range batch.Requests {
+			var subResponse *BatchSubResponse
+			if failed && !batch.ContinueOnError {
+				subResponse = batchErrorResponse(http.StatusFailedDependency, errors.New("Skipped after an earlier request failed"))
+			} else {
+				subResponse = dispatchBatchRequest(registry, handler, r, sub, results)
+			}
+			subResponse.Ref = sub.Ref
+			results.add(sub.Ref, subResponse)
+			if subResponse.Status < 200 || subResponse.Status >= 300 {
+				failed = true
+			}
+			response.Responses = append(response.Responses, *subResponse)
+		}
+		WriteOperationResult(w, r, OkResult(response))
+	}
+}
+
+// dispatchBatchRequest resolves the references of a sub-request, and performs it through the handler.
+func dispatchBatchRequest(registry *Registry, handler http.Handler, r *http.Request, sub BatchSubRequest, results *batchResults) *BatchSubResponse {
+	resource, _ := registry.Resource(sub.Resource)
+	path := registry.ResourcePath(resource)
+
+	ancestors, _ := registry.ancestors(resource)
+	for i := range ancestors {
+		key := ancestorKey(resource, ancestors, i)
+		parent, err := results.resolveString(sub.Parents[key])
+		if err != nil {
+			return batchErrorResponse(http.StatusBadRequest, err)
+		}
+		if parent == "" {
+			return batchErrorResponse(http.StatusBadRequest, errors.New("Missing parent key: "+key))
+		}
+		path = strings.Replace(path, "{"+key+"}", url.PathEscape(parent), 1)
+	}
+	if sub.ID != "" {
+		id, err := results.resolveString(sub.ID)
+		if err != nil {
+			return batchErrorResponse(http.StatusBadRequest, err)
+		}
+		path += "/" + url.PathEscape(id)
+	}
+	body, err := results.resolveBody(sub.Body)
+	if err != nil {
+		return batchErrorResponse(http.StatusBadRequest, err)
+	}
+
+	subRequest, err := http.NewRequest(strings.ToUpper(sub.Method), path, bytes.NewReader(body))
+	if err != nil {
+		return batchErrorResponse(http.StatusBadRequest, err)
+	}
+	subRequest = subRequest.WithContext(r.Context())
+	for k, v := range r.Header {
+		subRequest.Header[k] = v
+	}
+	subRequest.Header.Del("Content-Length")
+	subRequest.Header.Del(IdempotencyKeyHeader)
+	subRequest.Header.Set("Content-Type", "application/json")
+
+	writer := newBatchResponseWriter()
+	handler.ServeHTTP(writer, subRequest)
+	if writer.statusCode == 0 {
+		writer.statusCode = http.StatusOK
+	}
+	subResponse := &BatchSubResponse{Status: writer.statusCode, Location: writer.header.Get("Location")}
+	if raw := bytes.TrimSpace(writer.body.Bytes()); len(raw) > 0 && json.Valid(raw) {
+		subResponse.Body = json.RawMessage(raw)
+	}
+	return subResponse
+}
+
+// batchErrorResponse is the response to a sub-request that couldn't be performed.
+func batchErrorResponse(status int, err error) *BatchSubResponse {
+	body, _ := json.Marshal(&ErrorDetails{Message: err.Error()})
+	return &BatchSubResponse{Status: status, Body: body}
+}
@@ -0,0 +1,102 @@
+package crud_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveBatch(t *testing.T, registry *crud.Registry, batch string) *crud.BatchResponse {
+	registry.Batch = true
+	router := mux.NewRouter()
+	registry.Route(router)
+
+	w := serve(router, "POST", "/api/_batch", batch)
+
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return &crud.BatchResponse{}
+	}
+	response := &crud.BatchResponse{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), response))
+	return response
+}
+
+func TestRegistry_RouteBatch(t *testing.T) {
+	registry, bookings, passengers := newNestedRegistry()
+
+	response := serveBatch(t, registry, `{"requests": [
+		{"ref": "booking", "method": "POST", "resource": "bookings", "body": {"name": "trip", "status": "new"}},
+		{"method": "PUT", "resource": "bookings", "id": "{{booking}}", "body": {"name": "trip", "status": "confirmed"}},
+		{"method": "POST", "resource": "passengers", "parents": {"bookingId": "{{booking}}"}, "body": {"name": "passenger of {{1.name}}"}},
+		{"method": "GET", "resource": "bookings", "id": "{{booking}}"}
+	]}`)
+
+	if !assert.Len(t, response.Responses, 4) {
+		return
+	}
+	assert.Equal(t, "booking", response.Responses[0].Ref)
+	assert.Equal(t, http.StatusCreated, response.Responses[0].Status)
+	assert.Equal(t, http.StatusOK, response.Responses[1].Status)
+	assert.Equal(t, http.StatusCreated, response.Responses[2].Status)
+	assert.Equal(t, http.StatusOK, response.Responses[3].Status)
+	assert.JSONEq(t, `{"id": 1, "name": "trip", "status": "confirmed"}`, string(response.Responses[3].Body))
+
+	assert.Equal(t, "confirmed", bookings.entities["1"].Status)
+	assert.Equal(t, "passenger of trip", passengers.bookings["1"].entities["1"].Name)
+}
+
+func TestRegistry_RouteBatchStopsAtFailure(t *testing.T) {
+	registry, bookings, _ := newNestedRegistry()
+
+	response := serveBatch(t, registry, `{"requests": [
+		{"ref": "invalid", "method": "POST", "resource": "bookings", "body": {"status": "new"}},
+		{"method": "POST", "resource": "bookings", "body": {"name": "trip"}}
+	]}`)
+
+	assert.Equal(t, http.StatusBadRequest, response.Responses[0].Status)
+	assert.Equal(t, http.StatusFailedDependency, response.Responses[1].Status)
+	assert.Len(t, bookings.entities, 0)
+}
+
+func TestRegistry_RouteBatchContinueOnError(t *testing.T) {
+	registry, bookings, _ := newNestedRegistry()
+
+	response := serveBatch(t, registry, `{"continueOnError": true, "requests": [
+		{"ref": "invalid", "method": "POST", "resource": "bookings", "body": {"status": "new"}},
+		{"method": "POST", "resource": "bookings", "body": {"name": "trip"}},
+		{"method": "DELETE", "resource": "bookings", "id": "{{invalid}}"}
+	]}`)
+
+	assert.Equal(t, http.StatusBadRequest, response.Responses[0].Status)
+	assert.Equal(t, http.StatusCreated, response.Responses[1].Status)
+	// References to failed requests can't be resolved
+	assert.Equal(t, http.StatusBadRequest, response.Responses[2].Status)
+	assert.Len(t, bookings.entities, 1)
+}
+
+func TestRegistry_RouteBatchInvalid(t *testing.T) {
+	registry, _, _ := newNestedRegistry()
+	registry.Batch = true
+	router := mux.NewRouter()
+	registry.Route(router)
+
+	w := serve(router, "POST", "/api/_batch", `{"requests": [{"method": "GET", "resource": "unknown"}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(router, "POST", "/api/_batch", `{"requests": [{"method": "PATCH", "resource": "bookings"}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(router, "POST", "/api/_batch", `{"requests": []}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Batches aren't routed unless enabled
+	registry.Batch = false
+	router = mux.NewRouter()
+	registry.Route(router)
+	w = serve(router, "POST", "/api/_batch", `{"requests": [{"method": "GET", "resource": "bookings"}]}`)
+	assert.NotEqual(t, http.StatusOK, w.Code)
+}
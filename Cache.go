@@ -0,0 +1,339 @@
+package crud
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CacheStore keeps cached values until they expire. Stores may evict values earlier, e.g. to bound their size.
+type CacheStore interface {
+	// Get returns the value for the key, and whether it was found.
+	Get(key string) ([]byte, bool, error)
+	// Set stores the value for the key for the given duration. A zero duration keeps the value until it's evicted.
+	Set(key string, value []byte, ttl time.Duration) error
+	// Delete forgets the value for the key, if any.
+	Delete(key string) error
+}
+
+// CachingService is a read-through caching Service decorator. Successful results of GetByID and GetAll are cached per
+// resource, for GetAll by the full DataSetRequest. Every Add, Update and Delete invalidates all cached results of the
+// resource; if it's Accepted, they're invalidated once more when the asynchronous operation completes, provided it's
+// tracked by an AsyncOperationTracker. Failing stores are treated as cache misses, so the decorated Service is used instead. As a
+// ServiceDecorator, it forwards the optional interfaces of the decorated Service, whose results aren't cached.
+type CachingService struct {
+	Service
+
+	// Store keeps the cached results; it can be shared by resources.
+	Store CacheStore
+	// Resource is the name of the resource, prefixing the cache keys.
+	Resource string
+	// TTL is how long results are cached.
+	TTL time.Duration
+	// CreateFunc creates the entities cached results are decoded into. If nil, entities are decoded as JSON objects.
+	CreateFunc func() Entity
+}
+
+// NewCachingService wraps the given Service with one caching the results of GetByID and GetAll for the resource in the
+// store, for the given duration.
+func NewCachingService(svc Service, store CacheStore, resourceName string, ttl time.Duration, createFunc func() Entity) *CachingService {
+	return &CachingService{Service: svc, Store: store, Resource: resourceName, TTL: ttl, CreateFunc: createFunc}
+}
+
+// EntityPipeline uses the pipeline of the decorated Service.
+func (c *CachingService) EntityPipeline() *EntityPipeline {
+	return pipelineFor(c.Service)
+}
+
+// ResourcePolicy uses the policy of the decorated Service.
+func (c *CachingService) ResourcePolicy() *ResourcePolicy {
+	return policyFor(c.Service)
+}
+
+// Decorated returns the decorated Service.
+func (c *CachingService) Decorated() Service {
+	return c.Service
+}
+
+// GetAllAsOf gets the entities as they were at the given moment from the decorated Service, if it's an AsOfService.
+func (c *CachingService) GetAllAsOf(request *DataSetRequest, asOf time.Time) OperationResult {
+	return getAllAsOf(c.Service, request, asOf)
+}
+
+// GetByIDAsOf gets the entity as it was at the given moment from the decorated Service, if it's an AsOfService.
+func (c *CachingService) GetByIDAsOf(id EntityKey, asOf time.Time) OperationResult {
+	return getByIDAsOf(c.Service, id, asOf)
+}
+
+// Aggregate aggregates the entities using the decorated Service, if it's an Aggregator.
+func (c *CachingService) Aggregate(request *AggregationRequest) OperationResult {
+	return aggregate(c.Service, request)
+}
+
+// GetDistinctValues gets the distinct values of a column from the decorated Service, if it's a DistinctValuesService
+// or an Aggregator.
+func (c *CachingService) GetDistinctValues(request *DistinctValuesRequest) OperationResult {
+	return distinctValuesFor(c.Service, request)
+}
+
+// GetByIDs gets the entities with the given keys from the decorated Service, in a single call if it's a BatchGetter.
+func (c *CachingService) GetByIDs(ids []EntityKey) OperationResult {
+	return getByIDs(c.Service, ids)
+}
+
+// cachedDataSet is a DataSet as cached, with the items decoded separately.
+type cachedDataSet struct {
+	Items      []json.RawMessage `json:"items"`
+	PagingInfo PagingInfo        `json:"pagingInfo"`
+}
+
+// GetAll gets the entities from the cache, or from the decorated Service if they aren't cached
+func (c *CachingService) GetAll(request *DataSetRequest) OperationResult {
+	fingerprint, err := json.Marshal(request)
+	if err != nil {
+		return c.Service.GetAll(request)
+	}
+	hash := sha256.Sum256(fingerprint)
+	key := c.key("list", hex.EncodeToString(hash[:]))
+	if key != "" {
+		if data, ok, err := c.Store.Get(key); err == nil && ok {
+			if dataSet, err := c.decodeDataSet(data); err == nil {
+				return OkResult(dataSet)
+			}
+		}
+	}
+
+	result := c.Service.GetAll(request)
+	c.store(key, result)
+	return result
+}
+
+// GetByID gets the entity from the cache, or from the decorated Service if it isn't cached
+func (c *CachingService) GetByID(id EntityKey) OperationResult {
+	key := c.key("id", fmt.Sprint(id))
+	if key != "" {
+		if data, ok, err := c.Store.Get(key); err == nil && ok {
+			if entity, err := c.decode(data); err == nil {
+				return OkResult(entity)
+			}
+		}
+	}
+
+	result := c.Service.GetByID(id)
+	c.store(key, result)
+	return result
+}
+
+// Add adds the entity, invalidating the cached results
+func (c *CachingService) Add(entity Entity) OperationResult {
+	return c.invalidateAfter(c.Service.Add(entity))
+}
+
+// Update updates the entity, invalidating the cached results
+func (c *CachingService) Update(id EntityKey, entity Entity) OperationResult {
+	return c.invalidateAfter(c.Service.Update(id, entity))
+}
+
+// Delete deletes the entity, invalidating the cached results
+func (c *CachingService) Delete(id EntityKey) OperationResult {
+	return c.invalidateAfter(c.Service.Delete(id))
+}
+
+// invalidateAfter invalidates the cached results after a mutation, and once more when it's Accepted and the
+// asynchronous operation completes.
+func (c *CachingService) invalidateAfter(result OperationResult) OperationResult {
+	c.Invalidate()
+	if result == nil || result.State() != Accepted {
+		return result
+	}
+	if operation, ok := result.Value().(*AsyncOperation); ok && operation.Done() != nil {
+		go func() {
+			<-operation.Done()
+			c.Invalidate()
+		}()
+	}
+	return result
+}
+
+// Invalidate forgets all cached results of the resource, e.g. after changing its entities without using the
+// CachingService. Cached results are keyed by a generation of the resource; invalidating starts a new generation, so
+// the results of earlier ones are never used again, and expire in time.
+func (c *CachingService) Invalidate() error {
+	return c.Store.Set(c.generationKey(), []byte(newGeneration()), 0)
+}
+
+func (c *CachingService) generationKey() string {
+	return "crud:" + c.Resource + ":generation"
+}
+
+// key returns the cache key of a result in the current generation of the resource. It's empty if the generation can't
+// be determined, in which case nothing is cached.
+func (c *CachingService) key(kind, id string) string {
+	generation, ok, err := c.Store.Get(c.generationKey())
+	if err != nil {
+		return ""
+	}
+	if !ok {
+		generation = []byte(newGeneration())
+		if err := c.Store.Set(c.generationKey(), generation, 0); err != nil {
+			return ""
+		}
+	}
+	return "crud:" + c.Resource + ":" + string(generation) + ":" + kind + ":" + id
+}
+
+// store caches the result, if it's successful.
+func (c *CachingService) store(key string, result OperationResult) {
+	if key == "" || result == nil || result.State() != Ok || result.Value() == nil {
+		return
+	}
+	if data, err := json.Marshal(result.Value()); err == nil {
+		c.Store.Set(key, data, c.TTL)
+	}
+}
+
+// decode decodes a cached entity.
+func (c *CachingService) decode(data []byte) (interface{}, error) {
+	if c.CreateFunc == nil {
+		var entity interface{}
+		err := json.Unmarshal(data, &entity)
+		return entity, err
+	}
+	entity := c.CreateFunc()
+	if err := json.Unmarshal(data, entity); err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// decodeDataSet decodes a cached DataSet.
+func (c *CachingService) decodeDataSet(data []byte) (*DataSet, error) {
+	cached := &cachedDataSet{}
+	if err := json.Unmarshal(data, cached); err != nil {
+		return nil, err
+	}
+	dataSet := &DataSet{Items: make([]interface{}, len(cached.Items)), PagingInfo: cached.PagingInfo}
+	for i, item := range cached.Items {
+		entity, err := c.decode(item)
+		if err != nil {
+			return nil, err
+		}
+		dataSet.Items[i] = entity
+	}
+	return dataSet, nil
+}
+
+var (
+	generationMutex sync.Mutex
+	lastGeneration  int64
+)
+
+// newGeneration returns a generation of cached results. Generations are based on the current time, so that they differ
+// from the ones of earlier runs and other instances of the service.
+func newGeneration() string {
+	generationMutex.Lock()
+	defer generationMutex.Unlock()
+
+	generation := time.Now().UnixNano()
+	if generation <= lastGeneration {
+		generation = lastGeneration + 1
+	}
+	lastGeneration = generation
+	return strconv.FormatInt(generation, 36)
+}
+
+// MemoryCacheStore is a CacheStore keeping its values in memory, evicting the least recently used values once it holds
+// its maximum number of values. It's only shared by the resources of a single instance of a service.
+type MemoryCacheStore struct {
+	// Now returns the current time. Replaceable for testing purposes; defaults to time.Now.
+	Now func() time.Time
+	// MaxEntries is the maximum number of values held.
+	MaxEntries int
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type memoryCacheEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewMemoryCacheStore creates an empty in-memory store holding up to the given number of values.
+func NewMemoryCacheStore(maxEntries int) *MemoryCacheStore {
+	return &MemoryCacheStore{
+		Now:        time.Now,
+		MaxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// Get returns the value for the key, unless it expired
+func (s *MemoryCacheStore) Get(key string) ([]byte, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := element.Value.(*memoryCacheEntry)
+	if !entry.expiresAt.IsZero() && !s.Now().Before(entry.expiresAt) {
+		s.remove(element)
+		return nil, false, nil
+	}
+	s.lru.MoveToFront(element)
+	return entry.value, true, nil
+}
+
+// Set stores the value for the key, evicting the least recently used value if the store is full
+func (s *MemoryCacheStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry := &memoryCacheEntry{key: key, value: value}
+	if ttl > 0 {
+		entry.expiresAt = s.Now().Add(ttl)
+	}
+	if element, ok := s.entries[key]; ok {
+		element.Value = entry
+		s.lru.MoveToFront(element)
+		return nil
+	}
+	s.entries[key] = s.lru.PushFront(entry)
+	for s.MaxEntries > 0 && s.lru.Len() > s.MaxEntries {
+		s.remove(s.lru.Back())
+	}
+	return nil
+}
+
+// Delete forgets the value for the key
+func (s *MemoryCacheStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		s.remove(element)
+	}
+	return nil
+}
+
+// Len returns the number of values held, including the ones that expired but weren't evicted yet.
+func (s *MemoryCacheStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lru.Len()
+}
+
+func (s *MemoryCacheStore) remove(element *list.Element) {
+	s.lru.Remove(element)
+	delete(s.entries, element.Value.(*memoryCacheEntry).key)
+}
@@ -0,0 +1,167 @@
+package crud
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisCacheStore is a CacheStore keeping its values in Redis, or a server speaking its protocol, so that cached results
+// are shared between instances of a service. It uses a single connection, which is reopened after failures. Eviction is
+// up to the server, see its maxmemory-policy.
+type RedisCacheStore struct {
+	// Dial opens a connection to the server.
+	Dial func() (net.Conn, error)
+	// Timeout bounds the time a command may take, including dialing. No timeout if zero.
+	Timeout time.Duration
+
+	mutex  sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedisCacheStore creates a store using the server at the given address, e.g. localhost:6379.
+func NewRedisCacheStore(address string, timeout time.Duration) *RedisCacheStore {
+	return &RedisCacheStore{
+		Dial: func() (net.Conn, error) {
+			return net.DialTimeout("tcp", address, timeout)
+		},
+		Timeout: timeout,
+	}
+}
+
+// Get returns the value for the key, using GET
+func (s *RedisCacheStore) Get(key string) ([]byte, bool, error) {
+	reply, err := s.do("GET", key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("Unexpected reply to GET: %v", reply)
+	}
+	return value, true, nil
+}
+
+// Set stores the value for the key, using SET with PX for the time to live
+func (s *RedisCacheStore) Set(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		milliseconds := int64(ttl / time.Millisecond)
+		if milliseconds < 1 {
+			milliseconds = 1
+		}
+		args = append(args, "PX", strconv.FormatInt(milliseconds, 10))
+	}
+	_, err := s.do(args...)
+	return err
+}
+
+// Delete forgets the value for the key, using DEL
+func (s *RedisCacheStore) Delete(key string) error {
+	_, err := s.do("DEL", key)
+	return err
+}
+
+// Close closes the connection to the server, if open. The store can still be used; it reconnects when needed.
+func (s *RedisCacheStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.disconnect()
+}
+
+// do sends a command and reads its reply: a string for status replies, an int64 for integers, a []byte for bulk
+// strings, and nil for null replies. Error replies are returned as errors.
+func (s *RedisCacheStore) do(args ...string) (interface{}, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.conn == nil {
+		conn, err := s.Dial()
+		if err != nil {
+			return nil, err
+		}
+		s.conn = conn
+		s.reader = bufio.NewReader(conn)
+	}
+	if s.Timeout > 0 {
+		s.conn.SetDeadline(time.Now().Add(s.Timeout))
+	}
+
+	reply, err := s.roundTrip(args)
+	if _, isReplyError := err.(redisError); err != nil && !isReplyError {
+		// The connection is in an unknown state
+		s.disconnect()
+	}
+	return reply, err
+}
+
+func (s *RedisCacheStore) roundTrip(args []string) (interface{}, error) {
+	writer := bufio.NewWriter(s.conn)
+	fmt.Fprintf(writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, err
+	}
+	return readRedisReply(s.reader)
+}
+
+func (s *RedisCacheStore) disconnect() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	s.reader = nil
+	return err
+}
+
+// redisError is an error reply of the server.
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// readRedisReply reads a reply in the Redis serialization protocol. Arrays aren't supported, as the commands used
+// don't reply with them.
+func readRedisReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("Malformed reply: " + line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errors.New("Malformed reply: " + line)
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return data[:length], nil
+	}
+	return nil, errors.New("Unsupported reply: " + line)
+}
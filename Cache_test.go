@@ -0,0 +1,258 @@
+package crud_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// readCountingService counts the reads reaching the decorated Service
+type readCountingService struct {
+	crud.Service
+	mutex sync.Mutex
+	reads int
+}
+
+func (c *readCountingService) count() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.reads++
+}
+
+func (c *readCountingService) Reads() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.reads
+}
+
+func (c *readCountingService) GetAll(request *crud.DataSetRequest) crud.OperationResult {
+	c.count()
+	return c.Service.GetAll(request)
+}
+
+func (c *readCountingService) GetByID(id crud.EntityKey) crud.OperationResult {
+	c.count()
+	return c.Service.GetByID(id)
+}
+
+func testCachingService(t *testing.T, store crud.CacheStore) {
+	counting := &readCountingService{Service: newMemoryService()}
+	svc := crud.NewCachingService(counting, store, "things", time.Minute, newTestEntity)
+	svc.Add(&testEntity{Name: "first", Status: "new"})
+
+	// Reads are cached
+	assert.Equal(t, &testEntity{ID: 1, Name: "first", Status: "new"}, svc.GetByID(1).Value())
+	assert.Equal(t, &testEntity{ID: 1, Name: "first", Status: "new"}, svc.GetByID(1).Value())
+	assert.Equal(t, 1, counting.Reads())
+	assert.Equal(t, crud.NotFound, svc.GetByID(2).State())
+	assert.Equal(t, crud.NotFound, svc.GetByID(2).State())
+	assert.Equal(t, 3, counting.Reads())
+
+	// Lists are cached by the full request
+	request := &crud.DataSetRequest{PageSize: 10, PageNumber: 1, SortColumn: "id", SortDirection: "asc", Filters: map[string]string{"status": "new"}}
+	dataSet := svc.GetAll(request).Value().(*crud.DataSet)
+	assert.Equal(t, []interface{}{&testEntity{ID: 1, Name: "first", Status: "new"}}, dataSet.Items)
+	assert.Equal(t, dataSet, svc.GetAll(request).Value())
+	assert.Equal(t, 4, counting.Reads())
+	svc.GetAll(&crud.DataSetRequest{PageSize: 10, PageNumber: 1, SortColumn: "id", SortDirection: "asc", Filters: map[string]string{"status": "old"}})
+	assert.Equal(t, 5, counting.Reads())
+
+	// Writes invalidate
+	svc.Update(1, &testEntity{Name: "updated"})
+	assert.Equal(t, "updated", svc.GetByID(1).Value().(*testEntity).Name)
+	assert.Len(t, svc.GetAll(request).Value().(*crud.DataSet).Items, 1)
+	assert.Equal(t, 7, counting.Reads())
+	svc.Add(&testEntity{Name: "second"})
+	assert.Len(t, svc.GetAll(request).Value().(*crud.DataSet).Items, 2)
+	svc.Delete(1)
+	assert.Equal(t, crud.NotFound, svc.GetByID(1).State())
+	assert.Equal(t, 9, counting.Reads())
+}
+
+func TestCachingService_MemoryCacheStore(t *testing.T) {
+	testCachingService(t, crud.NewMemoryCacheStore(100))
+}
+
+func TestCachingService_RedisCacheStore(t *testing.T) {
+	server := newRedisStandIn(t)
+	defer server.Close()
+
+	store := crud.NewRedisCacheStore(server.Addr().String(), time.Second)
+	defer store.Close()
+	testCachingService(t, store)
+}
+
+func TestCachingService_TTL(t *testing.T) {
+	now := time.Now()
+	store := crud.NewMemoryCacheStore(100)
+	store.Now = func() time.Time { return now }
+	counting := &readCountingService{Service: newMemoryService()}
+	svc := crud.NewCachingService(counting, store, "things", time.Minute, newTestEntity)
+	svc.Add(&testEntity{Name: "first"})
+
+	svc.GetByID(1)
+	now = now.Add(59 * time.Second)
+	svc.GetByID(1)
+	assert.Equal(t, 1, counting.Reads())
+	now = now.Add(time.Second)
+	svc.GetByID(1)
+	assert.Equal(t, 2, counting.Reads())
+}
+
+func TestCachingService_FailingStore(t *testing.T) {
+	store := crud.NewRedisCacheStore("127.0.0.1:1", 100*time.Millisecond)
+	counting := &readCountingService{Service: newMemoryService()}
+	svc := crud.NewCachingService(counting, store, "things", time.Minute, newTestEntity)
+	svc.Add(&testEntity{Name: "first"})
+
+	assert.Equal(t, crud.Ok, svc.GetByID(1).State())
+	assert.Equal(t, crud.Ok, svc.GetByID(1).State())
+	assert.Equal(t, 2, counting.Reads())
+}
+
+// asyncUpdatingService updates entities asynchronously, once released
+type asyncUpdatingService struct {
+	*memoryService
+	tracker *crud.AsyncOperationTracker
+	release chan struct{}
+}
+
+func (s *asyncUpdatingService) Update(id crud.EntityKey, entity crud.Entity) crud.OperationResult {
+	return s.tracker.Start("things", func() crud.OperationResult {
+		<-s.release
+		return s.memoryService.Update(id, entity)
+	})
+}
+
+func TestCachingService_InvalidatesWhenAsyncOperationCompletes(t *testing.T) {
+	async := &asyncUpdatingService{memoryService: newMemoryService(), tracker: crud.NewAsyncOperationTracker("/operations", time.Minute), release: make(chan struct{})}
+	svc := crud.NewCachingService(async, crud.NewMemoryCacheStore(100), "things", time.Minute, newTestEntity)
+	svc.Add(&testEntity{Name: "first"})
+
+	result := svc.Update(1, &testEntity{Name: "updated"})
+	assert.Equal(t, crud.Accepted, result.State())
+	// Cached while the update is still in progress
+	assert.Equal(t, "first", svc.GetByID(1).Value().(*testEntity).Name)
+
+	close(async.release)
+	<-result.Value().(*crud.AsyncOperation).Done()
+	waitFor(t, func() bool { return svc.GetByID(1).Value().(*testEntity).Name == "updated" })
+}
+
+func TestMemoryCacheStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	store := crud.NewMemoryCacheStore(2)
+	store.Set("a", []byte("1"), 0)
+	store.Set("b", []byte("2"), 0)
+	store.Get("a")
+	store.Set("c", []byte("3"), 0)
+
+	assert.Equal(t, 2, store.Len())
+	_, ok, _ := store.Get("b")
+	assert.False(t, ok)
+	value, ok, _ := store.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+
+	store.Delete("a")
+	_, ok, _ = store.Get("a")
+	assert.False(t, ok)
+}
+
+// redisStandIn is a server understanding just the commands of RedisCacheStore
+type redisStandIn struct {
+	net.Listener
+	mutex  sync.Mutex
+	values map[string]string
+	expiry map[string]time.Time
+}
+
+func newRedisStandIn(t *testing.T) *redisStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("Can't listen: " + err.Error())
+	}
+	server := &redisStandIn{Listener: listener, values: make(map[string]string), expiry: make(map[string]time.Time)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *redisStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		io.WriteString(conn, s.execute(args))
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, count)
+	for i := range args {
+		line, err = reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		length, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:length])
+	}
+	return args, nil
+}
+
+func (s *redisStandIn) execute(args []string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		value, ok := s.values[args[1]]
+		if expiresAt, expires := s.expiry[args[1]]; !ok || expires && !time.Now().Before(expiresAt) {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "SET":
+		s.values[args[1]] = args[2]
+		delete(s.expiry, args[1])
+		if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+			milliseconds, _ := strconv.Atoi(args[4])
+			s.expiry[args[1]] = time.Now().Add(time.Duration(milliseconds) * time.Millisecond)
+		}
+		return "+OK\r\n"
+	case "DEL":
+		_, ok := s.values[args[1]]
+		delete(s.values, args[1])
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	}
+	return "-ERR unknown command\r\n"
+}
@@ -0,0 +1,261 @@
+package crud
+
+import (
+	"sync"
+	"time"
+)
+
+// ChangeType describes what happened to an entity
+type ChangeType string
+
+const (
+	// ChangeCreated is published when an entity is added
+	ChangeCreated ChangeType = "created"
+	// ChangeUpdated is published when an entity is updated
+	ChangeUpdated ChangeType = "updated"
+	// ChangeDeleted is published when an entity is deleted
+	ChangeDeleted ChangeType = "deleted"
+	// ChangeReset is sent to a resuming subscriber, instead of the events it missed, when those are no longer in the
+	// backlog. Its ID is that of the last missed event; the subscriber should reload the entities it keeps track of.
+	ChangeReset ChangeType = "reset"
+)
+
+// ChangeEvent describes a single change to an entity of a resource
+type ChangeEvent struct {
+	// ID is assigned by the ChangeEventBus, and increases with every published event.
+	ID uint64 `json:"id"`
+	// Type is what happened to the entity.
+	Type ChangeType `json:"type"`
+	// Resource is the name of the resource the entity belongs to.
+	Resource string `json:"resource"`
+	// Key is the ID of the entity.
+	Key EntityKey `json:"key"`
+	// Entity is the state of the entity after the change. For deletes, it's the last known state, if available.
+	Entity interface{} `json:"entity,omitempty"`
+	// Timestamp is the moment the event was published.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ChangeEventBus distributes change events to subscribers. It keeps a backlog of the most recent events, so that
+// subscribers can resume after reconnecting.
+//
+// Subscribers that don't keep up are disconnected rather than slowing down the publishers; they can resume using
+// the ID of the last event they received.
+type ChangeEventBus struct {
+	// Now returns the current time. Replaceable for testing purposes; defaults to time.Now.
+	Now func() time.Time
+
+	mutex       sync.Mutex
+	lastID      uint64
+	backlog     []ChangeEvent
+	backlogSize int
+	subscribers map[chan ChangeEvent]func(ChangeEvent) bool
+}
+
+// ChangeEventBufferSize is the number of events buffered per subscriber before it is considered too slow.
+var ChangeEventBufferSize = 64
+
+// NewChangeEventBus creates a bus that keeps the given amount of events for resuming subscribers.
+func NewChangeEventBus(backlogSize int) *ChangeEventBus {
+	return &ChangeEventBus{
+		Now:         time.Now,
+		backlog:     make([]ChangeEvent, 0, backlogSize),
+		backlogSize: backlogSize,
+		subscribers: make(map[chan ChangeEvent]func(ChangeEvent) bool),
+	}
+}
+
+// Publish assigns an ID and timestamp to the event, and delivers it to all interested subscribers. The published
+// event is returned.
+func (b *ChangeEventBus) Publish(event ChangeEvent) ChangeEvent {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.lastID++
+	event.ID = b.lastID
+	event.Timestamp = b.Now()
+
+	if b.backlogSize > 0 {
+		if len(b.backlog) == b.backlogSize {
+			b.backlog = append(b.backlog[:0], b.backlog[1:]...)
+		}
+		b.backlog = append(b.backlog, event)
+	}
+
+	for events, filter := range b.subscribers {
+		if filter != nil && !filter(event) {
+			continue
+		}
+		select {
+		case events <- event:
+		default:
+			// Too slow; drop it, it can resume from its last event
+			delete(b.subscribers, events)
+			close(events)
+		}
+	}
+	return event
+}
+
+// Subscribe returns a channel that receives all events published after the event with ID afterID, for which the
+// filter returns true. A nil filter accepts all events; an afterID of zero only receives new events. If some of the
+// events after afterID are no longer in the backlog, the channel receives a ChangeReset event first.
+//
+// The returned function must be called to unsubscribe. The channel is closed once unsubscribed, or when the
+// subscriber didn't keep up.
+func (b *ChangeEventBus) Subscribe(afterID uint64, filter func(ChangeEvent) bool) (<-chan ChangeEvent, func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	replay := make([]ChangeEvent, 0)
+	if afterID > 0 {
+		if missed, ok := b.missedID(afterID); ok {
+			replay = append(replay, ChangeEvent{ID: missed, Type: ChangeReset, Timestamp: b.Now()})
+			afterID = missed
+		}
+		for _, event := range b.backlog {
+			if event.ID > afterID && (filter == nil || filter(event)) {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	events := make(chan ChangeEvent, len(replay)+ChangeEventBufferSize)
+	for _, event := range replay {
+		events <- event
+	}
+	b.subscribers[events] = filter
+
+	unsubscribe := func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		if _, ok := b.subscribers[events]; ok {
+			delete(b.subscribers, events)
+			close(events)
+		}
+	}
+	return events, unsubscribe
+}
+
+// missedID tells whether events after afterID are no longer in the backlog, and returns the ID of the last one missed.
+// IDs beyond the last published one were issued by an earlier bus, so all events were missed. The caller holds the lock.
+func (b *ChangeEventBus) missedID(afterID uint64) (uint64, bool) {
+	if afterID > b.lastID {
+		return b.lastID, true
+	}
+	oldest := b.lastID + 1
+	if len(b.backlog) > 0 {
+		oldest = b.backlog[0].ID
+	}
+	return oldest - 1, afterID+1 < oldest
+}
+
+// ChangeFeedService is a Service decorator that publishes a ChangeEvent for every successful Add, Update and Delete. As
+// a ServiceDecorator, it forwards the optional interfaces of the decorated Service.
+//
+// Events can only be published for Adds that return the ID of the new entity (see CreatedWithIDResult).
+type ChangeFeedService struct {
+	Service
+
+	bus          *ChangeEventBus
+	resourceName string
+}
+
+// NewChangeFeedService wraps the given Service with one that publishes its changes to the bus.
+func NewChangeFeedService(svc Service, bus *ChangeEventBus, resourceName string) *ChangeFeedService {
+	return &ChangeFeedService{
+		Service:      svc,
+		bus:          bus,
+		resourceName: resourceName,
+	}
+}
+
+// EntityPipeline uses the pipeline of the decorated Service.
+func (c *ChangeFeedService) EntityPipeline() *EntityPipeline {
+	return pipelineFor(c.Service)
+}
+
+// ResourcePolicy uses the policy of the decorated Service.
+func (c *ChangeFeedService) ResourcePolicy() *ResourcePolicy {
+	return policyFor(c.Service)
+}
+
+// Decorated returns the decorated Service.
+func (c *ChangeFeedService) Decorated() Service {
+	return c.Service
+}
+
+// GetAllAsOf gets the entities as they were at the given moment from the decorated Service, if it's an AsOfService.
+func (c *ChangeFeedService) GetAllAsOf(request *DataSetRequest, asOf time.Time) OperationResult {
+	return getAllAsOf(c.Service, request, asOf)
+}
+
+// GetByIDAsOf gets the entity as it was at the given moment from the decorated Service, if it's an AsOfService.
+func (c *ChangeFeedService) GetByIDAsOf(id EntityKey, asOf time.Time) OperationResult {
+	return getByIDAsOf(c.Service, id, asOf)
+}
+
+// Aggregate aggregates the entities using the decorated Service, if it's an Aggregator.
+func (c *ChangeFeedService) Aggregate(request *AggregationRequest) OperationResult {
+	return aggregate(c.Service, request)
+}
+
+// GetDistinctValues gets the distinct values of a column from the decorated Service, if it's a DistinctValuesService
+// or an Aggregator.
+func (c *ChangeFeedService) GetDistinctValues(request *DistinctValuesRequest) OperationResult {
+	return distinctValuesFor(c.Service, request)
+}
+
+// GetByIDs gets the entities with the given keys from the decorated Service, in a single call if it's a BatchGetter.
+func (c *ChangeFeedService) GetByIDs(ids []EntityKey) OperationResult {
+	return getByIDs(c.Service, ids)
+}
+
+// Add will add the given entity, and publish a created event if the new ID is known.
+func (c *ChangeFeedService) Add(entity Entity) OperationResult {
+	result := c.Service.Add(entity)
+	if isSuccess(result) && result.Value() != nil {
+		c.publish(ChangeCreated, result.Value(), readBack(c.Service, result.Value(), entity))
+	}
+	return result
+}
+
+// Update will update an existing entity, and publish an updated event.
+func (c *ChangeFeedService) Update(id EntityKey, entity Entity) OperationResult {
+	result := c.Service.Update(id, entity)
+	if isSuccess(result) {
+		var updated interface{} = entity
+		if result.Value() != nil {
+			updated = result.Value()
+		}
+		c.publish(ChangeUpdated, id, updated)
+	}
+	return result
+}
+
+// Delete will delete the entity, and publish a deleted event carrying its last known state.
+func (c *ChangeFeedService) Delete(id EntityKey) OperationResult {
+	last := readBack(c.Service, id, nil)
+	result := c.Service.Delete(id)
+	if isSuccess(result) {
+		c.publish(ChangeDeleted, id, last)
+	}
+	return result
+}
+
+func (c *ChangeFeedService) publish(changeType ChangeType, id EntityKey, entity interface{}) {
+	var snapshot interface{}
+	if entity != nil {
+		// Subscribers receive the JSON representation, so later changes to the entity can't alter the event
+		doc, err := toJSONDocument(entity)
+		if err == nil {
+			snapshot = doc
+		}
+	}
+	c.bus.Publish(ChangeEvent{
+		Type:     changeType,
+		Resource: c.resourceName,
+		Key:      id,
+		Entity:   snapshot,
+	})
+}
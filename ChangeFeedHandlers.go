@@ -0,0 +1,131 @@
+package crud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+)
+
+// ChangeFeedHeartbeatInterval is how often a comment is sent on idle change feeds, to keep proxies from closing the connection.
+var ChangeFeedHeartbeatInterval = 15 * time.Second
+
+// CreateCrudHandlerChangeFeed is used to stream the changes of a resource as Server-Sent Events. Route it as e.g. /{resource}/_changes.
+//
+// Clients resume by sending the Last-Event-ID header (or the lastEventId query parameter). If the events since then are no longer
+// kept, a reset event is sent first, telling the client to reload the resource. The stream can be narrowed down with the query
+// parameters 'types' (comma separated list of change types), 'id' (a single entity) and 'filters' (same format as for the list handler,
+// applied to the entity of each event).
+var CreateCrudHandlerChangeFeed = func(ctx servicefoundation.AppContext, bus *ChangeEventBus, resourceName string, recoverFunc RecoverFunc) http.HandlerFunc {
+	logger := ctx.Logger()
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer recoverFunc("crudHandler", ctx, w, r)
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			WriteOperationResult(w, r, ErrorResult(errors.New("Streaming is not supported by the response writer")))
+			return
+		}
+
+		lastEventID, err := extractLastEventID(r)
+		if err != nil {
+			WriteOperationResult(w, r, ValidationFailedResult(err))
+			return
+		}
+		filter := createChangeEventFilter(r, resourceName)
+
+		logger.Debug("CreateCrudHandlerChangeFeed", fmt.Sprintf("Interpreted as ChangeFeed command. Last event ID: %v", lastEventID))
+		events, unsubscribe := bus.Subscribe(lastEventID, filter)
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(ChangeFeedHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+				flusher.Flush()
+			case event, ok := <-events:
+				if !ok {
+					// Unsubscribed by the bus; the client will reconnect and resume
+					return
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					logger.Error("CreateCrudHandlerChangeFeed", fmt.Sprintf("Failed to encode change event %v: %v", event.ID, err))
+					continue
+				}
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func extractLastEventID(r *http.Request) (uint64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("lastEventId")
+	}
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, errors.New("Invalid last event ID: " + value)
+	}
+	return id, nil
+}
+
+func createChangeEventFilter(r *http.Request, resourceName string) func(ChangeEvent) bool {
+	query := r.URL.Query()
+
+	var types []ChangeType
+	if value := query.Get("types"); value != "" {
+		for _, t := range strings.Split(value, ",") {
+			types = append(types, ChangeType(strings.ToLower(strings.TrimSpace(t))))
+		}
+	}
+	id := query.Get("id")
+	filters := ExtractDataSetRequestFromURI(r).Filters
+
+	return func(event ChangeEvent) bool {
+		if event.Resource != resourceName {
+			return false
+		}
+		if id != "" && fmt.Sprint(event.Key) != id {
+			return false
+		}
+		if len(types) > 0 {
+			found := false
+			for _, t := range types {
+				if t == event.Type {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		if len(filters) > 0 {
+			fields, _ := event.Entity.(map[string]interface{})
+			return matchesFilters(fields, filters)
+		}
+		return true
+	}
+}
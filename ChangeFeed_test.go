@@ -0,0 +1,141 @@
+package crud_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeFeedService_PublishesChanges(t *testing.T) {
+	bus := crud.NewChangeEventBus(10)
+	svc := crud.NewChangeFeedService(newMemoryService(), bus, "things")
+	events, unsubscribe := bus.Subscribe(0, nil)
+	defer unsubscribe()
+
+	svc.Add(&testEntity{Name: "first"})
+	svc.Update(1, &testEntity{Name: "renamed"})
+	svc.Delete(1)
+	svc.Delete(1)
+
+	created := <-events
+	assert.Equal(t, crud.ChangeCreated, created.Type)
+	assert.Equal(t, uint64(1), created.ID)
+	assert.Equal(t, "things", created.Resource)
+	assert.Equal(t, 1, created.Key)
+	assert.Equal(t, float64(1), created.Entity.(map[string]interface{})["id"])
+
+	updated := <-events
+	assert.Equal(t, crud.ChangeUpdated, updated.Type)
+	assert.Equal(t, "renamed", updated.Entity.(map[string]interface{})["name"])
+
+	deleted := <-events
+	assert.Equal(t, crud.ChangeDeleted, deleted.Type)
+	assert.Equal(t, "renamed", deleted.Entity.(map[string]interface{})["name"])
+
+	// The second delete failed, so nothing was published
+	assert.Equal(t, 0, len(events))
+}
+
+func TestChangeEventBus_ResumesFromBacklog(t *testing.T) {
+	bus := crud.NewChangeEventBus(2)
+	for i := 0; i < 4; i++ {
+		bus.Publish(crud.ChangeEvent{Type: crud.ChangeUpdated, Resource: "things", Key: i})
+	}
+
+	events, unsubscribe := bus.Subscribe(2, nil)
+	defer unsubscribe()
+
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, uint64(3), (<-events).ID)
+	assert.Equal(t, uint64(4), (<-events).ID)
+}
+
+func TestChangeEventBus_ResetsWhenEventsWereMissed(t *testing.T) {
+	bus := crud.NewChangeEventBus(2)
+	for i := 0; i < 4; i++ {
+		bus.Publish(crud.ChangeEvent{Type: crud.ChangeUpdated, Resource: "things", Key: i})
+	}
+
+	// Only the last two events are kept, so the second one was missed
+	events, unsubscribe := bus.Subscribe(1, func(event crud.ChangeEvent) bool { return event.Resource == "things" })
+	defer unsubscribe()
+	assert.Equal(t, 3, len(events))
+	reset := <-events
+	assert.Equal(t, crud.ChangeReset, reset.Type)
+	assert.Equal(t, uint64(2), reset.ID)
+	assert.Equal(t, uint64(3), (<-events).ID)
+
+	// IDs beyond the last event were issued by an earlier bus
+	events, unsubscribe = bus.Subscribe(42, nil)
+	defer unsubscribe()
+	if assert.Equal(t, 1, len(events)) {
+		reset = <-events
+		assert.Equal(t, crud.ChangeReset, reset.Type)
+		assert.Equal(t, uint64(4), reset.ID)
+	}
+}
+
+func TestChangeEventBus_DropsSlowSubscribers(t *testing.T) {
+	bus := crud.NewChangeEventBus(0)
+	events, unsubscribe := bus.Subscribe(0, nil)
+	defer unsubscribe()
+
+	for i := 0; i <= crud.ChangeEventBufferSize; i++ {
+		bus.Publish(crud.ChangeEvent{Type: crud.ChangeUpdated, Resource: "things", Key: i})
+	}
+
+	received := 0
+	for range events {
+		received++
+	}
+	assert.Equal(t, crud.ChangeEventBufferSize, received)
+}
+
+func TestCreateCrudHandlerChangeFeed_StreamsEvents(t *testing.T) {
+	bus := crud.NewChangeEventBus(10)
+	bus.Publish(crud.ChangeEvent{Type: crud.ChangeCreated, Resource: "things", Key: 1, Entity: map[string]interface{}{"status": "new"}})
+	bus.Publish(crud.ChangeEvent{Type: crud.ChangeCreated, Resource: "others", Key: 1})
+	bus.Publish(crud.ChangeEvent{Type: crud.ChangeCreated, Resource: "things", Key: 2, Entity: map[string]interface{}{"status": "new"}})
+	bus.Publish(crud.ChangeEvent{Type: crud.ChangeUpdated, Resource: "things", Key: 2, Entity: map[string]interface{}{"status": "active"}})
+
+	server := httptest.NewServer(crud.CreateCrudHandlerChangeFeed(newTestContext(), bus, "things", noRecovery))
+	defer server.Close()
+
+	r, _ := http.NewRequest("GET", server.URL+`?filters={"status":"act"}`, nil)
+	r.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(r)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// Published after connecting, so it's delivered live
+	bus.Publish(crud.ChangeEvent{Type: crud.ChangeUpdated, Resource: "things", Key: 1, Entity: map[string]interface{}{"status": "active"}})
+
+	reader := bufio.NewReader(resp.Body)
+	ids := make([]string, 0)
+	for len(ids) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(line, "id: ") {
+			ids = append(ids, strings.TrimSpace(strings.TrimPrefix(line, "id: ")))
+		}
+	}
+	assert.Equal(t, []string{"4", "5"}, ids)
+}
+
+func TestCreateCrudHandlerChangeFeed_InvalidLastEventID(t *testing.T) {
+	bus := crud.NewChangeEventBus(10)
+	r, _ := http.NewRequest("GET", "/things/_changes?lastEventId=abc", nil)
+	w := httptest.NewRecorder()
+
+	crud.CreateCrudHandlerChangeFeed(newTestContext(), bus, "things", noRecovery)(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
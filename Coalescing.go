@@ -0,0 +1,147 @@
+package crud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CoalescingService is a Service decorator coalescing concurrent identical reads: while a GetByID or GetAll is in
+// flight, identical calls wait for it instead of calling the decorated Service, and share its OperationResult. Calls
+// are identical if they're for the same EntityKey, or for the same DataSetRequest, ignoring the case of the sort
+// direction. As the result is shared, callers shouldn't modify its value. As a ServiceDecorator, it forwards the
+// optional interfaces of the decorated Service without coalescing them.
+type CoalescingService struct {
+	Service
+
+	mutex sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// coalescedCall is a call in flight, and the calls waiting for it
+type coalescedCall struct {
+	done    chan struct{}
+	result  OperationResult
+	waiters int
+}
+
+// NewCoalescingService wraps the given Service with one coalescing concurrent identical reads.
+func NewCoalescingService(svc Service) *CoalescingService {
+	return &CoalescingService{Service: svc, calls: make(map[string]*coalescedCall)}
+}
+
+// EntityPipeline uses the pipeline of the decorated Service.
+func (c *CoalescingService) EntityPipeline() *EntityPipeline {
+	return pipelineFor(c.Service)
+}
+
+// ResourcePolicy uses the policy of the decorated Service.
+func (c *CoalescingService) ResourcePolicy() *ResourcePolicy {
+	return policyFor(c.Service)
+}
+
+// Decorated returns the decorated Service.
+func (c *CoalescingService) Decorated() Service {
+	return c.Service
+}
+
+// GetAllAsOf gets the entities as they were at the given moment from the decorated Service, if it's an AsOfService.
+func (c *CoalescingService) GetAllAsOf(request *DataSetRequest, asOf time.Time) OperationResult {
+	return getAllAsOf(c.Service, request, asOf)
+}
+
+// GetByIDAsOf gets the entity as it was at the given moment from the decorated Service, if it's an AsOfService.
+func (c *CoalescingService) GetByIDAsOf(id EntityKey, asOf time.Time) OperationResult {
+	return getByIDAsOf(c.Service, id, asOf)
+}
+
+// Aggregate aggregates the entities using the decorated Service, if it's an Aggregator.
+func (c *CoalescingService) Aggregate(request *AggregationRequest) OperationResult {
+	return aggregate(c.Service, request)
+}
+
+// GetDistinctValues gets the distinct values of a column from the decorated Service, if it's a DistinctValuesService
+// or an Aggregator.
+func (c *CoalescingService) GetDistinctValues(request *DistinctValuesRequest) OperationResult {
+	return distinctValuesFor(c.Service, request)
+}
+
+// GetByIDs gets the entities with the given keys from the decorated Service, in a single call if it's a BatchGetter.
+func (c *CoalescingService) GetByIDs(ids []EntityKey) OperationResult {
+	return getByIDs(c.Service, ids)
+}
+
+// GetAll gets the entities, or waits for an identical call in flight
+func (c *CoalescingService) GetAll(request *DataSetRequest) OperationResult {
+	key, err := dataSetRequestKey(request)
+	if err != nil {
+		return c.Service.GetAll(request)
+	}
+	return c.do("list:"+key, func() OperationResult {
+		return c.Service.GetAll(request)
+	})
+}
+
+// GetByID gets the entity, or waits for an identical call in flight
+func (c *CoalescingService) GetByID(id EntityKey) OperationResult {
+	return c.do("id:"+fmt.Sprint(id), func() OperationResult {
+		return c.Service.GetByID(id)
+	})
+}
+
+// Waiting returns the number of calls waiting for an identical call in flight.
+func (c *CoalescingService) Waiting() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	waiting := 0
+	for _, inFlight := range c.calls {
+		waiting += inFlight.waiters
+	}
+	return waiting
+}
+
+// do performs the call, unless an identical one is in flight, in which case its result is awaited. If the call panics,
+// the waiting calls get an Error result, while the panic is passed on to the caller.
+func (c *CoalescingService) do(key string, call func() OperationResult) OperationResult {
+	c.mutex.Lock()
+	if inFlight, ok := c.calls[key]; ok {
+		inFlight.waiters++
+		c.mutex.Unlock()
+		<-inFlight.done
+		return inFlight.result
+	}
+	inFlight := &coalescedCall{done: make(chan struct{})}
+	c.calls[key] = inFlight
+	c.mutex.Unlock()
+
+	completed := false
+	defer func() {
+		if !completed {
+			inFlight.result = ErrorResult(errors.New("The coalesced call failed"))
+		}
+		c.mutex.Lock()
+		delete(c.calls, key)
+		c.mutex.Unlock()
+		close(inFlight.done)
+	}()
+	inFlight.result = call()
+	completed = true
+	return inFlight.result
+}
+
+// dataSetRequestKey returns a key identifying a DataSetRequest. Filters are sorted by column when encoded.
+func dataSetRequestKey(request *DataSetRequest) (string, error) {
+	if request == nil {
+		return "", nil
+	}
+	normalized := *request
+	normalized.SortDirection = strings.ToLower(normalized.SortDirection)
+	if len(normalized.Filters) == 0 {
+		normalized.Filters = nil
+	}
+	data, err := json.Marshal(&normalized)
+	return string(data), err
+}
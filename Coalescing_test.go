@@ -0,0 +1,140 @@
+package crud_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// gatedService holds reads until the gate is opened
+type gatedService struct {
+	*readCountingService
+	gate chan struct{}
+}
+
+func newGatedService() *gatedService {
+	svc := &gatedService{readCountingService: &readCountingService{Service: newMemoryService()}, gate: make(chan struct{})}
+	svc.Add(&testEntity{Name: "first"})
+	return svc
+}
+
+func (g *gatedService) GetAll(request *crud.DataSetRequest) crud.OperationResult {
+	<-g.gate
+	return g.readCountingService.GetAll(request)
+}
+
+func (g *gatedService) GetByID(id crud.EntityKey) crud.OperationResult {
+	<-g.gate
+	return g.readCountingService.GetByID(id)
+}
+
+// readConcurrently performs the reads concurrently, opening the gate once all but the distinct ones wait
+func readConcurrently(t *testing.T, svc *crud.CoalescingService, gated *gatedService, distinct int, reads ...func() crud.OperationResult) []crud.OperationResult {
+	results := make([]crud.OperationResult, len(reads))
+	var wg sync.WaitGroup
+	for i, read := range reads {
+		wg.Add(1)
+		go func(i int, read func() crud.OperationResult) {
+			defer wg.Done()
+			results[i] = read()
+		}(i, read)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for svc.Waiting() < len(reads)-distinct && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, len(reads)-distinct, svc.Waiting())
+	close(gated.gate)
+	wg.Wait()
+	return results
+}
+
+func TestCoalescingService_GetByID(t *testing.T) {
+	gated := newGatedService()
+	svc := crud.NewCoalescingService(gated)
+
+	reads := make([]func() crud.OperationResult, 0)
+	for i := 0; i < 10; i++ {
+		reads = append(reads, func() crud.OperationResult { return svc.GetByID(1) })
+	}
+	reads = append(reads, func() crud.OperationResult { return svc.GetByID("2") })
+	results := readConcurrently(t, svc, gated, 2, reads...)
+
+	assert.Equal(t, 2, gated.Reads())
+	for _, result := range results[:10] {
+		assert.Equal(t, crud.Ok, result.State())
+		assert.True(t, result.Value() == results[0].Value())
+	}
+	assert.Equal(t, crud.NotFound, results[10].State())
+	assert.Equal(t, 0, svc.Waiting())
+
+	// Once completed, calls aren't coalesced anymore
+	svc.GetByID(1)
+	assert.Equal(t, 3, gated.Reads())
+}
+
+func TestCoalescingService_GetAll(t *testing.T) {
+	gated := newGatedService()
+	svc := crud.NewCoalescingService(gated)
+
+	request := func(direction string, filters map[string]string) func() crud.OperationResult {
+		return func() crud.OperationResult {
+			return svc.GetAll(&crud.DataSetRequest{PageSize: 10, PageNumber: 1, SortColumn: "id", SortDirection: direction, Filters: filters})
+		}
+	}
+	results := readConcurrently(t, svc, gated, 2,
+		request("asc", nil),
+		request("Asc", map[string]string{}),
+		request("ASC", nil),
+		request("asc", map[string]string{"name": "first"}),
+		request("asc", map[string]string{"name": "first"}),
+	)
+
+	assert.Equal(t, 2, gated.Reads())
+	assert.True(t, results[0].Value() == results[1].Value())
+	assert.True(t, results[0].Value() == results[2].Value())
+	assert.True(t, results[3].Value() == results[4].Value())
+	assert.False(t, results[0].Value() == results[3].Value())
+}
+
+// panickingService panics once the gate is opened
+type panickingService struct {
+	*gatedService
+}
+
+func (p *panickingService) GetByID(id crud.EntityKey) crud.OperationResult {
+	<-p.gate
+	panic("backing store unavailable")
+}
+
+func TestCoalescingService_Panic(t *testing.T) {
+	gated := &panickingService{newGatedService()}
+	svc := crud.NewCoalescingService(gated)
+
+	var mutex sync.Mutex
+	panics := 0
+	read := func() (result crud.OperationResult) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				assert.Equal(t, "backing store unavailable", recovered)
+				mutex.Lock()
+				panics++
+				mutex.Unlock()
+			}
+		}()
+		return svc.GetByID(1)
+	}
+	results := readConcurrently(t, svc, gated.gatedService, 1, read, read)
+
+	// The panic is passed on to the call reaching the Service, while the waiting call fails
+	assert.Equal(t, 1, panics)
+	for _, result := range results {
+		if result != nil {
+			assert.Equal(t, crud.Error, result.State())
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package crud
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// entityFields flattens an entity into its JSON representation, so that columns can be addressed by their JSON names.
+// Returns nil if the entity can't be represented as a JSON object.
+func entityFields(entity interface{}) map[string]interface{} {
+	if fields, ok := entity.(map[string]interface{}); ok {
+		return fields
+	}
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	return fields
+}
+
+// fieldValue looks up a column of a flattened entity. The exact name is preferred, but a case-insensitive
+// match is accepted as well, since the query string is case-insensitive too.
+func fieldValue(fields map[string]interface{}, column string) (interface{}, bool) {
+	if v, ok := fields[column]; ok {
+		return v, true
+	}
+	for k, v := range fields {
+		if strings.EqualFold(k, column) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// matchesFilters applies the filters of a request to a flattened entity. A filter matches when the column value
+// contains the filter value, ignoring case. Numbers are formatted like keys, without exponent.
+func matchesFilters(fields map[string]interface{}, filters map[string]string) bool {
+	for column, filter := range filters {
+		v, ok := fieldValue(fields, column)
+		if !ok || v == nil {
+			return false
+		}
+		if !strings.Contains(strings.ToLower(formatKey(v)), strings.ToLower(filter)) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareValues orders two JSON values. Nil sorts first, numbers are compared numerically and anything
+// else is compared on its string representation.
+func compareValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	af, aIsNumber := a.(float64)
+	bf, bIsNumber := b.(float64)
+	if aIsNumber && bIsNumber {
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// applyDataSetRequest filters, sorts and pages an in-memory collection of entities according to the request.
+// It is meant for Service implementations (and decorators) that hold their data in memory.
+func applyDataSetRequest(items []interface{}, request *DataSetRequest) *DataSet {
+	type row struct {
+		item   interface{}
+		fields map[string]interface{}
+	}
+
+	rows := make([]row, 0, len(items))
+	for _, item := range items {
+		fields := entityFields(item)
+		if !matchesFilters(fields, request.Filters) {
+			continue
+		}
+		rows = append(rows, row{item: item, fields: fields})
+	}
+
+	if request.SortColumn != "" {
+		descending := strings.EqualFold(request.SortDirection, string(Desc))
+		sort.SliceStable(rows, func(i, j int) bool {
+			a, _ := fieldValue(rows[i].fields, request.SortColumn)
+			b, _ := fieldValue(rows[j].fields, request.SortColumn)
+			if descending {
+				return compareValues(a, b) > 0
+			}
+			return compareValues(a, b) < 0
+		})
+	}
+
+	pageSize := request.PageSize
+	if pageSize < 1 {
+		pageSize = 1
+	}
+	pageNumber := request.PageNumber
+	if pageNumber < 1 {
+		pageNumber = 1
+	}
+
+	// The page size comes from the client; don't allocate more than there is to return
+	capacity := pageSize
+	if capacity > len(rows) {
+		capacity = len(rows)
+	}
+	result := &DataSet{
+		Items: make([]interface{}, 0, capacity),
+		PagingInfo: PagingInfo{
+			SupportsPaging:       true,
+			DoesKnowTotalRecords: true,
+			PageSize:             pageSize,
+			PageNumber:           pageNumber,
+			TotalRecordsCount:    len(rows),
+		},
+	}
+	if pageNumber-1 > len(rows)/pageSize {
+		// Way past the end; also prevents overflowing the offset below
+		return result
+	}
+	for i := (pageNumber - 1) * pageSize; i < len(rows) && i < pageNumber*pageSize; i++ {
+		result.Items = append(result.Items, rows[i].item)
+	}
+	return result
+}
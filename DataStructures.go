@@ -1,5 +1,7 @@
 package crud
 
+import "strconv"
+
 // SortDirection describes how results are sorted
 type SortDirection string
 
@@ -29,8 +31,34 @@ const (
 	NotSupportedByResource State = 6
 	// Created is the same as OK, but used to signify that the entity was created
 	Created State = 7
+	// Accepted means that the operation was started, but hasn't completed yet. The value is the AsyncOperation that
+	// tracks it.
+	Accepted State = 8
 )
 
+// String returns the name of the state
+func (s State) String() string {
+	switch s {
+	case Ok:
+		return "Ok"
+	case ValidationFailed:
+		return "ValidationFailed"
+	case Error:
+		return "Error"
+	case NotFound:
+		return "NotFound"
+	case Conflict:
+		return "Conflict"
+	case NotSupportedByResource:
+		return "NotSupportedByResource"
+	case Created:
+		return "Created"
+	case Accepted:
+		return "Accepted"
+	}
+	return "State(" + strconv.Itoa(int(s)) + ")"
+}
+
 // PagingInfo is used to describe how results are being pages
 type PagingInfo struct {
 	// SupportsPaging indicates whether the datasource even supports paging. If false, it means that all the applicable
@@ -78,6 +106,15 @@ type DataSet struct {
 // ErrorDetails is used to communicate the details of an error back to the caller
 type ErrorDetails struct {
 	Message string `json:"message"`
+	// Details holds structured information about the error, if the error provides it (see DetailedError).
+	Details interface{} `json:"details,omitempty"`
+}
+
+// DetailedError can be implemented by errors that have structured information for the caller, on top of their message.
+type DetailedError interface {
+	error
+	// Details returns the information to pass to the caller in ErrorDetails.
+	Details() interface{}
 }
 
 // OperationResult is the result of a CRUD operation
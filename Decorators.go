@@ -0,0 +1,107 @@
+package crud
+
+import (
+	"fmt"
+	"time"
+)
+
+// ServiceDecorator is implemented by Service decorators forwarding the optional interfaces of the Service they
+// decorate: AsOfService, Aggregator, DistinctValuesService and BatchGetter. Since such a decorator implements them
+// whether the decorated Service does or not, the registry looks through it to tell what the resource supports, and the
+// forwarded calls return NotSupportedByResourceResult if the decorated Service doesn't implement them.
+type ServiceDecorator interface {
+	Service
+	// Decorated returns the decorated Service.
+	Decorated() Service
+}
+
+// optionalInterface identifies one of the optional interfaces of a Service.
+type optionalInterface int
+
+const (
+	asOfServiceInterface optionalInterface = iota
+	aggregatorInterface
+	distinctValuesServiceInterface
+	batchGetterInterface
+)
+
+// implementedBy tells whether the Service itself implements the interface.
+func (i optionalInterface) implementedBy(svc Service) bool {
+	var ok bool
+	switch i {
+	case asOfServiceInterface:
+		_, ok = svc.(AsOfService)
+	case aggregatorInterface:
+		_, ok = svc.(Aggregator)
+	case distinctValuesServiceInterface:
+		_, ok = svc.(DistinctValuesService)
+	case batchGetterInterface:
+		_, ok = svc.(BatchGetter)
+	}
+	return ok
+}
+
+// interfaceProvider is implemented by ServiceDecorators implementing some of the optional interfaces themselves, rather
+// than forwarding them, such as HistoryService for AsOfService.
+type interfaceProvider interface {
+	provides(i optionalInterface) bool
+}
+
+// implements tells whether the Service implements an optional interface, looking through ServiceDecorators.
+func implements(svc Service, i optionalInterface) bool {
+	for svc != nil {
+		decorator, ok := svc.(ServiceDecorator)
+		if !ok {
+			return i.implementedBy(svc)
+		}
+		if provider, ok := svc.(interfaceProvider); ok && provider.provides(i) {
+			return true
+		}
+		svc = decorator.Decorated()
+	}
+	return false
+}
+
+// getAllAsOf forwards GetAllAsOf to the Service, if it supports it.
+func getAllAsOf(svc Service, request *DataSetRequest, asOf time.Time) OperationResult {
+	if implements(svc, asOfServiceInterface) {
+		return svc.(AsOfService).GetAllAsOf(request, asOf)
+	}
+	return NotSupportedByResourceResult()
+}
+
+// getByIDAsOf forwards GetByIDAsOf to the Service, if it supports it.
+func getByIDAsOf(svc Service, id EntityKey, asOf time.Time) OperationResult {
+	if implements(svc, asOfServiceInterface) {
+		return svc.(AsOfService).GetByIDAsOf(id, asOf)
+	}
+	return NotSupportedByResourceResult()
+}
+
+// aggregate forwards Aggregate to the Service, if it supports it.
+func aggregate(svc Service, request *AggregationRequest) OperationResult {
+	if implements(svc, aggregatorInterface) {
+		return svc.(Aggregator).Aggregate(request)
+	}
+	return NotSupportedByResourceResult()
+}
+
+// getByIDs gets the entities with the given keys in a single call if the Service is a BatchGetter, or by calling
+// GetByID for every key otherwise. The value of the result is a map[string]interface{}, as for BatchGetter.
+func getByIDs(svc Service, ids []EntityKey) OperationResult {
+	if implements(svc, batchGetterInterface) {
+		return svc.(BatchGetter).GetByIDs(ids)
+	}
+	entities := make(map[string]interface{}, len(ids))
+	for _, id := range ids {
+		result := svc.GetByID(id)
+		switch result.State() {
+		case Ok:
+			entities[fmt.Sprint(id)] = result.Value()
+		case NotFound:
+		default:
+			return result
+		}
+	}
+	return OkResult(entities)
+}
@@ -0,0 +1,117 @@
+package crud_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// batchGettingService counts the calls to GetByIDs
+type batchGettingService struct {
+	*memoryService
+	batches int
+}
+
+func (s *batchGettingService) GetByIDs(ids []crud.EntityKey) crud.OperationResult {
+	s.batches++
+	entities := make(map[string]interface{}, len(ids))
+	for _, id := range ids {
+		if result := s.GetByID(id); result.State() == crud.Ok {
+			entities[toKey(id)] = result.Value()
+		}
+	}
+	return crud.OkResult(entities)
+}
+
+// decorators wrap a Service with each of the decorators forwarding optional interfaces
+var decorators = map[string]func(svc crud.Service) crud.Service{
+	"caching": func(svc crud.Service) crud.Service {
+		return crud.NewCachingService(svc, crud.NewMemoryCacheStore(100), "things", time.Minute, newTestEntity)
+	},
+	"coalescing": func(svc crud.Service) crud.Service {
+		return crud.NewCoalescingService(svc)
+	},
+	"metrics": func(svc crud.Service) crud.Service {
+		return crud.NewMetricsService(svc, crud.NewMetrics("test"), "things")
+	},
+	"change feed": func(svc crud.Service) crud.Service {
+		return crud.NewChangeFeedService(svc, crud.NewChangeEventBus(10), "things")
+	},
+	"hooks": func(svc crud.Service) crud.Service {
+		return crud.NewHookService(svc, nil, newTestEntity)
+	},
+	"history": func(svc crud.Service) crud.Service {
+		return crud.NewHistoryService(svc)
+	},
+}
+
+func TestServiceDecorators_ForwardAggregation(t *testing.T) {
+	for name, decorate := range decorators {
+		router := newDistinctRouter(decorate(crud.NewMemoryAggregator(newStatusService())), nil)
+
+		w := serve(router, "GET", "/things/_aggregate?groupBy=status", "")
+		assert.Equal(t, http.StatusOK, w.Code, name)
+		var result crud.AggregationResult
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &result), name)
+		assert.Len(t, result.Rows, 3, name)
+
+		w = serve(router, "GET", "/things/_distinct/status", "")
+		assert.Equal(t, http.StatusOK, w.Code, name)
+
+		// Decorated Services that don't aggregate don't get the routes
+		router = newDistinctRouter(decorate(newStatusService()), nil)
+		assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/things/_aggregate?groupBy=status", "").Code, name)
+		assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/things/_distinct/status", "").Code, name)
+	}
+}
+
+func TestServiceDecorators_ForwardAsOf(t *testing.T) {
+	for name, decorate := range decorators {
+		if name == "history" {
+			// Answers AsOf requests itself
+			continue
+		}
+		history := crud.NewHistoryService(newMemoryService())
+		now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+		history.Now = func() time.Time { return now }
+		history.Add(&testEntity{Name: "first"})
+		now = now.Add(time.Hour)
+		history.Update(1, &testEntity{Name: "second"})
+		svc := decorate(history)
+
+		result := svc.(crud.AsOfService).GetByIDAsOf(1, now.Add(-time.Minute))
+		assert.Equal(t, crud.Ok, result.State(), name)
+		assert.Equal(t, "first", result.Value().(map[string]interface{})["name"], name)
+
+		assert.Equal(t, crud.NotSupportedByResource, decorate(newMemoryService()).(crud.AsOfService).GetByIDAsOf(1, now).State(), name)
+	}
+}
+
+func TestServiceDecorators_ForwardBatchGetter(t *testing.T) {
+	for name, decorate := range decorators {
+		batching := &batchGettingService{memoryService: newMemoryService()}
+		batching.Add(&testEntity{Name: "first"})
+		registry := crud.NewRegistry(newTestContext(), crud.Recovery)
+		registry.Register(&crud.Resource{Name: "things", Service: decorate(batching), CreateFunc: newTestEntity})
+		registry.Register(&crud.Resource{
+			Name:       "bookings",
+			Service:    newMemoryService(),
+			CreateFunc: newTestEntity,
+			Relations:  []crud.Relation{{Name: "thing", Resource: "things", ForeignKey: "id"}},
+		})
+		router := mux.NewRouter()
+		registry.Route(router)
+		bookings, _ := registry.Resource("bookings")
+		bookings.Service.Add(&testEntity{Name: "booking"})
+
+		w := serve(router, "GET", "/bookings?expand=thing", "")
+		assert.Equal(t, http.StatusOK, w.Code, name)
+		assert.Contains(t, w.Body.String(), `"first"`, name)
+		assert.Equal(t, 1, batching.batches, name)
+	}
+}
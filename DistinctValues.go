@@ -0,0 +1,62 @@
+package crud
+
+import "fmt"
+
+// DistinctValue is a value occurring in a column, and the number of entities it occurs in
+type DistinctValue struct {
+	// Value is the value of the column. Nil for entities without a value.
+	Value interface{} `json:"value"`
+	// Count is the number of entities with this value.
+	Count int `json:"count"`
+}
+
+// DistinctValuesRequest asks for the distinct values of a column among the entities matching the filters
+type DistinctValuesRequest struct {
+	// Column is the column to get the distinct values of.
+	Column string `json:"column"`
+	// Filters select the entities, like the filters of a DataSetRequest.
+	Filters map[string]string `json:"filters"`
+}
+
+// DistinctValuesService can be implemented by a Service to return the distinct values of a column efficiently.
+// Resources implemented by an Aggregator get distinct values through aggregation instead.
+type DistinctValuesService interface {
+	// GetDistinctValues returns the distinct values of the column, ordered by value. The value of the result is a
+	// []DistinctValue.
+	GetDistinctValues(request *DistinctValuesRequest) OperationResult
+}
+
+// distinctValuesFor gets the distinct values from the Service, either directly or through aggregation.
+func distinctValuesFor(svc Service, request *DistinctValuesRequest) OperationResult {
+	if implements(svc, distinctValuesServiceInterface) {
+		return svc.(DistinctValuesService).GetDistinctValues(request)
+	}
+	if !implements(svc, aggregatorInterface) {
+		return NotSupportedByResourceResult()
+	}
+
+	result := svc.(Aggregator).Aggregate(&AggregationRequest{
+		GroupBy:  []string{request.Column},
+		Measures: []Measure{{Function: AggregateCount}},
+		Filters:  request.Filters,
+	})
+	if result.State() != Ok {
+		return result
+	}
+	aggregation, ok := result.Value().(*AggregationResult)
+	if !ok || aggregation == nil {
+		return ErrorResult(fmt.Errorf("Unexpected result of Aggregate: %T", result.Value()))
+	}
+
+	values := make([]DistinctValue, 0, len(aggregation.Rows))
+	for _, row := range aggregation.Rows {
+		count, _ := toFloat(row[Measure{Function: AggregateCount}.Name()])
+		values = append(values, DistinctValue{Value: row[request.Column], Count: int(count)})
+	}
+	return OkResult(values)
+}
+
+// supportsDistinctValues tells whether distinctValuesFor can get distinct values from the Service.
+func supportsDistinctValues(svc Service) bool {
+	return implements(svc, distinctValuesServiceInterface) || implements(svc, aggregatorInterface)
+}
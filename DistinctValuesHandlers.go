@@ -0,0 +1,55 @@
+package crud
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+	"github.com/gorilla/mux"
+)
+
+// CreateCrudHandlerDistinctValues is used to get the distinct values of a column, with the number of entities having
+// each value. Route it as e.g. /{resource}/_distinct/{column}; the filters are passed as for list requests. The policy of
+// the Service is applied, if it declares one (see ResourcePolicyProvider).
+var CreateCrudHandlerDistinctValues = func(ctx servicefoundation.AppContext, svc Service, resourceName string, recoverFunc RecoverFunc) http.HandlerFunc {
+	return CreateCrudHandlerDistinctValuesWithPolicy(ctx, svc, resourceName, recoverFunc, policyFor(svc))
+}
+
+// CreateCrudHandlerDistinctValuesWithPolicy is used to get the distinct values of a column, applying the given policy:
+// only the columns that can be filtered on are available, and filters on other columns are removed, or rejected if the
+// policy is strict. The policy can be nil.
+var CreateCrudHandlerDistinctValuesWithPolicy = func(ctx servicefoundation.AppContext, svc Service, resourceName string, recoverFunc RecoverFunc, policy *ResourcePolicy) http.HandlerFunc {
+	logger := ctx.Logger()
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer recoverFunc("crudHandler", ctx, w, r)
+
+		vars := mux.Vars(r)
+		column := vars["column"]
+		if policy != nil && policy.FilterColumns != nil && !containsString(policy.FilterColumns, column) {
+			WriteOperationResult(w, r, ValidationFailedResult(errors.New("Can't get the distinct values of column "+column)))
+			return
+		}
+
+		var dsRequest *DataSetRequest
+		if policy != nil && policy.Strict {
+			var problems *QueryValidationError
+			if dsRequest, problems = ParseDataSetRequestStrict(r, policy); problems != nil {
+				WriteOperationResult(w, r, ValidationFailedResult(problems))
+				return
+			}
+		} else {
+			dsRequest = ExtractDataSetRequestFromURI(r)
+			if policy != nil && policy.FilterColumns != nil {
+				ConstrainFilterColumns(dsRequest, policy.FilterColumns...)
+			}
+		}
+
+		request := &DistinctValuesRequest{Column: column, Filters: dsRequest.Filters}
+		logger.Debug("CreateCrudHandlerDistinctValues", fmt.Sprintf("Interpreted as DistinctValues command. Arguments: %v", request))
+		opResult := traceService(r, resourceName, OperationDistinct, func() OperationResult {
+			return distinctValuesFor(svc, request)
+		})
+		WriteOperationResult(w, r, opResult)
+	}
+}
@@ -0,0 +1,90 @@
+package crud_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// distinctService answers distinct values requests itself
+type distinctService struct {
+	*memoryService
+	request *crud.DistinctValuesRequest
+}
+
+func (s *distinctService) GetDistinctValues(request *crud.DistinctValuesRequest) crud.OperationResult {
+	s.request = request
+	return crud.OkResult([]crud.DistinctValue{{Value: "x", Count: 42}})
+}
+
+func newDistinctRouter(svc crud.Service, policy *crud.ResourcePolicy) *mux.Router {
+	registry := crud.NewRegistry(newTestContext(), crud.Recovery)
+	registry.Register(&crud.Resource{Name: "things", Service: svc, CreateFunc: newTestEntity, Policy: policy})
+	router := mux.NewRouter()
+	registry.Route(router)
+	return router
+}
+
+func newStatusService() *memoryService {
+	svc := newMemoryService()
+	svc.Add(&testEntity{Name: "first", Status: "new"})
+	svc.Add(&testEntity{Name: "second", Status: "active"})
+	svc.Add(&testEntity{Name: "third", Status: "new"})
+	svc.Add(&testEntity{Name: "fourth", Status: "closed"})
+	return svc
+}
+
+func TestRegistry_RouteDistinctValuesThroughAggregation(t *testing.T) {
+	router := newDistinctRouter(crud.NewMemoryAggregator(newStatusService()), nil)
+
+	w := serve(router, "GET", "/things/_distinct/status", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var values []crud.DistinctValue
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &values))
+	assert.Equal(t, []crud.DistinctValue{{Value: "active", Count: 1}, {Value: "closed", Count: 1}, {Value: "new", Count: 2}}, values)
+}
+
+func TestRegistry_RouteDistinctValuesOfService(t *testing.T) {
+	svc := &distinctService{memoryService: newStatusService()}
+	router := newDistinctRouter(svc, &crud.ResourcePolicy{FilterColumns: []string{"status", "name"}})
+
+	w := serve(router, "GET", `/things/_distinct/status?filters={"name":"a","secret":"b"}`, "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "status", svc.request.Column)
+	assert.Equal(t, map[string]string{"name": "a"}, svc.request.Filters)
+}
+
+func TestRegistry_RouteDistinctValuesOfDisallowedColumn(t *testing.T) {
+	svc := &distinctService{memoryService: newStatusService()}
+	router := newDistinctRouter(svc, &crud.ResourcePolicy{FilterColumns: []string{"status"}})
+
+	w := serve(router, "GET", "/things/_distinct/name", "")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Nil(t, svc.request)
+}
+
+func TestRegistry_RouteDistinctValuesStrict(t *testing.T) {
+	svc := &distinctService{memoryService: newStatusService()}
+	router := newDistinctRouter(svc, &crud.ResourcePolicy{FilterColumns: []string{"status"}, Strict: true})
+
+	w := serve(router, "GET", `/things/_distinct/status?filters={"secret":"b"}`, "")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Nil(t, svc.request)
+}
+
+func TestRegistry_RouteDistinctValuesNotSupported(t *testing.T) {
+	router := newDistinctRouter(newStatusService(), nil)
+
+	w := serve(router, "GET", "/things/_distinct/status", "")
+
+	// Not routed, so it's interpreted as a request for the entity with ID _distinct
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
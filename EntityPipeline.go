@@ -0,0 +1,65 @@
+package crud
+
+// EntityStep is a custom step of an EntityPipeline. Returning an error stops the pipeline, and fails the request
+// with State ValidationFailed.
+type EntityStep func(entity Entity, isNewEntity bool) error
+
+// EntityPipeline describes what the create and update handlers do with a decoded entity, before passing it to the Service.
+// By default that is calling Format, then Validate, then the custom steps (if any), in that order.
+type EntityPipeline struct {
+	// SkipFormat disables calling the Format method of the entity.
+	SkipFormat bool
+	// SkipValidate disables calling the Validate method of the entity.
+	SkipValidate bool
+	// Steps are run after Format and Validate.
+	Steps []EntityStep
+}
+
+// EntityPipelineProvider can be implemented by a Service to choose the pipeline used by its create and update handlers.
+type EntityPipelineProvider interface {
+	EntityPipeline() *EntityPipeline
+}
+
+// DefaultEntityPipeline is used for Services that don't implement EntityPipelineProvider.
+var DefaultEntityPipeline = &EntityPipeline{}
+
+// NoEntityPipeline does nothing; it's meant for Services that format and validate entities themselves.
+var NoEntityPipeline = &EntityPipeline{SkipFormat: true, SkipValidate: true}
+
+// Run passes the entity through the pipeline. It returns nil if the entity can be passed on to the Service, or the
+// result to reply with otherwise. A nil pipeline runs as the DefaultEntityPipeline.
+func (p *EntityPipeline) Run(entity Entity, isNewEntity bool) OperationResult {
+	if p == nil {
+		p = DefaultEntityPipeline
+	}
+	if !p.SkipFormat {
+		entity.Format(isNewEntity)
+	}
+	if !p.SkipValidate {
+		if err := entity.Validate(); err != nil {
+			return ValidationFailedResult(err)
+		}
+	}
+	for _, step := range p.Steps {
+		if err := step(entity, isNewEntity); err != nil {
+			return ValidationFailedResult(err)
+		}
+	}
+	return nil
+}
+
+// pipelineFor returns the pipeline to use for a Service.
+func pipelineFor(svc Service) *EntityPipeline {
+	if provider, ok := svc.(EntityPipelineProvider); ok {
+		if pipeline := provider.EntityPipeline(); pipeline != nil {
+			return pipeline
+		}
+	}
+	return DefaultEntityPipeline
+}
+
+// EntityPipeline returns the pipeline entities of the resource go through before they're added or updated: the one
+// declared by its Service, if any. Adapters exposing the resource other than through the registry should use it.
+func (r *Resource) EntityPipeline() *EntityPipeline {
+	return pipelineFor(r.service(ParentKeys{}))
+}
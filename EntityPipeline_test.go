@@ -0,0 +1,160 @@
+package crud_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type formattedEntity struct {
+	Name      string `json:"name"`
+	FormatNew *bool  `json:"-"`
+}
+
+func (e *formattedEntity) Validate() error {
+	if e.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+func (e *formattedEntity) Format(isNewEntity bool) {
+	e.Name = strings.TrimSpace(e.Name)
+	e.FormatNew = &isNewEntity
+}
+
+// recordingService remembers the entities it receives
+type recordingService struct {
+	crud.Service
+	pipeline *crud.EntityPipeline
+	received []*formattedEntity
+}
+
+func (s *recordingService) Add(entity crud.Entity) crud.OperationResult {
+	s.received = append(s.received, entity.(*formattedEntity))
+	return crud.CreatedResult()
+}
+
+func (s *recordingService) Update(id crud.EntityKey, entity crud.Entity) crud.OperationResult {
+	s.received = append(s.received, entity.(*formattedEntity))
+	return crud.OkResult(entity)
+}
+
+func (s *recordingService) EntityPipeline() *crud.EntityPipeline {
+	return s.pipeline
+}
+
+func serveEntity(handler http.HandlerFunc, method, body string) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	router.HandleFunc("/things", handler).Methods("POST")
+	router.HandleFunc("/things/{id}", handler).Methods("PUT")
+
+	path := "/things"
+	if method == "PUT" {
+		path = "/things/1"
+	}
+	r, _ := http.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func newFormattedEntity() crud.Entity {
+	return &formattedEntity{}
+}
+
+func TestCreateCrudHandlerCreateEntity_FormatsAndValidates(t *testing.T) {
+	svc := &recordingService{}
+	handler := crud.CreateCrudHandlerCreateEntity(newTestContext(), svc, "things", noRecovery, newFormattedEntity)
+
+	w := serveEntity(handler, "POST", `{"name":"  padded  "}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 1, len(svc.received))
+	assert.Equal(t, "padded", svc.received[0].Name)
+	assert.True(t, *svc.received[0].FormatNew)
+}
+
+func TestCreateCrudHandlerCreateEntity_ValidationFailed(t *testing.T) {
+	svc := &recordingService{}
+	handler := crud.CreateCrudHandlerCreateEntity(newTestContext(), svc, "things", noRecovery, newFormattedEntity)
+
+	w := serveEntity(handler, "POST", `{"name":"   "}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "name is required")
+	assert.Equal(t, 0, len(svc.received))
+}
+
+func TestCreateCrudHandlerUpdateEntity_FormatsAsExisting(t *testing.T) {
+	svc := &recordingService{}
+	handler := crud.CreateCrudHandlerUpdateEntity(newTestContext(), svc, "things", noRecovery, newFormattedEntity)
+
+	w := serveEntity(handler, "PUT", `{"name":"x"}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, *svc.received[0].FormatNew)
+}
+
+func TestCreateCrudHandlerUpdateEntity_OptOut(t *testing.T) {
+	svc := &recordingService{pipeline: crud.NoEntityPipeline}
+	handler := crud.CreateCrudHandlerUpdateEntity(newTestContext(), svc, "things", noRecovery, newFormattedEntity)
+
+	w := serveEntity(handler, "PUT", `{"name":""}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, len(svc.received))
+	assert.Nil(t, svc.received[0].FormatNew)
+}
+
+func TestCreateCrudHandlerCreateEntityWithPipeline_CustomSteps(t *testing.T) {
+	svc := &recordingService{}
+	steps := make([]string, 0)
+	pipeline := &crud.EntityPipeline{Steps: []crud.EntityStep{
+		func(entity crud.Entity, isNewEntity bool) error {
+			steps = append(steps, "first")
+			return errors.New("rejected by first step")
+		},
+		func(entity crud.Entity, isNewEntity bool) error {
+			steps = append(steps, "second")
+			return nil
+		},
+	}}
+	handler := crud.CreateCrudHandlerCreateEntityWithPipeline(newTestContext(), svc, "things", noRecovery, newFormattedEntity, pipeline)
+
+	w := serveEntity(handler, "POST", `{"name":"x"}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "rejected by first step")
+	assert.Equal(t, []string{"first"}, steps)
+	assert.Equal(t, 0, len(svc.received))
+}
+
+func TestEntityPipeline_ForwardedByDecorators(t *testing.T) {
+	svc := crud.NewHistoryService(&recordingService{pipeline: crud.NoEntityPipeline})
+
+	assert.Equal(t, crud.NoEntityPipeline, svc.EntityPipeline())
+}
+
+func TestCreateCrudHandlerCreateEntityWithPipeline_Nil(t *testing.T) {
+	svc := &recordingService{}
+	handler := crud.CreateCrudHandlerCreateEntityWithPipeline(newTestContext(), svc, "things", noRecovery, newFormattedEntity, nil)
+
+	assert.Equal(t, http.StatusBadRequest, serveEntity(handler, "POST", `{"name":"   "}`).Code)
+	assert.Equal(t, http.StatusCreated, serveEntity(handler, "POST", `{"name":"  padded  "}`).Code)
+	assert.Equal(t, "padded", svc.received[0].Name)
+}
+
+func TestResource_EntityPipeline(t *testing.T) {
+	resource := &crud.Resource{Name: "things", Service: crud.NewHistoryService(&recordingService{pipeline: crud.NoEntityPipeline})}
+	assert.Equal(t, crud.NoEntityPipeline, resource.EntityPipeline())
+
+	resource = &crud.Resource{Name: "things", Service: &recordingService{}}
+	assert.Equal(t, crud.DefaultEntityPipeline, resource.EntityPipeline())
+}
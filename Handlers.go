@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"strconv"
+	"time"
 
 	servicefoundation "github.com/Travix-International/go-servicefoundation"
 	"github.com/gorilla/mux"
@@ -18,17 +19,61 @@ import (
 // RecoverFunc is the recovery function as needed by the CRUD functionality
 type RecoverFunc func(name string, ctx servicefoundation.AppContext, w http.ResponseWriter, r *http.Request)
 
-// CreateCrudHandlerGetList is used to request a list of entities
+// CreateCrudHandlerGetList is used to request a list of entities. The policy of the Service is applied to the request, if
+// it declares one (see ResourcePolicyProvider).
 var CreateCrudHandlerGetList = func(ctx servicefoundation.AppContext, svc Service, resourceName string, recoverFunc RecoverFunc) http.HandlerFunc {
+	return CreateCrudHandlerGetListWithPolicy(ctx, svc, resourceName, recoverFunc, policyFor(svc))
+}
+
+// CreateCrudHandlerGetListWithPolicy is used to request a list of entities, constraining the request to the given policy.
+// The policy can be nil. A strict policy rejects the request instead of constraining it.
+var CreateCrudHandlerGetListWithPolicy = func(ctx servicefoundation.AppContext, svc Service, resourceName string, recoverFunc RecoverFunc, policy *ResourcePolicy) http.HandlerFunc {
 	logger := ctx.Logger()
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer recoverFunc("crudHandler", ctx, w, r)
 		logger.Debug("CrudHandlerStart", fmt.Sprintf("CRUD operation %v requested on %v", r.Method, r.URL.Path))
 
-		dsRequest := ExtractDataSetRequestFromURI(r)
+		decode := startPhase(r, PhaseDecode, AttributeResource.String(resourceName), AttributeOperation.String(string(OperationGetList)))
+		var dsRequest *DataSetRequest
+		if policy != nil && policy.Strict {
+			var problems *QueryValidationError
+			if dsRequest, problems = ParseDataSetRequestStrict(r, policy); problems != nil {
+				logger.Debug("CreateCrudHandlerGetList", problems.Error())
+				endPhase(decode, problems)
+				WriteOperationResult(w, r, ValidationFailedResult(problems))
+				return
+			}
+		} else {
+			dsRequest = ExtractDataSetRequestFromURI(r)
+			if policy != nil {
+				policy.Apply(dsRequest)
+			}
+		}
+		asOf, err := ExtractAsOfFromURI(r)
+		endPhase(decode, err)
+		if err != nil {
+			WriteOperationResult(w, r, ValidationFailedResult(err))
+			return
+		}
+
+		if asOf != nil {
+			logger.Debug("CreateCrudHandlerGetList", fmt.Sprintf("Interpreted as GetList command as of %v. Arguments: %v", asOf, dsRequest))
+			asOfSvc, ok := svc.(AsOfService)
+			if !ok {
+				WriteOperationResult(w, r, NotSupportedByResourceResult())
+				return
+			}
+			opResult := traceService(r, resourceName, OperationGetList, func() OperationResult {
+				return asOfSvc.GetAllAsOf(dsRequest, *asOf)
+			}, pagingAttributes(dsRequest)...)
+			WriteOperationResult(w, r, opResult)
+			return
+		}
 
 		logger.Debug("CreateCrudHandlerGetList", fmt.Sprintf("Interpreted as GetList command. Arguments: %v", dsRequest))
-		opResult := svc.GetAll(dsRequest)
+		opResult := traceService(r, resourceName, OperationGetList, func() OperationResult {
+			return svc.GetAll(dsRequest)
+		}, pagingAttributes(dsRequest)...)
 		WriteOperationResult(w, r, opResult)
 	}
 }
@@ -39,34 +84,78 @@ var CreateCrudHandlerGetByID = func(ctx servicefoundation.AppContext, svc Servic
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer recoverFunc("crudHandler", ctx, w, r)
 
+		decode := startPhase(r, PhaseDecode, AttributeResource.String(resourceName), AttributeOperation.String(string(OperationGetByID)))
 		vars := mux.Vars(r)
 		idVar := vars["id"]
+		asOf, err := ExtractAsOfFromURI(r)
+		endPhase(decode, err)
+		if err != nil {
+			WriteOperationResult(w, r, ValidationFailedResult(err))
+			return
+		}
+
+		if asOf != nil {
+			logger.Debug("CreateCrudHandlerGetByID", fmt.Sprintf("Interpreted as GetById command as of %v. ID: %v", asOf, idVar))
+			asOfSvc, ok := svc.(AsOfService)
+			if !ok {
+				WriteOperationResult(w, r, NotSupportedByResourceResult())
+				return
+			}
+			opResult := traceService(r, resourceName, OperationGetByID, func() OperationResult {
+				return asOfSvc.GetByIDAsOf(idVar, *asOf)
+			}, keyAttribute(idVar))
+			WriteOperationResult(w, r, opResult)
+			return
+		}
 
 		logger.Debug("CreateCrudHandlerGetByID", fmt.Sprintf("Interpreted as GetById command. ID: %v", idVar))
-		opResult := svc.GetByID(idVar)
+		opResult := traceService(r, resourceName, OperationGetByID, func() OperationResult {
+			return svc.GetByID(idVar)
+		}, keyAttribute(idVar))
 		WriteOperationResult(w, r, opResult)
 	}
 }
 
-// CreateCrudHandlerCreateEntity is used to create a new entity
+// CreateCrudHandlerCreateEntity is used to create a new entity. The entity is passed through the pipeline of the Service
+// (see EntityPipelineProvider) before it's added.
 var CreateCrudHandlerCreateEntity = func(ctx servicefoundation.AppContext,
 	svc Service, resourceName string, recoverFunc RecoverFunc,
 	createFunc func() Entity) http.HandlerFunc {
+	return CreateCrudHandlerCreateEntityWithPipeline(ctx, svc, resourceName, recoverFunc, createFunc, pipelineFor(svc))
+}
+
+// CreateCrudHandlerCreateEntityWithPipeline is used to create a new entity, passing it through the given pipeline first
+var CreateCrudHandlerCreateEntityWithPipeline = func(ctx servicefoundation.AppContext,
+	svc Service, resourceName string, recoverFunc RecoverFunc,
+	createFunc func() Entity, pipeline *EntityPipeline) http.HandlerFunc {
 	logger := ctx.Logger()
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer recoverFunc("crudHandler", ctx, w, r)
 
+		decode := startPhase(r, PhaseDecode, AttributeResource.String(resourceName), AttributeOperation.String(string(OperationCreate)))
+
 		// Read the entity from the HTTP body
 		entity := createFunc()
 		err := ReadEntityFromBody(r.Body, entity)
 		if err != nil {
 			opResult := ValidationFailedResult(errors.New("Failed to parse entity from HTTP body: " + err.Error()))
+			endPhase(decode, opResult.Error())
 			WriteOperationResult(w, r, opResult)
 			return
 		}
 
+		if opResult := pipeline.Run(entity, true); opResult != nil {
+			logger.Debug("CreateCrudHandlerCreate", fmt.Sprintf("Entity rejected by pipeline: %v", opResult.Error()))
+			endPhase(decode, opResult.Error())
+			WriteOperationResult(w, r, opResult)
+			return
+		}
+		endPhase(decode, nil)
+
 		logger.Debug("CreateCrudHandlerCreate", fmt.Sprintf("Interpreted as create command. Entity: %s", entity))
-		opResult := svc.Add(entity)
+		opResult := traceService(r, resourceName, OperationCreate, func() OperationResult {
+			return svc.Add(entity)
+		})
 		WriteOperationResult(w, r, opResult)
 	}
 }
@@ -81,19 +170,31 @@ var CreateCrudHandlerDeleteByID = func(ctx servicefoundation.AppContext, svc Ser
 		idVar := vars["id"]
 
 		logger.Debug("CreateCrudHandlerDeleteById", fmt.Sprintf("Interpreted as DeleteByID command. ID: %v", idVar))
-		opResult := svc.Delete(idVar)
+		opResult := traceService(r, resourceName, OperationDelete, func() OperationResult {
+			return svc.Delete(idVar)
+		}, keyAttribute(idVar))
 		WriteOperationResult(w, r, opResult)
 	}
 }
 
-// CreateCrudHandlerUpdateEntity is used to update a new entity
+// CreateCrudHandlerUpdateEntity is used to update a new entity. The entity is passed through the pipeline of the Service
+// (see EntityPipelineProvider) before it's updated.
 var CreateCrudHandlerUpdateEntity = func(ctx servicefoundation.AppContext,
 	svc Service, resourceName string, recoverFunc RecoverFunc,
 	createFunc func() Entity) http.HandlerFunc {
+	return CreateCrudHandlerUpdateEntityWithPipeline(ctx, svc, resourceName, recoverFunc, createFunc, pipelineFor(svc))
+}
+
+// CreateCrudHandlerUpdateEntityWithPipeline is used to update an entity, passing it through the given pipeline first
+var CreateCrudHandlerUpdateEntityWithPipeline = func(ctx servicefoundation.AppContext,
+	svc Service, resourceName string, recoverFunc RecoverFunc,
+	createFunc func() Entity, pipeline *EntityPipeline) http.HandlerFunc {
 	logger := ctx.Logger()
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer recoverFunc("crudHandler", ctx, w, r)
 
+		decode := startPhase(r, PhaseDecode, AttributeResource.String(resourceName), AttributeOperation.String(string(OperationUpdate)))
+
 		// Read the ID from the path
 		vars := mux.Vars(r)
 		idVar := vars["id"]
@@ -103,12 +204,23 @@ var CreateCrudHandlerUpdateEntity = func(ctx servicefoundation.AppContext,
 		err := ReadEntityFromBody(r.Body, entity)
 		if err != nil {
 			opResult := ValidationFailedResult(errors.New("Failed to parse entity from HTTP body: " + err.Error()))
+			endPhase(decode, opResult.Error())
 			WriteOperationResult(w, r, opResult)
 			return
 		}
 
+		if opResult := pipeline.Run(entity, false); opResult != nil {
+			logger.Debug("CreateCrudHandlerUpdateEntity", fmt.Sprintf("Entity rejected by pipeline: %v", opResult.Error()))
+			endPhase(decode, opResult.Error())
+			WriteOperationResult(w, r, opResult)
+			return
+		}
+		endPhase(decode, nil)
+
 		logger.Debug("CreateCrudHandlerUpdateEntity", fmt.Sprintf("Interpreted as update command. Id: %v Entity: %s", idVar, entity))
-		opResult := svc.Update(idVar, entity)
+		opResult := traceService(r, resourceName, OperationUpdate, func() OperationResult {
+			return svc.Update(idVar, entity)
+		}, keyAttribute(idVar))
 		WriteOperationResult(w, r, opResult)
 	}
 }
@@ -130,31 +242,32 @@ func ReadEntityFromBody(body io.ReadCloser, entity interface{}) error {
 func WriteOperationResult(w http.ResponseWriter, r *http.Request, opResult OperationResult) {
 
 	// Determine HTTP status code, plus the response object
-	statusCode := http.StatusInternalServerError
+	statusCode := StatusCodeForState(opResult.State())
+	encode := startPhase(r, PhaseEncode, AttributeState.String(opResult.State().String()))
+	defer encode.End()
 	var responseObject interface{}
 	if opResult.Error() != nil {
-		responseObject = &ErrorDetails{Message: opResult.Error().Error()}
+		details := &ErrorDetails{Message: opResult.Error().Error()}
+		if detailed, ok := opResult.Error().(DetailedError); ok {
+			details.Details = detailed.Details()
+		}
+		responseObject = details
 	}
 
 	switch opResult.State() {
-	case Ok:
-		statusCode = http.StatusOK
+	case Ok, Created:
 		if opResult.Value() != nil {
 			responseObject = opResult.Value()
 		}
-	case Created:
-		statusCode = http.StatusCreated
-	case ValidationFailed:
-		statusCode = http.StatusBadRequest
+	case Accepted:
+		if operation, ok := opResult.Value().(*AsyncOperation); ok && operation != nil {
+			if operation.Location != "" {
+				w.Header().Set("Location", operation.Location)
+			}
+			responseObject = operation
+		}
 	case NotFound:
-		statusCode = http.StatusNotFound
 		responseObject = nil
-	case Conflict:
-		statusCode = http.StatusConflict
-	case NotSupportedByResource:
-		statusCode = http.StatusMethodNotAllowed
-	case Error:
-		// Nothing to do, accept defaults
 	}
 
 	ww := servicefoundation.NewWrappedResponseWriter(w)
@@ -165,6 +278,27 @@ func WriteOperationResult(w http.ResponseWriter, r *http.Request, opResult Opera
 	}
 }
 
+// StatusCodeForState returns the HTTP status code the CRUD protocol uses for a State.
+func StatusCodeForState(state State) int {
+	switch state {
+	case Ok:
+		return http.StatusOK
+	case Created:
+		return http.StatusCreated
+	case Accepted:
+		return http.StatusAccepted
+	case ValidationFailed:
+		return http.StatusBadRequest
+	case NotFound:
+		return http.StatusNotFound
+	case Conflict:
+		return http.StatusConflict
+	case NotSupportedByResource:
+		return http.StatusMethodNotAllowed
+	}
+	return http.StatusInternalServerError
+}
+
 // ExtractDataSetRequestFromURI is a helper function to parse URI parameters into a DataSet request. Any
 // parameters that are omitted from the URL are substituted with default values.
 func ExtractDataSetRequestFromURI(r *http.Request) *DataSetRequest {
@@ -199,7 +333,7 @@ func ExtractDataSetRequestFromURI(r *http.Request) *DataSetRequest {
 			// is encoded json, actually
 			jsonText := v[0]
 			filterKvs := make(map[string]string)
-			err := json.Unmarshal([]byte(jsonText), filterKvs)
+			err := json.Unmarshal([]byte(jsonText), &filterKvs)
 			if err == nil {
 				dsReq.Filters = filterKvs
 			}
@@ -208,3 +342,39 @@ func ExtractDataSetRequestFromURI(r *http.Request) *DataSetRequest {
 
 	return dsReq
 }
+
+// ExtractAsOfFromURI is a helper function to parse the optional 'asOf' URI parameter, an RFC 3339 timestamp. Returns nil
+// if the parameter is omitted.
+func ExtractAsOfFromURI(r *http.Request) (*time.Time, error) {
+	value := ""
+	for k, v := range r.URL.Query() {
+		if strings.ToLower(k) == "asof" {
+			value = v[0]
+		}
+	}
+	if value == "" {
+		return nil, nil
+	}
+	asOf, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.New("Invalid asOf timestamp, expected RFC 3339: " + value)
+	}
+	return &asOf, nil
+}
+
+// ExtractExpandFromURI is a helper function to parse the optional 'expand' URI parameter: a comma-separated list of the
+// relations to embed in the response. Returns nil if the parameter is omitted.
+func ExtractExpandFromURI(r *http.Request) []string {
+	var expand []string
+	for k, v := range r.URL.Query() {
+		if strings.ToLower(k) != "expand" {
+			continue
+		}
+		for _, name := range strings.Split(v[0], ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				expand = append(expand, name)
+			}
+		}
+	}
+	return expand
+}
@@ -44,3 +44,11 @@ func TestCreateCrudHandlerGetList_WithoutParameters(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	crudService.AssertExpectations(t)
 }
+
+func TestExtractDataSetRequestFromURI_Filters(t *testing.T) {
+	r, _ := http.NewRequest("GET", `/things?filters={"name":"abc"}`, nil)
+
+	dsRequest := crud.ExtractDataSetRequestFromURI(r)
+
+	assert.Equal(t, map[string]string{"name": "abc"}, dsRequest.Filters)
+}
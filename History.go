@@ -0,0 +1,292 @@
+package crud
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// HistoryOperation describes the kind of mutation that produced a revision
+type HistoryOperation string
+
+const (
+	// HistoryCreated is recorded when an entity is added
+	HistoryCreated HistoryOperation = "created"
+	// HistoryUpdated is recorded when an entity is updated
+	HistoryUpdated HistoryOperation = "updated"
+	// HistoryDeleted is recorded when an entity is deleted
+	HistoryDeleted HistoryOperation = "deleted"
+)
+
+// Revision is a snapshot of an entity, as it was right after a mutation
+type Revision struct {
+	// Number is the one-based, per-entity revision number.
+	Number int `json:"revision"`
+	// Timestamp is the moment the revision was recorded.
+	Timestamp time.Time `json:"timestamp"`
+	// Operation is the kind of mutation that produced this revision.
+	Operation HistoryOperation `json:"operation"`
+	// Entity is the JSON representation of the entity. Nil for deletes.
+	Entity interface{} `json:"entity"`
+}
+
+// AsOfService is implemented by Services that can return entities as they were at a given moment in time.
+type AsOfService interface {
+	// GetAllAsOf is the same as GetAll, but evaluated against the entities as they were at the given moment.
+	GetAllAsOf(request *DataSetRequest, asOf time.Time) OperationResult
+
+	// GetByIDAsOf returns the entity with the specified ID, as it was at the given moment.
+	GetByIDAsOf(id EntityKey, asOf time.Time) OperationResult
+}
+
+// HistoryService is a Service decorator that records a revision for every successful Add, Update and Delete. It
+// implements AsOfService itself; as a ServiceDecorator, it forwards the other optional interfaces of the decorated
+// Service.
+//
+// Revisions can only be recorded for Adds that return the ID of the new entity (see CreatedWithIDResult), since
+// there's no other way to learn the ID. The history is kept in memory, so set MaxRevisions or Retention to bound it.
+// Entities are unknown as of moments before their oldest remaining revision.
+type HistoryService struct {
+	Service
+
+	// Now returns the current time. Replaceable for testing purposes; defaults to time.Now.
+	Now func() time.Time
+	// MaxRevisions is the number of revisions kept per entity; older ones are dropped. Zero means unlimited.
+	MaxRevisions int
+	// Retention is how long revisions are kept. The latest revision of an entity that still exists is always kept, so
+	// its current state stays known; the history of a deleted entity is dropped altogether once its deletion expires.
+	// Zero means forever.
+	Retention time.Duration
+
+	mutex     sync.RWMutex
+	revisions map[string][]Revision
+}
+
+// NewHistoryService wraps the given Service with one that keeps the history of its entities.
+func NewHistoryService(svc Service) *HistoryService {
+	return &HistoryService{
+		Service:   svc,
+		Now:       time.Now,
+		revisions: make(map[string][]Revision),
+	}
+}
+
+// EntityPipeline uses the pipeline of the decorated Service.
+func (h *HistoryService) EntityPipeline() *EntityPipeline {
+	return pipelineFor(h.Service)
+}
+
+// ResourcePolicy uses the policy of the decorated Service.
+func (h *HistoryService) ResourcePolicy() *ResourcePolicy {
+	return policyFor(h.Service)
+}
+
+// Decorated returns the decorated Service.
+func (h *HistoryService) Decorated() Service {
+	return h.Service
+}
+
+// provides tells that the history itself answers AsOf requests.
+func (h *HistoryService) provides(i optionalInterface) bool {
+	return i == asOfServiceInterface
+}
+
+// Aggregate aggregates the entities using the decorated Service, if it's an Aggregator.
+func (h *HistoryService) Aggregate(request *AggregationRequest) OperationResult {
+	return aggregate(h.Service, request)
+}
+
+// GetDistinctValues gets the distinct values of a column from the decorated Service, if it's a DistinctValuesService
+// or an Aggregator.
+func (h *HistoryService) GetDistinctValues(request *DistinctValuesRequest) OperationResult {
+	return distinctValuesFor(h.Service, request)
+}
+
+// GetByIDs gets the entities with the given keys from the decorated Service, in a single call if it's a BatchGetter.
+func (h *HistoryService) GetByIDs(ids []EntityKey) OperationResult {
+	return getByIDs(h.Service, ids)
+}
+
+// Add will add the given entity, recording the first revision if the new ID is known. The entity is read back
+// from the Service, so that the revision includes fields set by the Service itself, such as the ID.
+func (h *HistoryService) Add(entity Entity) OperationResult {
+	result := h.Service.Add(entity)
+	if isSuccess(result) && result.Value() != nil {
+		h.record(result.Value(), HistoryCreated, readBack(h.Service, result.Value(), entity))
+	}
+	return result
+}
+
+// Update will update an existing entity, recording the new state as a revision.
+func (h *HistoryService) Update(id EntityKey, entity Entity) OperationResult {
+	result := h.Service.Update(id, entity)
+	if isSuccess(result) {
+		var snapshot interface{} = entity
+		if result.Value() != nil {
+			snapshot = result.Value()
+		}
+		h.record(id, HistoryUpdated, snapshot)
+	}
+	return result
+}
+
+// Delete will delete the entity, recording the deletion as a revision.
+func (h *HistoryService) Delete(id EntityKey) OperationResult {
+	result := h.Service.Delete(id)
+	if isSuccess(result) {
+		h.record(id, HistoryDeleted, nil)
+	}
+	return result
+}
+
+// Revisions returns the revisions of an entity that are kept, oldest first.
+func (h *HistoryService) Revisions(id EntityKey) []Revision {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	revisions := h.revisions[historyKey(id)]
+	result := make([]Revision, len(revisions))
+	copy(result, revisions)
+	return result
+}
+
+// Revision returns a specific revision of an entity. The boolean is false if it doesn't exist, or was dropped.
+func (h *HistoryService) Revision(id EntityKey, number int) (Revision, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	for _, revision := range h.revisions[historyKey(id)] {
+		if revision.Number == number {
+			return revision, true
+		}
+	}
+	return Revision{}, false
+}
+
+// DeleteExpired drops the revisions that expired according to Retention. Expired revisions of an entity are dropped
+// when it's changed anyway; this frees up the memory held by entities that aren't.
+func (h *HistoryService) DeleteExpired() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	now := h.Now()
+	for key := range h.revisions {
+		h.prune(key, now)
+	}
+}
+
+// DiffRevisions returns the JSON Patch that turns one revision of an entity into another.
+func (h *HistoryService) DiffRevisions(id EntityKey, from, to int) OperationResult {
+	fromRevision, ok := h.Revision(id, from)
+	if !ok {
+		return NotFoundResult()
+	}
+	toRevision, ok := h.Revision(id, to)
+	if !ok {
+		return NotFoundResult()
+	}
+
+	patch, err := DiffJSON(fromRevision.Entity, toRevision.Entity)
+	if err != nil {
+		return ErrorResult(err)
+	}
+	return OkResult(patch)
+}
+
+// GetByIDAsOf returns the entity with the specified ID, as it was recorded at the given moment.
+func (h *HistoryService) GetByIDAsOf(id EntityKey, asOf time.Time) OperationResult {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	revision, ok := revisionAsOf(h.revisions[historyKey(id)], asOf)
+	if !ok || revision.Operation == HistoryDeleted {
+		return NotFoundResult()
+	}
+	return OkResult(revision.Entity)
+}
+
+// GetAllAsOf returns the entities that existed at the given moment, in the state they were in at that moment.
+// Filtering, sorting and paging are applied on the recorded JSON representation of the entities.
+func (h *HistoryService) GetAllAsOf(request *DataSetRequest, asOf time.Time) OperationResult {
+	h.mutex.RLock()
+	items := make([]interface{}, 0, len(h.revisions))
+	for _, revisions := range h.revisions {
+		revision, ok := revisionAsOf(revisions, asOf)
+		if ok && revision.Operation != HistoryDeleted {
+			items = append(items, revision.Entity)
+		}
+	}
+	h.mutex.RUnlock()
+
+	return OkResult(applyDataSetRequest(items, request))
+}
+
+func (h *HistoryService) record(id EntityKey, operation HistoryOperation, entity interface{}) {
+	var snapshot interface{}
+	if entity != nil {
+		// Store the JSON representation, so later changes to the entity can't alter history
+		doc, err := toJSONDocument(entity)
+		if err != nil {
+			return
+		}
+		snapshot = doc
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	key := historyKey(id)
+	now := h.Now()
+	number := 1
+	if revisions := h.revisions[key]; len(revisions) > 0 {
+		number = revisions[len(revisions)-1].Number + 1
+	}
+	h.revisions[key] = append(h.revisions[key], Revision{
+		Number:    number,
+		Timestamp: now,
+		Operation: operation,
+		Entity:    snapshot,
+	})
+	h.prune(key, now)
+}
+
+// prune drops the revisions of an entity beyond MaxRevisions or Retention. The caller holds the lock.
+func (h *HistoryService) prune(key string, now time.Time) {
+	revisions := h.revisions[key]
+	keep := 0
+	if h.MaxRevisions > 0 && len(revisions) > h.MaxRevisions {
+		keep = len(revisions) - h.MaxRevisions
+	}
+	if h.Retention > 0 {
+		expiry := now.Add(-h.Retention)
+		for keep < len(revisions) && revisions[keep].Timestamp.Before(expiry) {
+			keep++
+		}
+		if keep == len(revisions) && revisions[keep-1].Operation != HistoryDeleted {
+			keep--
+		}
+	}
+	if keep == 0 {
+		return
+	}
+	if keep == len(revisions) {
+		delete(h.revisions, key)
+		return
+	}
+	h.revisions[key] = append([]Revision{}, revisions[keep:]...)
+}
+
+// revisionAsOf returns the latest revision recorded at or before the given moment.
+func revisionAsOf(revisions []Revision, asOf time.Time) (Revision, bool) {
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if !revisions[i].Timestamp.After(asOf) {
+			return revisions[i], true
+		}
+	}
+	return Revision{}, false
+}
+
+// historyKey normalizes entity keys, so that e.g. the int returned by Add and the string taken from the URL match.
+func historyKey(id EntityKey) string {
+	return fmt.Sprint(id)
+}
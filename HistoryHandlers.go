@@ -0,0 +1,80 @@
+package crud
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+	"github.com/gorilla/mux"
+)
+
+// CreateCrudHandlerGetRevisions is used to list all revisions of an entity. Route it as e.g. /{resource}/{id}/revisions.
+var CreateCrudHandlerGetRevisions = func(ctx servicefoundation.AppContext, svc *HistoryService, resourceName string, recoverFunc RecoverFunc) http.HandlerFunc {
+	logger := ctx.Logger()
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer recoverFunc("crudHandler", ctx, w, r)
+
+		vars := mux.Vars(r)
+		idVar := vars["id"]
+
+		logger.Debug("CreateCrudHandlerGetRevisions", fmt.Sprintf("Interpreted as GetRevisions command. ID: %v", idVar))
+		revisions := svc.Revisions(idVar)
+		if len(revisions) == 0 {
+			WriteOperationResult(w, r, NotFoundResult())
+			return
+		}
+		WriteOperationResult(w, r, OkResult(revisions))
+	}
+}
+
+// CreateCrudHandlerGetRevision is used to get a specific revision of an entity. Route it as e.g. /{resource}/{id}/revisions/{revision}.
+var CreateCrudHandlerGetRevision = func(ctx servicefoundation.AppContext, svc *HistoryService, resourceName string, recoverFunc RecoverFunc) http.HandlerFunc {
+	logger := ctx.Logger()
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer recoverFunc("crudHandler", ctx, w, r)
+
+		vars := mux.Vars(r)
+		idVar := vars["id"]
+		number, err := strconv.Atoi(vars["revision"])
+		if err != nil {
+			WriteOperationResult(w, r, ValidationFailedResult(errors.New("Invalid revision number: "+vars["revision"])))
+			return
+		}
+
+		logger.Debug("CreateCrudHandlerGetRevision", fmt.Sprintf("Interpreted as GetRevision command. ID: %v Revision: %v", idVar, number))
+		revision, ok := svc.Revision(idVar, number)
+		if !ok {
+			WriteOperationResult(w, r, NotFoundResult())
+			return
+		}
+		WriteOperationResult(w, r, OkResult(revision))
+	}
+}
+
+// CreateCrudHandlerDiffRevisions is used to get the JSON Patch between two revisions of an entity. Route it as e.g. /{resource}/{id}/diff;
+// the revisions are passed as the 'from' and 'to' query parameters.
+var CreateCrudHandlerDiffRevisions = func(ctx servicefoundation.AppContext, svc *HistoryService, resourceName string, recoverFunc RecoverFunc) http.HandlerFunc {
+	logger := ctx.Logger()
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer recoverFunc("crudHandler", ctx, w, r)
+
+		vars := mux.Vars(r)
+		idVar := vars["id"]
+		query := r.URL.Query()
+		from, err := strconv.Atoi(query.Get("from"))
+		if err != nil {
+			WriteOperationResult(w, r, ValidationFailedResult(errors.New("Invalid 'from' revision number: "+query.Get("from"))))
+			return
+		}
+		to, err := strconv.Atoi(query.Get("to"))
+		if err != nil {
+			WriteOperationResult(w, r, ValidationFailedResult(errors.New("Invalid 'to' revision number: "+query.Get("to"))))
+			return
+		}
+
+		logger.Debug("CreateCrudHandlerDiffRevisions", fmt.Sprintf("Interpreted as DiffRevisions command. ID: %v From: %v To: %v", idVar, from, to))
+		WriteOperationResult(w, r, svc.DiffRevisions(idVar, from, to))
+	}
+}
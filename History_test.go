@@ -0,0 +1,356 @@
+package crud_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	crud "github.com/Travix-International/crud-go"
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+	"github.com/Travix-International/logger"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type testEntity struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+func (e *testEntity) Validate() error {
+	if e.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+func (e *testEntity) Format(isNewEntity bool) {}
+
+// memoryService is a simple in-memory Service, used as the decorated service in tests
+type memoryService struct {
+	mutex    sync.Mutex
+	lastID   int
+	entities map[string]*testEntity
+}
+
+func newMemoryService() *memoryService {
+	return &memoryService{entities: make(map[string]*testEntity)}
+}
+
+func (m *memoryService) GetAll(request *crud.DataSetRequest) crud.OperationResult {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	items := make([]interface{}, 0, len(m.entities))
+	for i := 1; i <= m.lastID; i++ {
+		if e, ok := m.entities[strconv.Itoa(i)]; ok {
+			items = append(items, e)
+		}
+	}
+	return crud.OkResult(&crud.DataSet{Items: items, PagingInfo: crud.PagingInfo{PageSize: len(items), PageNumber: 1, TotalRecordsCount: len(items), DoesKnowTotalRecords: true}})
+}
+
+func (m *memoryService) GetByID(id crud.EntityKey) crud.OperationResult {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	e, ok := m.entities[toKey(id)]
+	if !ok {
+		return crud.NotFoundResult()
+	}
+	return crud.OkResult(e)
+}
+
+func (m *memoryService) Add(entity crud.Entity) crud.OperationResult {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lastID++
+	e := *entity.(*testEntity)
+	e.ID = m.lastID
+	m.entities[strconv.Itoa(e.ID)] = &e
+	return crud.CreatedWithIDResult(e.ID)
+}
+
+func (m *memoryService) Update(id crud.EntityKey, entity crud.Entity) crud.OperationResult {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	key := toKey(id)
+	if _, ok := m.entities[key]; !ok {
+		return crud.NotFoundResult()
+	}
+	e := *entity.(*testEntity)
+	e.ID, _ = strconv.Atoi(key)
+	m.entities[key] = &e
+	return crud.OkResult(&e)
+}
+
+func (m *memoryService) Delete(id crud.EntityKey) crud.OperationResult {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	key := toKey(id)
+	if _, ok := m.entities[key]; !ok {
+		return crud.NotFoundResult()
+	}
+	delete(m.entities, key)
+	return crud.OkResult(nil)
+}
+
+func toKey(id crud.EntityKey) string {
+	switch v := id.(type) {
+	case int:
+		return strconv.Itoa(v)
+	case string:
+		return v
+	}
+	return ""
+}
+
+func newTestContext() servicefoundation.AppContext {
+	loggy, _ := logger.New(make(map[string]string))
+	ctx := &servicefoundation.ContextBase{}
+	ctx.SetLogger(loggy)
+	return ctx
+}
+
+func noRecovery(name string, ctx servicefoundation.AppContext, w http.ResponseWriter, r *http.Request) {
+}
+
+// newSteppingClock returns a clock that advances one hour on every call
+func newSteppingClock(start time.Time) func() time.Time {
+	current := start
+	return func() time.Time {
+		current = current.Add(time.Hour)
+		return current
+	}
+}
+
+var historyStart = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func newHistoryFixture() *crud.HistoryService {
+	svc := crud.NewHistoryService(newMemoryService())
+	svc.Now = newSteppingClock(historyStart)
+
+	svc.Add(&testEntity{Name: "first", Status: "new"})                    // 13:00
+	svc.Update(1, &testEntity{Name: "first", Status: "active"})           // 14:00
+	svc.Add(&testEntity{Name: "second", Status: "new"})                   // 15:00
+	svc.Update("1", &testEntity{Name: "first renamed", Status: "active"}) // 16:00
+	svc.Delete(2)                                                         // 17:00
+	return svc
+}
+
+func TestHistoryService_RecordsRevisions(t *testing.T) {
+	svc := newHistoryFixture()
+
+	revisions := svc.Revisions(1)
+	assert.Equal(t, 3, len(revisions))
+	assert.Equal(t, crud.HistoryCreated, revisions[0].Operation)
+	assert.Equal(t, crud.HistoryUpdated, revisions[2].Operation)
+	assert.Equal(t, 3, revisions[2].Number)
+	assert.Equal(t, "first renamed", revisions[2].Entity.(map[string]interface{})["name"])
+
+	revisions = svc.Revisions("2")
+	assert.Equal(t, 2, len(revisions))
+	assert.Equal(t, crud.HistoryDeleted, revisions[1].Operation)
+	assert.Nil(t, revisions[1].Entity)
+}
+
+func TestHistoryService_FailedOperationsAreNotRecorded(t *testing.T) {
+	svc := newHistoryFixture()
+
+	result := svc.Update(42, &testEntity{Name: "ghost"})
+	assert.Equal(t, crud.NotFound, result.State())
+	assert.Equal(t, 0, len(svc.Revisions(42)))
+}
+
+func TestHistoryService_GetByIDAsOf(t *testing.T) {
+	svc := newHistoryFixture()
+
+	result := svc.GetByIDAsOf(1, historyStart.Add(90*time.Minute))
+	assert.Equal(t, crud.Ok, result.State())
+	assert.Equal(t, "new", result.Value().(map[string]interface{})["status"])
+
+	result = svc.GetByIDAsOf(1, historyStart)
+	assert.Equal(t, crud.NotFound, result.State())
+
+	result = svc.GetByIDAsOf(2, historyStart.Add(5*time.Hour))
+	assert.Equal(t, crud.NotFound, result.State())
+}
+
+func TestHistoryService_GetAllAsOf(t *testing.T) {
+	svc := newHistoryFixture()
+	request := &crud.DataSetRequest{PageSize: 10, PageNumber: 1, SortColumn: "id", SortDirection: string(crud.Asc)}
+
+	result := svc.GetAllAsOf(request, historyStart.Add(3*time.Hour))
+	dataSet := result.Value().(*crud.DataSet)
+	assert.Equal(t, 2, dataSet.PagingInfo.TotalRecordsCount)
+
+	result = svc.GetAllAsOf(request, historyStart.Add(5*time.Hour))
+	dataSet = result.Value().(*crud.DataSet)
+	assert.Equal(t, 1, dataSet.PagingInfo.TotalRecordsCount)
+	assert.Equal(t, "first renamed", dataSet.Items[0].(map[string]interface{})["name"])
+}
+
+func TestHistoryService_GetAllAsOfLargeValues(t *testing.T) {
+	entities := newMemoryService()
+	entities.lastID = 999999
+	svc := crud.NewHistoryService(entities)
+	svc.Now = newSteppingClock(historyStart)
+	svc.Add(&testEntity{Name: "first"})  // 13:00, ID 1000000
+	svc.Add(&testEntity{Name: "second"}) // 14:00, ID 1000001
+	asOf := historyStart.Add(3 * time.Hour)
+
+	result := svc.GetAllAsOf(&crud.DataSetRequest{PageSize: math.MaxInt, PageNumber: 1, SortColumn: "id"}, asOf)
+	assert.Equal(t, 2, len(result.Value().(*crud.DataSet).Items))
+
+	result = svc.GetAllAsOf(&crud.DataSetRequest{PageSize: 10, PageNumber: 1, Filters: map[string]string{"id": "1000001"}}, asOf)
+	dataSet := result.Value().(*crud.DataSet)
+	if assert.Equal(t, 1, len(dataSet.Items)) {
+		assert.Equal(t, "second", dataSet.Items[0].(map[string]interface{})["name"])
+	}
+}
+
+func TestHistoryService_MaxRevisions(t *testing.T) {
+	svc := crud.NewHistoryService(newMemoryService())
+	svc.Now = newSteppingClock(historyStart)
+	svc.MaxRevisions = 2
+
+	svc.Add(&testEntity{Name: "first"})     // 13:00
+	svc.Update(1, &testEntity{Name: "one"}) // 14:00
+	svc.Update(1, &testEntity{Name: "two"}) // 15:00
+
+	revisions := svc.Revisions(1)
+	if assert.Equal(t, 2, len(revisions)) {
+		assert.Equal(t, 2, revisions[0].Number)
+		assert.Equal(t, 3, revisions[1].Number)
+	}
+	_, ok := svc.Revision(1, 1)
+	assert.False(t, ok)
+	revision, ok := svc.Revision(1, 3)
+	assert.True(t, ok)
+	assert.Equal(t, "two", revision.Entity.(map[string]interface{})["name"])
+	assert.Equal(t, crud.NotFound, svc.GetByIDAsOf(1, historyStart.Add(90*time.Minute)).State())
+}
+
+func TestHistoryService_Retention(t *testing.T) {
+	svc := newHistoryFixture() // The clock is at 17:00
+	svc.Retention = 30 * time.Minute
+
+	svc.DeleteExpired() // 18:00
+
+	// Only the latest revision of an existing entity is kept, and nothing of a deleted one
+	revisions := svc.Revisions(1)
+	if assert.Equal(t, 1, len(revisions)) {
+		assert.Equal(t, 3, revisions[0].Number)
+	}
+	assert.Equal(t, 0, len(svc.Revisions(2)))
+	result := svc.GetAllAsOf(&crud.DataSetRequest{PageSize: 10, PageNumber: 1}, historyStart.Add(6*time.Hour))
+	assert.Equal(t, 1, result.Value().(*crud.DataSet).PagingInfo.TotalRecordsCount)
+}
+
+func TestHistoryService_DiffRevisions(t *testing.T) {
+	svc := newHistoryFixture()
+
+	result := svc.DiffRevisions(1, 1, 3)
+	assert.Equal(t, crud.Ok, result.State())
+	assert.Equal(t, []crud.PatchOperation{
+		{Op: "replace", Path: "/name", Value: "first renamed"},
+		{Op: "replace", Path: "/status", Value: "active"},
+	}, result.Value())
+
+	result = svc.DiffRevisions(1, 1, 4)
+	assert.Equal(t, crud.NotFound, result.State())
+}
+
+func TestDiffJSON_AddAndRemove(t *testing.T) {
+	patch, err := crud.DiffJSON(
+		map[string]interface{}{"a/b": 1, "gone": true},
+		map[string]interface{}{"a/b": 1, "new": nil})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []crud.PatchOperation{
+		{Op: "remove", Path: "/gone"},
+		{Op: "add", Path: "/new", Value: nil},
+	}, patch)
+
+	data, _ := json.Marshal(patch)
+	assert.Equal(t, `[{"op":"remove","path":"/gone"},{"op":"add","path":"/new","value":null}]`, string(data))
+}
+
+func TestCreateCrudHandlerGetByID_AsOf(t *testing.T) {
+	svc := newHistoryFixture()
+	router := mux.NewRouter()
+	router.HandleFunc("/things/{id}", crud.CreateCrudHandlerGetByID(newTestContext(), svc, "things", noRecovery))
+
+	r, _ := http.NewRequest("GET", "/things/1?asOf=2017-03-01T14:30:00Z", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"active"`)
+}
+
+func TestCreateCrudHandlerGetByID_InvalidAsOf(t *testing.T) {
+	svc := newHistoryFixture()
+	router := mux.NewRouter()
+	router.HandleFunc("/things/{id}", crud.CreateCrudHandlerGetByID(newTestContext(), svc, "things", noRecovery))
+
+	r, _ := http.NewRequest("GET", "/things/1?asOf=yesterday", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateCrudHandlerGetList_AsOfNotSupported(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/things", crud.CreateCrudHandlerGetList(newTestContext(), newMemoryService(), "things", noRecovery))
+
+	r, _ := http.NewRequest("GET", "/things?asOf=2017-03-01T14:30:00Z", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestCreateCrudHandlerGetRevision(t *testing.T) {
+	svc := newHistoryFixture()
+	router := mux.NewRouter()
+	router.HandleFunc("/things/{id}/revisions/{revision}", crud.CreateCrudHandlerGetRevision(newTestContext(), svc, "things", noRecovery))
+
+	r, _ := http.NewRequest("GET", "/things/1/revisions/2", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"revision":2`)
+
+	r, _ = http.NewRequest("GET", "/things/1/revisions/9", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCreateCrudHandlerDiffRevisions(t *testing.T) {
+	svc := newHistoryFixture()
+	router := mux.NewRouter()
+	router.HandleFunc("/things/{id}/diff", crud.CreateCrudHandlerDiffRevisions(newTestContext(), svc, "things", noRecovery))
+
+	r, _ := http.NewRequest("GET", "/things/1/diff?from=1&to=2", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"path":"/status"`)
+
+	r, _ = http.NewRequest("GET", "/things/1/diff?from=1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,250 @@
+package crud
+
+import "time"
+
+// The hook interfaces can be implemented by entities to have a HookService run them around mutations. Before hooks
+// return nil to let the mutation go ahead, or the result to reply with instead, e.g. a ConflictResult. After hooks are
+// run with the result of the mutation, whether it succeeded or not.
+
+// BeforeAddHook is run on an entity before it's added.
+type BeforeAddHook interface {
+	BeforeAdd() OperationResult
+}
+
+// AfterAddHook is run on an entity after adding it.
+type AfterAddHook interface {
+	AfterAdd(result OperationResult)
+}
+
+// BeforeUpdateHook is run on the new version of an entity before it's updated, receiving the stored version.
+type BeforeUpdateHook interface {
+	BeforeUpdate(old Entity) OperationResult
+}
+
+// AfterUpdateHook is run on the new version of an entity after updating it, receiving the formerly stored version.
+type AfterUpdateHook interface {
+	AfterUpdate(old Entity, result OperationResult)
+}
+
+// BeforeDeleteHook is run on the stored entity before it's deleted.
+type BeforeDeleteHook interface {
+	BeforeDelete() OperationResult
+}
+
+// AfterDeleteHook is run on the formerly stored entity after deleting it.
+type AfterDeleteHook interface {
+	AfterDelete(result OperationResult)
+}
+
+// Hooks are run by a HookService around the mutations of a resource, after the hooks of the entities themselves. All
+// hooks are optional. As for the hooks of entities, Before hooks return nil to let the mutation go ahead, or the result
+// to reply with instead.
+type Hooks struct {
+	BeforeAdd    func(entity Entity) OperationResult
+	AfterAdd     func(entity Entity, result OperationResult)
+	BeforeUpdate func(id EntityKey, old, entity Entity) OperationResult
+	AfterUpdate  func(id EntityKey, old, entity Entity, result OperationResult)
+	BeforeDelete func(id EntityKey, entity Entity) OperationResult
+	AfterDelete  func(id EntityKey, entity Entity, result OperationResult)
+}
+
+// HookService is a Service decorator running lifecycle hooks around Add, Update and Delete: those of the entities (see
+// BeforeAddHook and the like), and those of the resource. For hooks that need the stored entity, it's loaded using
+// GetByID first; if it can't be loaded, the result of GetByID is returned instead of performing the mutation. As a
+// ServiceDecorator, it forwards the optional interfaces of the decorated Service.
+type HookService struct {
+	Service
+
+	// Hooks are the hooks of the resource. Optional.
+	Hooks *Hooks
+	// CreateFunc creates an empty entity of the resource. It tells whether the entities of the resource have delete
+	// hooks, so that the stored entity is only loaded when needed. Optional; without it, entities are loaded on every
+	// Delete.
+	CreateFunc func() Entity
+}
+
+// NewHookService wraps the given Service with one running the hooks of the entities, and the given hooks of the
+// resource, around mutations.
+func NewHookService(svc Service, hooks *Hooks, createFunc func() Entity) *HookService {
+	if hooks == nil {
+		hooks = &Hooks{}
+	}
+	return &HookService{Service: svc, Hooks: hooks, CreateFunc: createFunc}
+}
+
+// EntityPipeline uses the pipeline of the decorated Service.
+func (h *HookService) EntityPipeline() *EntityPipeline {
+	return pipelineFor(h.Service)
+}
+
+// ResourcePolicy uses the policy of the decorated Service.
+func (h *HookService) ResourcePolicy() *ResourcePolicy {
+	return policyFor(h.Service)
+}
+
+// Decorated returns the decorated Service.
+func (h *HookService) Decorated() Service {
+	return h.Service
+}
+
+// GetAllAsOf gets the entities as they were at the given moment from the decorated Service, if it's an AsOfService.
+func (h *HookService) GetAllAsOf(request *DataSetRequest, asOf time.Time) OperationResult {
+	return getAllAsOf(h.Service, request, asOf)
+}
+
+// GetByIDAsOf gets the entity as it was at the given moment from the decorated Service, if it's an AsOfService.
+func (h *HookService) GetByIDAsOf(id EntityKey, asOf time.Time) OperationResult {
+	return getByIDAsOf(h.Service, id, asOf)
+}
+
+// Aggregate aggregates the entities using the decorated Service, if it's an Aggregator.
+func (h *HookService) Aggregate(request *AggregationRequest) OperationResult {
+	return aggregate(h.Service, request)
+}
+
+// GetDistinctValues gets the distinct values of a column from the decorated Service, if it's a DistinctValuesService
+// or an Aggregator.
+func (h *HookService) GetDistinctValues(request *DistinctValuesRequest) OperationResult {
+	return distinctValuesFor(h.Service, request)
+}
+
+// GetByIDs gets the entities with the given keys from the decorated Service, in a single call if it's a BatchGetter.
+func (h *HookService) GetByIDs(ids []EntityKey) OperationResult {
+	return getByIDs(h.Service, ids)
+}
+
+// Add adds the entity, unless a hook vetoes it
+func (h *HookService) Add(entity Entity) OperationResult {
+	if hook, ok := entity.(BeforeAddHook); ok {
+		if veto := hook.BeforeAdd(); veto != nil {
+			return veto
+		}
+	}
+	if h.Hooks.BeforeAdd != nil {
+		if veto := h.Hooks.BeforeAdd(entity); veto != nil {
+			return veto
+		}
+	}
+
+	result := h.Service.Add(entity)
+
+	if hook, ok := entity.(AfterAddHook); ok {
+		hook.AfterAdd(result)
+	}
+	if h.Hooks.AfterAdd != nil {
+		h.Hooks.AfterAdd(entity, result)
+	}
+	return result
+}
+
+// Update updates the entity, unless a hook vetoes it
+func (h *HookService) Update(id EntityKey, entity Entity) OperationResult {
+	before, hasBefore := entity.(BeforeUpdateHook)
+	after, hasAfter := entity.(AfterUpdateHook)
+	if !hasBefore && !hasAfter && h.Hooks.BeforeUpdate == nil && h.Hooks.AfterUpdate == nil {
+		return h.Service.Update(id, entity)
+	}
+
+	old, failed := h.load(id)
+	if failed != nil {
+		return failed
+	}
+	if hasBefore {
+		if veto := before.BeforeUpdate(old); veto != nil {
+			return veto
+		}
+	}
+	if h.Hooks.BeforeUpdate != nil {
+		if veto := h.Hooks.BeforeUpdate(id, old, entity); veto != nil {
+			return veto
+		}
+	}
+
+	result := h.Service.Update(id, entity)
+
+	if hasAfter {
+		after.AfterUpdate(old, result)
+	}
+	if h.Hooks.AfterUpdate != nil {
+		h.Hooks.AfterUpdate(id, old, entity, result)
+	}
+	return result
+}
+
+// Delete deletes the entity, unless a hook vetoes it
+func (h *HookService) Delete(id EntityKey) OperationResult {
+	if !h.deleteHooks() {
+		return h.Service.Delete(id)
+	}
+
+	entity, failed := h.load(id)
+	if failed != nil {
+		return failed
+	}
+	if hook, ok := entity.(BeforeDeleteHook); ok {
+		if veto := hook.BeforeDelete(); veto != nil {
+			return veto
+		}
+	}
+	if h.Hooks.BeforeDelete != nil {
+		if veto := h.Hooks.BeforeDelete(id, entity); veto != nil {
+			return veto
+		}
+	}
+
+	result := h.Service.Delete(id)
+
+	if hook, ok := entity.(AfterDeleteHook); ok {
+		hook.AfterDelete(result)
+	}
+	if h.Hooks.AfterDelete != nil {
+		h.Hooks.AfterDelete(id, entity, result)
+	}
+	return result
+}
+
+// deleteHooks tells whether there are hooks to run around Delete.
+func (h *HookService) deleteHooks() bool {
+	if h.Hooks.BeforeDelete != nil || h.Hooks.AfterDelete != nil || h.CreateFunc == nil {
+		return true
+	}
+	return entityHasHooks(h.CreateFunc(), false)
+}
+
+// load gets the stored entity, or the result to reply with if it can't be loaded. Values that aren't entities are
+// passed to the hooks as nil.
+func (h *HookService) load(id EntityKey) (Entity, OperationResult) {
+	result := h.Service.GetByID(id)
+	if result.State() != Ok {
+		return nil, result
+	}
+	entity, _ := result.Value().(Entity)
+	return entity, nil
+}
+
+// entityHasHooks tells whether the entity implements any hook around mutations, or around Delete only.
+func entityHasHooks(entity Entity, anyMutation bool) bool {
+	switch entity.(type) {
+	case BeforeDeleteHook, AfterDeleteHook:
+		return true
+	case BeforeAddHook, AfterAddHook, BeforeUpdateHook, AfterUpdateHook:
+		return anyMutation
+	}
+	return false
+}
+
+// MutatingService returns the Service to perform the mutations of a top-level resource with, so that its hooks are run:
+// its Service, wrapped with a HookService if the resource or its entities have hooks. Adapters exposing the resource
+// other than through the registry should mutate it through this Service.
+func (r *Resource) MutatingService() Service {
+	return r.hooked(r.Service)
+}
+
+// hooked wraps the Service performing mutations of the resource with a HookService, if the resource or its entities
+// have hooks.
+func (r *Resource) hooked(svc Service) Service {
+	if r.Hooks == nil && (r.CreateFunc == nil || !entityHasHooks(r.CreateFunc(), true)) {
+		return svc
+	}
+	return NewHookService(svc, r.Hooks, r.CreateFunc)
+}
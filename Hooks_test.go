@@ -0,0 +1,208 @@
+package crud_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// lockableEntity implements all hooks, recording the ones run in events
+type lockableEntity struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Locked    bool   `json:"locked"`
+	CreatedBy string `json:"createdBy"`
+
+	events *[]string
+}
+
+func (e *lockableEntity) Validate() error {
+	return nil
+}
+
+func (e *lockableEntity) Format(isNewEntity bool) {}
+
+func (e *lockableEntity) record(event string) {
+	if e.events != nil {
+		*e.events = append(*e.events, event)
+	}
+}
+
+func (e *lockableEntity) BeforeAdd() crud.OperationResult {
+	e.record("BeforeAdd")
+	e.CreatedBy = "system"
+	return nil
+}
+
+func (e *lockableEntity) AfterAdd(result crud.OperationResult) {
+	e.record("AfterAdd " + result.State().String())
+}
+
+func (e *lockableEntity) BeforeUpdate(old crud.Entity) crud.OperationResult {
+	e.record("BeforeUpdate " + old.(*lockableEntity).Name)
+	if old.(*lockableEntity).Locked {
+		return crud.ConflictResult(errors.New("The entity is locked"))
+	}
+	return nil
+}
+
+func (e *lockableEntity) AfterUpdate(old crud.Entity, result crud.OperationResult) {
+	e.record("AfterUpdate " + result.State().String())
+}
+
+func (e *lockableEntity) BeforeDelete() crud.OperationResult {
+	if e.Locked {
+		return crud.ConflictResult(errors.New("The entity is locked"))
+	}
+	return nil
+}
+
+func (e *lockableEntity) AfterDelete(result crud.OperationResult) {}
+
+// lockableService stores lockableEntities
+type lockableService struct {
+	lastID   int
+	entities map[string]*lockableEntity
+}
+
+func newLockableService() *lockableService {
+	return &lockableService{entities: make(map[string]*lockableEntity)}
+}
+
+func (s *lockableService) GetAll(request *crud.DataSetRequest) crud.OperationResult {
+	return crud.NotSupportedByResourceResult()
+}
+
+func (s *lockableService) GetByID(id crud.EntityKey) crud.OperationResult {
+	if e, ok := s.entities[fmt.Sprint(id)]; ok {
+		stored := *e
+		return crud.OkResult(&stored)
+	}
+	return crud.NotFoundResult()
+}
+
+func (s *lockableService) Add(entity crud.Entity) crud.OperationResult {
+	s.lastID++
+	e := *entity.(*lockableEntity)
+	e.ID = s.lastID
+	e.events = nil
+	s.entities[fmt.Sprint(e.ID)] = &e
+	return crud.CreatedWithIDResult(e.ID)
+}
+
+func (s *lockableService) Update(id crud.EntityKey, entity crud.Entity) crud.OperationResult {
+	if _, ok := s.entities[fmt.Sprint(id)]; !ok {
+		return crud.NotFoundResult()
+	}
+	e := *entity.(*lockableEntity)
+	e.events = nil
+	s.entities[fmt.Sprint(id)] = &e
+	return crud.OkResult(&e)
+}
+
+func (s *lockableService) Delete(id crud.EntityKey) crud.OperationResult {
+	if _, ok := s.entities[fmt.Sprint(id)]; !ok {
+		return crud.NotFoundResult()
+	}
+	delete(s.entities, fmt.Sprint(id))
+	return crud.OkResult(nil)
+}
+
+func TestHookService_EntityHooks(t *testing.T) {
+	backing := newLockableService()
+	svc := crud.NewHookService(backing, nil, func() crud.Entity { return &lockableEntity{} })
+	events := make([]string, 0)
+
+	assert.Equal(t, crud.Created, svc.Add(&lockableEntity{Name: "first", events: &events}).State())
+	assert.Equal(t, "system", backing.entities["1"].CreatedBy)
+
+	assert.Equal(t, crud.Ok, svc.Update(1, &lockableEntity{Name: "second", Locked: true, events: &events}).State())
+	assert.Equal(t, []string{"BeforeAdd", "AfterAdd Created", "BeforeUpdate first", "AfterUpdate Ok"}, events)
+
+	// Locked entities can't be changed anymore
+	result := svc.Update(1, &lockableEntity{Name: "third"})
+	assert.Equal(t, crud.Conflict, result.State())
+	assert.Equal(t, "The entity is locked", result.Error().Error())
+	assert.Equal(t, crud.Conflict, svc.Delete(1).State())
+	assert.Equal(t, "second", backing.entities["1"].Name)
+
+	assert.Equal(t, crud.NotFound, svc.Update(42, &lockableEntity{Name: "third"}).State())
+	assert.Equal(t, crud.NotFound, svc.Delete(42).State())
+}
+
+func TestHookService_ResourceHooks(t *testing.T) {
+	events := make([]string, 0)
+	hooks := &crud.Hooks{
+		BeforeAdd: func(entity crud.Entity) crud.OperationResult {
+			if entity.(*testEntity).Name == "forbidden" {
+				return crud.ValidationFailedResult(errors.New("Forbidden name"))
+			}
+			entity.(*testEntity).Status = "new"
+			return nil
+		},
+		AfterAdd: func(entity crud.Entity, result crud.OperationResult) {
+			events = append(events, fmt.Sprintf("AfterAdd %v", result.Value()))
+		},
+		BeforeUpdate: func(id crud.EntityKey, old, entity crud.Entity) crud.OperationResult {
+			entity.(*testEntity).Status = old.(*testEntity).Status
+			return nil
+		},
+		AfterUpdate: func(id crud.EntityKey, old, entity crud.Entity, result crud.OperationResult) {
+			events = append(events, fmt.Sprintf("AfterUpdate %v %v->%v", id, old.(*testEntity).Name, entity.(*testEntity).Name))
+		},
+		BeforeDelete: func(id crud.EntityKey, entity crud.Entity) crud.OperationResult {
+			if entity.(*testEntity).Status == "new" {
+				return crud.NotSupportedByResourceResult()
+			}
+			return nil
+		},
+		AfterDelete: func(id crud.EntityKey, entity crud.Entity, result crud.OperationResult) {
+			events = append(events, fmt.Sprintf("AfterDelete %v", id))
+		},
+	}
+	backing := newMemoryService()
+	svc := crud.NewHookService(backing, hooks, newTestEntity)
+
+	assert.Equal(t, crud.ValidationFailed, svc.Add(&testEntity{Name: "forbidden"}).State())
+	assert.Equal(t, crud.Created, svc.Add(&testEntity{Name: "first"}).State())
+	assert.Equal(t, crud.Ok, svc.Update(1, &testEntity{Name: "second"}).State())
+	assert.Equal(t, "new", backing.entities["1"].Status)
+	assert.Equal(t, crud.NotSupportedByResource, svc.Delete(1).State())
+
+	backing.entities["1"].Status = "done"
+	assert.Equal(t, crud.Ok, svc.Delete(1).State())
+	assert.Equal(t, []string{"AfterAdd 1", "AfterUpdate 1 first->second", "AfterDelete 1"}, events)
+}
+
+func TestRegistry_RouteWithHooks(t *testing.T) {
+	registry := crud.NewRegistry(newTestContext(), crud.Recovery)
+	registry.BasePath = "/api/"
+	backing := newLockableService()
+	registry.Register(&crud.Resource{Name: "documents", Service: backing, CreateFunc: func() crud.Entity { return &lockableEntity{} }})
+	registry.Register(&crud.Resource{
+		Name:       "things",
+		Service:    newMemoryService(),
+		CreateFunc: newTestEntity,
+		Hooks: &crud.Hooks{
+			BeforeDelete: func(id crud.EntityKey, entity crud.Entity) crud.OperationResult {
+				return crud.ConflictResult(errors.New("Things can't be deleted"))
+			},
+		},
+	})
+	router := mux.NewRouter()
+	registry.Route(router)
+
+	assert.Equal(t, http.StatusCreated, serve(router, "POST", "/api/documents", `{"name": "first", "locked": true}`).Code)
+	assert.Equal(t, "system", backing.entities["1"].CreatedBy)
+	w := serve(router, "PUT", "/api/documents/1", `{"name": "second"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"message": "The entity is locked"}`, w.Body.String())
+
+	assert.Equal(t, http.StatusCreated, serve(router, "POST", "/api/things", `{"name": "first"}`).Code)
+	assert.Equal(t, http.StatusConflict, serve(router, "DELETE", "/api/things/1", "").Code)
+}
@@ -0,0 +1,270 @@
+package crud
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header holding the idempotency key chosen by the client
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses that are replayed for a repeated idempotency key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// DefaultIdempotencyTTL is how long idempotency keys are remembered by default.
+var DefaultIdempotencyTTL = 24 * time.Hour
+
+// DefaultIdempotencyLockTimeout is how long a key is reserved by default for a request being processed. Once it's over,
+// the reservation may be taken over by a repeated request, in case the instance processing the first one died.
+var DefaultIdempotencyLockTimeout = time.Minute
+
+// MaxIdempotencyKeyLength is the maximum length of an idempotency key.
+var MaxIdempotencyKeyLength = 255
+
+// IdempotencyRecord is what an IdempotencyStore remembers about a request with an idempotency key
+type IdempotencyRecord struct {
+	// Key is the idempotency key.
+	Key string
+	// Fingerprint identifies the request the key was first used for.
+	Fingerprint string
+	// Completed is false while the request is being processed.
+	Completed bool
+	// StatusCode is the status code of the response, once completed.
+	StatusCode int
+	// Header holds the headers of the response, once completed.
+	Header http.Header
+	// Body is the body of the response, once completed.
+	Body []byte
+	// ExpiresAt is the moment the key is forgotten.
+	ExpiresAt time.Time
+	// LockedUntil is the moment the reservation of a key that isn't completed may be taken over.
+	LockedUntil time.Time
+}
+
+// reserved tells whether the key of the record can't be reserved at the given moment.
+func (r *IdempotencyRecord) reserved(now time.Time) bool {
+	return now.Before(r.ExpiresAt) && (r.Completed || now.Before(r.LockedUntil))
+}
+
+// IdempotencyStore remembers requests with idempotency keys, and their responses, until they expire
+type IdempotencyStore interface {
+	// Begin reserves the key for the request with the given fingerprint. If the key is already completed, or reserved
+	// and its lock timeout isn't over yet, nothing is reserved and the existing record is returned instead.
+	Begin(key, fingerprint string) (*IdempotencyRecord, error)
+	// Complete stores the response for a reserved key.
+	Complete(record *IdempotencyRecord) error
+	// Release forgets a reserved key, so the request can be retried.
+	Release(key string) error
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore that keeps its records in memory. It's only suitable for services
+// running a single instance.
+type MemoryIdempotencyStore struct {
+	// Now returns the current time. Replaceable for testing purposes; defaults to time.Now.
+	Now func() time.Time
+	// TTL is how long keys are remembered.
+	TTL time.Duration
+	// LockTimeout is how long a key is reserved for a request being processed. Defaults to DefaultIdempotencyLockTimeout.
+	LockTimeout time.Duration
+
+	mutex   sync.Mutex
+	records map[string]*IdempotencyRecord
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory store remembering keys for the given duration.
+func NewMemoryIdempotencyStore(ttl time.Duration) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		Now:         time.Now,
+		TTL:         ttl,
+		LockTimeout: DefaultIdempotencyLockTimeout,
+		records:     make(map[string]*IdempotencyRecord),
+	}
+}
+
+// Begin reserves the key, unless it's already known
+func (s *MemoryIdempotencyStore) Begin(key, fingerprint string) (*IdempotencyRecord, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.Now()
+	if existing, ok := s.records[key]; ok && existing.reserved(now) {
+		record := *existing
+		return &record, nil
+	}
+	s.records[key] = &IdempotencyRecord{
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(s.TTL),
+		LockedUntil: now.Add(s.LockTimeout),
+	}
+	return nil, nil
+}
+
+// Complete stores the response for a reserved key
+func (s *MemoryIdempotencyStore) Complete(record *IdempotencyRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	existing, ok := s.records[record.Key]
+	if !ok {
+		return errors.New("Idempotency key is not reserved: " + record.Key)
+	}
+	existing.Completed = true
+	existing.StatusCode = record.StatusCode
+	existing.Header = record.Header
+	existing.Body = record.Body
+	return nil
+}
+
+// Release forgets a reserved key
+func (s *MemoryIdempotencyStore) Release(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// DeleteExpired forgets all expired keys. Expired keys are ignored anyway; this only frees up memory.
+func (s *MemoryIdempotencyStore) DeleteExpired() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.Now()
+	for key, record := range s.records {
+		if !now.Before(record.ExpiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
+
+// WithIdempotency makes a handler idempotent for requests carrying an Idempotency-Key header. The response to the first
+// request with a key is stored, and replayed for repeated requests with that key. Reusing a key for a different request,
+// or while the first request is still being processed, results in Conflict. Requests without the header are passed on
+// as is. The status code, headers and body of responses are replayed; responses with a 5xx status code aren't stored,
+// so those requests can be retried. If the handler panics, or the response can't be stored, the key is released as
+// well; failures of the store are logged.
+func WithIdempotency(ctx servicefoundation.AppContext, store IdempotencyStore, handler http.HandlerFunc) http.HandlerFunc {
+	logger := ctx.Logger()
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			handler(w, r)
+			return
+		}
+		if len(key) > MaxIdempotencyKeyLength {
+			WriteOperationResult(w, r, ValidationFailedResult(errors.New("Idempotency key is too long")))
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			WriteOperationResult(w, r, ValidationFailedResult(errors.New("Failed to read HTTP body: "+err.Error())))
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(r, body)
+
+		existing, err := store.Begin(key, fingerprint)
+		if err != nil {
+			WriteOperationResult(w, r, ErrorResult(err))
+			return
+		}
+		if existing != nil {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				WriteOperationResult(w, r, ConflictResult(errors.New("Idempotency key was already used for a different request")))
+			case !existing.Completed:
+				WriteOperationResult(w, r, ConflictResult(errors.New("A request with this idempotency key is still being processed")))
+			default:
+				for name, values := range existing.Header {
+					w.Header()[name] = values
+				}
+				w.Header().Set(IdempotentReplayedHeader, "true")
+				w.WriteHeader(existing.StatusCode)
+				w.Write(existing.Body)
+			}
+			return
+		}
+
+		// Release the key unless the response is stored, also when the handler panics, so the request can be retried
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := store.Release(key); err != nil {
+				logger.Error("IdempotencyRelease", fmt.Sprintf("Failed to release idempotency key %v: %v", key, err))
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		handler(recorder, r)
+
+		if recorder.statusCode >= http.StatusInternalServerError {
+			return
+		}
+		err = store.Complete(&IdempotencyRecord{
+			Key:         key,
+			Fingerprint: fingerprint,
+			Completed:   true,
+			StatusCode:  recorder.statusCode,
+			Header:      cloneHeader(w.Header()),
+			Body:        recorder.body.Bytes(),
+		})
+		if err != nil {
+			logger.Error("IdempotencyComplete", fmt.Sprintf("Failed to store the response for idempotency key %v: %v", key, err))
+			return
+		}
+		completed = true
+	}
+}
+
+// cloneHeader returns a copy of the given headers.
+func cloneHeader(header http.Header) http.Header {
+	clone := make(http.Header, len(header))
+	for name, values := range header {
+		clone[name] = append([]string(nil), values...)
+	}
+	return clone
+}
+
+// requestFingerprint identifies a request by its method, path and body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder passes a response on, while keeping a copy of its status code and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(statusCode int) {
+	if !rr.wroteHeader {
+		rr.statusCode = statusCode
+		rr.wroteHeader = true
+	}
+	rr.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.wroteHeader = true
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
@@ -0,0 +1,136 @@
+package crud
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLIdempotencyStore is an IdempotencyStore that keeps its records in a SQL table, so that keys are shared between
+// instances of a service. The table can be created using CreateTable.
+type SQLIdempotencyStore struct {
+	// Now returns the current time. Replaceable for testing purposes; defaults to time.Now.
+	Now func() time.Time
+	// TTL is how long keys are remembered.
+	TTL time.Duration
+	// LockTimeout is how long a key is reserved for a request being processed. Defaults to DefaultIdempotencyLockTimeout.
+	LockTimeout time.Duration
+	// BindVar returns the placeholder for the n-th (one-based) query argument. Defaults to "?"; use DollarBindVar for
+	// PostgreSQL.
+	BindVar func(n int) string
+	// BinaryType is the column type used by CreateTable for the stored response bodies. Defaults to "BLOB"; use "BYTEA"
+	// for PostgreSQL.
+	BinaryType string
+
+	db    *sql.DB
+	table string
+}
+
+// DollarBindVar returns PostgreSQL style placeholders: $1, $2 and so on.
+func DollarBindVar(n int) string {
+	return fmt.Sprintf("$%v", n)
+}
+
+// NewSQLIdempotencyStore creates a store using the given table, remembering keys for the given duration.
+func NewSQLIdempotencyStore(db *sql.DB, table string, ttl time.Duration) *SQLIdempotencyStore {
+	return &SQLIdempotencyStore{
+		Now:         time.Now,
+		TTL:         ttl,
+		LockTimeout: DefaultIdempotencyLockTimeout,
+		BindVar:     func(int) string { return "?" },
+		BinaryType:  "BLOB",
+		db:          db,
+		table:       table,
+	}
+}
+
+// CreateTable creates the table of the store, if it doesn't exist yet. Expiry and lock times are stored as Unix
+// nanoseconds, response headers as JSON.
+func (s *SQLIdempotencyStore) CreateTable() error {
+	_, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS ` + s.table + ` (
+		idempotency_key VARCHAR(255) NOT NULL PRIMARY KEY,
+		fingerprint VARCHAR(64) NOT NULL,
+		completed INTEGER NOT NULL,
+		status_code INTEGER NOT NULL,
+		headers TEXT NOT NULL,
+		body ` + s.BinaryType + `,
+		expires_at BIGINT NOT NULL,
+		locked_until BIGINT NOT NULL
+	)`)
+	return err
+}
+
+// Begin reserves the key, unless it's already known. Reservations rely on the primary key of the table, so concurrent
+// requests with the same key can't both reserve it. Expired keys, and reservations whose lock timeout is over, are
+// deleted first.
+func (s *SQLIdempotencyStore) Begin(key, fingerprint string) (*IdempotencyRecord, error) {
+	now := s.Now()
+	_, err := s.db.Exec(s.query("DELETE FROM %v WHERE idempotency_key = %v AND (expires_at <= %v OR (completed = 0 AND locked_until <= %v))"),
+		key, now.UnixNano(), now.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+
+	_, insertErr := s.db.Exec(
+		s.query("INSERT INTO %v (idempotency_key, fingerprint, completed, status_code, headers, body, expires_at, locked_until) VALUES (%v, %v, %v, %v, %v, %v, %v, %v)"),
+		key, fingerprint, 0, 0, "{}", []byte{}, now.Add(s.TTL).UnixNano(), now.Add(s.LockTimeout).UnixNano())
+	if insertErr == nil {
+		return nil, nil
+	}
+
+	// The key exists, unless inserting failed for another reason
+	record := &IdempotencyRecord{Key: key}
+	var completed int
+	var headers string
+	var expiresAt, lockedUntil int64
+	err = s.db.QueryRow(s.query("SELECT fingerprint, completed, status_code, headers, body, expires_at, locked_until FROM %v WHERE idempotency_key = %v"), key).
+		Scan(&record.Fingerprint, &completed, &record.StatusCode, &headers, &record.Body, &expiresAt, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return nil, insertErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(headers), &record.Header); err != nil {
+		return nil, err
+	}
+	record.Completed = completed != 0
+	record.ExpiresAt = time.Unix(0, expiresAt)
+	record.LockedUntil = time.Unix(0, lockedUntil)
+	return record, nil
+}
+
+// Complete stores the response for a reserved key
+func (s *SQLIdempotencyStore) Complete(record *IdempotencyRecord) error {
+	headers, err := json.Marshal(record.Header)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.query("UPDATE %v SET completed = %v, status_code = %v, headers = %v, body = %v WHERE idempotency_key = %v"),
+		1, record.StatusCode, string(headers), record.Body, record.Key)
+	return err
+}
+
+// Release forgets a reserved key
+func (s *SQLIdempotencyStore) Release(key string) error {
+	_, err := s.db.Exec(s.query("DELETE FROM %v WHERE idempotency_key = %v"), key)
+	return err
+}
+
+// DeleteExpired removes all expired keys from the table. Expired keys are ignored anyway; this only frees up space.
+func (s *SQLIdempotencyStore) DeleteExpired() error {
+	_, err := s.db.Exec(s.query("DELETE FROM %v WHERE expires_at <= %v"), s.Now().UnixNano())
+	return err
+}
+
+// query fills in the table name and the placeholders of a query. The first %v is the table name, the others are
+// placeholders.
+func (s *SQLIdempotencyStore) query(format string) string {
+	args := []interface{}{s.table}
+	for n := 1; n < strings.Count(format, "%v"); n++ {
+		args = append(args, s.BindVar(n))
+	}
+	return fmt.Sprintf(format, args...)
+}
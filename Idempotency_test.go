@@ -0,0 +1,382 @@
+package crud_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newIdempotentCreateHandler(store crud.IdempotencyStore) (http.HandlerFunc, *memoryService) {
+	svc := newMemoryService()
+	handler := crud.CreateCrudHandlerCreateEntity(newTestContext(), svc, "things", noRecovery, newTestEntity)
+	return crud.WithIdempotency(newTestContext(), store, handler), svc
+}
+
+func postWithKey(handler http.HandlerFunc, key, body string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("POST", "/things", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(crud.IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestWithIdempotency_ReplaysResponse(t *testing.T) {
+	handler, svc := newIdempotentCreateHandler(crud.NewMemoryIdempotencyStore(time.Hour))
+
+	first := postWithKey(handler, "abc", `{"name":"first"}`)
+	second := postWithKey(handler, "abc", `{"name":"first"}`)
+
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "true", second.Header().Get(crud.IdempotentReplayedHeader))
+	assert.Equal(t, 1, len(svc.entities))
+}
+
+func TestWithIdempotency_ConflictOnDifferentPayload(t *testing.T) {
+	handler, svc := newIdempotentCreateHandler(crud.NewMemoryIdempotencyStore(time.Hour))
+
+	postWithKey(handler, "abc", `{"name":"first"}`)
+	w := postWithKey(handler, "abc", `{"name":"second"}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, 1, len(svc.entities))
+}
+
+func TestWithIdempotency_WithoutKey(t *testing.T) {
+	handler, svc := newIdempotentCreateHandler(crud.NewMemoryIdempotencyStore(time.Hour))
+
+	postWithKey(handler, "", `{"name":"first"}`)
+	postWithKey(handler, "", `{"name":"first"}`)
+
+	assert.Equal(t, 2, len(svc.entities))
+}
+
+func TestWithIdempotency_ExpiresKeys(t *testing.T) {
+	store := crud.NewMemoryIdempotencyStore(90 * time.Minute)
+	store.Now = newSteppingClock(historyStart)
+	handler, svc := newIdempotentCreateHandler(store)
+
+	postWithKey(handler, "abc", `{"name":"first"}`) // 13:00, expires 14:30
+	postWithKey(handler, "abc", `{"name":"first"}`) // 14:00, replayed
+	postWithKey(handler, "abc", `{"name":"first"}`) // 15:00, expired
+
+	assert.Equal(t, 2, len(svc.entities))
+}
+
+func TestWithIdempotency_ReleasesKeyOnServerError(t *testing.T) {
+	calls := 0
+	handler := crud.WithIdempotency(newTestContext(), crud.NewMemoryIdempotencyStore(time.Hour), func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		crud.WriteOperationResult(w, r, crud.ErrorResult(errors.New("unavailable")))
+	})
+
+	postWithKey(handler, "abc", `{}`)
+	w := postWithKey(handler, "abc", `{}`)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, 2, calls)
+}
+
+func TestWithIdempotency_ConflictWhileInProgress(t *testing.T) {
+	store := crud.NewMemoryIdempotencyStore(time.Hour)
+	var inner *httptest.ResponseRecorder
+	handler := crud.WithIdempotency(newTestContext(), store, func(w http.ResponseWriter, r *http.Request) {
+		inner = postWithKey(crud.WithIdempotency(newTestContext(), store, okHandler), "abc", `{}`)
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	postWithKey(handler, "abc", `{}`)
+
+	assert.Equal(t, http.StatusConflict, inner.Code)
+}
+
+func TestWithIdempotency_ReleasesKeyOnPanic(t *testing.T) {
+	calls := 0
+	handler := crud.WithIdempotency(newTestContext(), crud.NewMemoryIdempotencyStore(time.Hour), func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			panic("unavailable")
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	assert.Panics(t, func() { postWithKey(handler, "abc", `{}`) })
+	w := postWithKey(handler, "abc", `{}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 2, calls)
+}
+
+// failingCompleteStore is an IdempotencyStore failing to store responses
+type failingCompleteStore struct {
+	*crud.MemoryIdempotencyStore
+}
+
+func (s *failingCompleteStore) Complete(record *crud.IdempotencyRecord) error {
+	return errors.New("unavailable")
+}
+
+func TestWithIdempotency_ReleasesKeyWhenCompleteFails(t *testing.T) {
+	calls := 0
+	handler := crud.WithIdempotency(newTestContext(), &failingCompleteStore{crud.NewMemoryIdempotencyStore(time.Hour)}, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	postWithKey(handler, "abc", `{}`)
+	w := postWithKey(handler, "abc", `{}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 2, calls)
+}
+
+// acceptingHandler accepts requests, to be processed asynchronously
+func acceptingHandler(calls *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Location", "/operations/42")
+		w.Header().Add("X-Note", "first")
+		w.Header().Add("X-Note", "second")
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func TestWithIdempotency_ReplaysHeaders(t *testing.T) {
+	calls := 0
+	handler := crud.WithIdempotency(newTestContext(), crud.NewMemoryIdempotencyStore(time.Hour), acceptingHandler(&calls))
+
+	postWithKey(handler, "abc", `{}`)
+	w := postWithKey(handler, "abc", `{}`)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "/operations/42", w.Header().Get("Location"))
+	assert.Equal(t, []string{"first", "second"}, w.Header()["X-Note"])
+	assert.Equal(t, 1, calls)
+}
+
+func TestWithIdempotency_TakesOverAfterLockTimeout(t *testing.T) {
+	store := crud.NewMemoryIdempotencyStore(90 * time.Minute)
+	store.Now = newSteppingClock(historyStart)
+	store.LockTimeout = 30 * time.Minute
+	// A request reserving the key, and never completing
+	_, err := store.Begin("abc", "unknown") // 13:00, locked until 13:30
+	assert.Nil(t, err)
+	calls := 0
+	handler := crud.WithIdempotency(newTestContext(), store, acceptingHandler(&calls))
+
+	w := postWithKey(handler, "abc", `{}`) // 14:00
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, 1, calls)
+}
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestRegistry_RouteWithIdempotency(t *testing.T) {
+	registry := newTestRegistry()
+	svc := newMemoryService()
+	registry.Register(&crud.Resource{
+		Name:        "things",
+		Service:     svc,
+		CreateFunc:  newTestEntity,
+		Idempotency: crud.NewMemoryIdempotencyStore(time.Hour),
+	})
+	router := mux.NewRouter()
+	registry.Route(router)
+
+	for i := 0; i < 2; i++ {
+		r, _ := http.NewRequest("POST", "/api/things", strings.NewReader(`{"name":"first"}`))
+		r.Header.Set(crud.IdempotencyKeyHeader, "abc")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+	assert.Equal(t, 1, len(svc.entities))
+}
+
+func TestSQLIdempotencyStore(t *testing.T) {
+	db := openIdempotencyDB(t)
+	store := crud.NewSQLIdempotencyStore(db, "idempotency", 150*time.Minute)
+	store.Now = newSteppingClock(historyStart)
+	assert.Nil(t, store.CreateTable())
+	handler, svc := newIdempotentCreateHandler(store)
+
+	first := postWithKey(handler, "abc", `{"name":"first"}`)    // 13:00, expires 15:30
+	second := postWithKey(handler, "abc", `{"name":"first"}`)   // 14:00, replayed
+	conflict := postWithKey(handler, "abc", `{"name":"other"}`) // 15:00
+	postWithKey(handler, "abc", `{"name":"first"}`)             // 16:00, expired
+
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "true", second.Header().Get(crud.IdempotentReplayedHeader))
+	assert.Equal(t, http.StatusConflict, conflict.Code)
+	assert.Equal(t, 2, len(svc.entities))
+}
+
+func TestSQLIdempotencyStore_ReplaysHeaders(t *testing.T) {
+	store := crud.NewSQLIdempotencyStore(openIdempotencyDB(t), "idempotency", time.Hour)
+	calls := 0
+	handler := crud.WithIdempotency(newTestContext(), store, acceptingHandler(&calls))
+
+	postWithKey(handler, "abc", `{}`)
+	w := postWithKey(handler, "abc", `{}`)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "/operations/42", w.Header().Get("Location"))
+	assert.Equal(t, []string{"first", "second"}, w.Header()["X-Note"])
+	assert.Equal(t, 1, calls)
+}
+
+func TestSQLIdempotencyStore_TakesOverAfterLockTimeout(t *testing.T) {
+	store := crud.NewSQLIdempotencyStore(openIdempotencyDB(t), "idempotency", 90*time.Minute)
+	now := historyStart
+	store.Now = func() time.Time { return now }
+	store.LockTimeout = 30 * time.Minute
+
+	_, err := store.Begin("abc", "first")
+	assert.Nil(t, err)
+	now = now.Add(time.Hour)
+	existing, err := store.Begin("abc", "second")
+	assert.Nil(t, err)
+	assert.Nil(t, existing)
+	now = now.Add(time.Minute)
+	existing, err = store.Begin("abc", "third")
+	assert.Nil(t, err)
+	if assert.NotNil(t, existing) {
+		assert.Equal(t, "second", existing.Fingerprint)
+	}
+}
+
+func TestSQLIdempotencyStore_BinaryType(t *testing.T) {
+	db := openIdempotencyDB(t)
+	store := crud.NewSQLIdempotencyStore(db, "idempotency", time.Hour)
+	store.BinaryType = "BYTEA"
+
+	assert.Nil(t, store.CreateTable())
+	assert.Contains(t, testIdempotencyDriver.createQuery, "body BYTEA,")
+}
+
+// idempotencyDriver is a database/sql driver understanding just the queries of SQLIdempotencyStore
+type idempotencyDriver struct {
+	mutex       sync.Mutex
+	rows        map[string][]driver.Value
+	createQuery string
+}
+
+var testIdempotencyDriver = &idempotencyDriver{rows: make(map[string][]driver.Value)}
+
+// openIdempotencyDB opens a database of the test driver, without any rows
+func openIdempotencyDB(t *testing.T) *sql.DB {
+	testIdempotencyDriver.mutex.Lock()
+	testIdempotencyDriver.rows = make(map[string][]driver.Value)
+	testIdempotencyDriver.mutex.Unlock()
+
+	db, err := sql.Open("idempotencytest", "")
+	assert.Nil(t, err)
+	return db
+}
+
+func init() {
+	sql.Register("idempotencytest", testIdempotencyDriver)
+}
+
+func (d *idempotencyDriver) Open(name string) (driver.Conn, error) {
+	return &idempotencyConn{driver: d}, nil
+}
+
+type idempotencyConn struct {
+	driver *idempotencyDriver
+}
+
+func (c *idempotencyConn) Prepare(query string) (driver.Stmt, error) {
+	return &idempotencyStmt{driver: c.driver, query: query}, nil
+}
+
+func (c *idempotencyConn) Close() error              { return nil }
+func (c *idempotencyConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type idempotencyStmt struct {
+	driver *idempotencyDriver
+	query  string
+}
+
+func (s *idempotencyStmt) Close() error  { return nil }
+func (s *idempotencyStmt) NumInput() int { return -1 }
+
+func (s *idempotencyStmt) Exec(args []driver.Value) (driver.Result, error) {
+	d := s.driver
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	switch {
+	case strings.HasPrefix(s.query, "CREATE TABLE"):
+		d.createQuery = s.query
+	case strings.HasPrefix(s.query, "INSERT"):
+		key := args[0].(string)
+		if _, ok := d.rows[key]; ok {
+			return nil, errors.New("duplicate key")
+		}
+		d.rows[key] = args[1:]
+	case strings.HasPrefix(s.query, "UPDATE"):
+		row := d.rows[args[4].(string)]
+		row[1], row[2], row[3], row[4] = args[0], args[1], args[2], args[3]
+	case strings.Contains(s.query, "idempotency_key = ? AND (expires_at"):
+		key := args[0].(string)
+		if row, ok := d.rows[key]; ok && (row[5].(int64) <= args[1].(int64) || row[1].(int64) == 0 && row[6].(int64) <= args[2].(int64)) {
+			delete(d.rows, key)
+		}
+	case strings.HasPrefix(s.query, "DELETE FROM idempotency WHERE idempotency_key"):
+		delete(d.rows, args[0].(string))
+	default:
+		return nil, errors.New("unexpected query: " + s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *idempotencyStmt) Query(args []driver.Value) (driver.Rows, error) {
+	d := s.driver
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	row, ok := d.rows[args[0].(string)]
+	if !ok {
+		return &idempotencyRows{}, nil
+	}
+	return &idempotencyRows{row: append([]driver.Value{}, row...)}, nil
+}
+
+type idempotencyRows struct {
+	row []driver.Value
+}
+
+func (r *idempotencyRows) Columns() []string {
+	return []string{"fingerprint", "completed", "status_code", "headers", "body", "expires_at", "locked_until"}
+}
+
+func (r *idempotencyRows) Close() error { return nil }
+
+func (r *idempotencyRows) Next(dest []driver.Value) error {
+	if r.row == nil {
+		return io.EOF
+	}
+	copy(dest, r.row)
+	r.row = nil
+	return nil
+}
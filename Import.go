@@ -0,0 +1,236 @@
+package crud
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ImportAction describes what an import did with a row
+type ImportAction string
+
+const (
+	// ImportCreated means the row was added as a new entity
+	ImportCreated ImportAction = "created"
+	// ImportUpdated means the row updated an existing entity
+	ImportUpdated ImportAction = "updated"
+	// ImportFailed means the row was rejected
+	ImportFailed ImportAction = "failed"
+)
+
+// MaxImportRows is the maximum number of rows in a single import.
+var MaxImportRows = 10000
+
+// ImportRowResult reports the outcome of importing a single row
+type ImportRowResult struct {
+	// Line is the line of the row in the CSV, the header being line 1.
+	Line int `json:"line"`
+	// Action is what was done with the row. In a dry run, it's what would have been done.
+	Action ImportAction `json:"action"`
+	// ID is the ID of the entity, if known.
+	ID EntityKey `json:"id,omitempty"`
+	// Error describes why the row was rejected.
+	Error *ErrorDetails `json:"error,omitempty"`
+}
+
+// ImportReport reports the outcome of an import, row by row
+type ImportReport struct {
+	// DryRun is true if nothing was actually added or updated.
+	DryRun bool `json:"dryRun"`
+	// Total is the number of rows.
+	Total int `json:"total"`
+	// Succeeded is the number of rows that were (or would have been) added or updated.
+	Succeeded int `json:"succeeded"`
+	// Failed is the number of rows that were rejected.
+	Failed int `json:"failed"`
+	// Rows holds the outcome of every row.
+	Rows []ImportRowResult `json:"rows"`
+}
+
+// ImportCSV imports entities from CSV. The header names the JSON fields the columns map to, ignoring case. Every row is
+// decoded into an entity created by createFunc and passed through the pipeline. Rows with the ID of an existing entity
+// update it; others are added. In a dry run, the rows are checked, but nothing is added or updated.
+//
+// Problems with individual rows are reported in the report; the error is only set if the CSV can't be imported at all,
+// e.g. when it has more than MaxImportRows rows, in which case nothing is imported.
+func ImportCSV(svc Service, r io.Reader, createFunc func() Entity, pipeline *EntityPipeline, dryRun bool) (*ImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV is empty")
+	}
+	if err != nil {
+		return nil, errors.New("Failed to read CSV header: " + err.Error())
+	}
+	columns, err := importColumns(header, entityType(createFunc))
+	if err != nil {
+		return nil, err
+	}
+
+	// All rows are read before importing any, so that nothing is imported from a CSV that has too many rows
+	type csvRow struct {
+		record []string
+		err    error
+	}
+	rows := make([]csvRow, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if len(rows) == MaxImportRows {
+			return nil, fmt.Errorf("CSV has more than %v rows", MaxImportRows)
+		}
+		rows = append(rows, csvRow{record: record, err: err})
+	}
+
+	report := &ImportReport{DryRun: dryRun, Total: len(rows), Rows: make([]ImportRowResult, 0, len(rows))}
+	for i, csvRow := range rows {
+		var row ImportRowResult
+		if csvRow.err != nil {
+			row = ImportRowResult{Action: ImportFailed, Error: &ErrorDetails{Message: csvRow.err.Error()}}
+		} else {
+			row = importRow(svc, columns, csvRow.record, createFunc, pipeline, dryRun)
+		}
+		row.Line = i + 2
+		if row.Action == ImportFailed {
+			report.Failed++
+		} else {
+			report.Succeeded++
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	return report, nil
+}
+
+// importColumn is a column of the CSV, mapped to a field of the entity
+type importColumn struct {
+	name string
+	kind reflect.Kind
+}
+
+// importColumns maps the header of the CSV to the fields of the entity type. For entities that aren't structs, the
+// header is taken as is.
+func importColumns(header []string, t reflect.Type) ([]importColumn, error) {
+	var fields []structField
+	if t != nil {
+		fields = structFields(t)
+	}
+
+	columns := make([]importColumn, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if len(fields) == 0 {
+			columns[i] = importColumn{name: name, kind: reflect.String}
+			continue
+		}
+		found := false
+		for _, f := range fields {
+			if strings.EqualFold(f.Name, name) {
+				fieldType := f.Type
+				for fieldType.Kind() == reflect.Ptr {
+					fieldType = fieldType.Elem()
+				}
+				columns[i] = importColumn{name: f.Name, kind: fieldType.Kind()}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("Unknown column: " + name)
+		}
+	}
+	return columns, nil
+}
+
+// importRow decodes, checks and stores a single row.
+func importRow(svc Service, columns []importColumn, record []string, createFunc func() Entity, pipeline *EntityPipeline, dryRun bool) ImportRowResult {
+	failed := func(err error) ImportRowResult {
+		details := &ErrorDetails{Message: err.Error()}
+		if detailed, ok := err.(DetailedError); ok {
+			details.Details = detailed.Details()
+		}
+		return ImportRowResult{Action: ImportFailed, Error: details}
+	}
+
+	fields := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		if i >= len(record) || record[i] == "" {
+			continue
+		}
+		value, err := importValue(record[i], column.kind)
+		if err != nil {
+			return failed(fmt.Errorf("Invalid value for %v: %v", column.name, err))
+		}
+		fields[column.name] = value
+	}
+	entity := createFunc()
+	data, _ := json.Marshal(fields)
+	if err := json.Unmarshal(data, entity); err != nil {
+		return failed(err)
+	}
+
+	id, hasID := fieldValue(fields, "id")
+	isNew := !hasID || svc.GetByID(fmt.Sprint(id)).State() != Ok
+	result := ImportRowResult{Action: ImportUpdated}
+	if isNew {
+		result.Action = ImportCreated
+	}
+	if hasID {
+		result.ID = id
+	}
+
+	if opResult := pipeline.Run(entity, isNew); opResult != nil {
+		return failed(opResult.Error())
+	}
+	if dryRun {
+		return result
+	}
+
+	var opResult OperationResult
+	if isNew {
+		opResult = svc.Add(entity)
+		if opResult.State() == Created && opResult.Value() != nil {
+			result.ID = opResult.Value()
+		}
+	} else {
+		opResult = svc.Update(fmt.Sprint(id), entity)
+	}
+	if !isSuccess(opResult) {
+		err := opResult.Error()
+		if err == nil {
+			err = errors.New(opResult.State().String())
+		}
+		return failed(err)
+	}
+	return result
+}
+
+// importValue converts a cell to a JSON value for a field of the given kind.
+func importValue(cell string, kind reflect.Kind) (interface{}, error) {
+	switch kind {
+	case reflect.String:
+		return cell, nil
+	case reflect.Bool:
+		return strconv.ParseBool(cell)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if _, err := strconv.ParseFloat(cell, 64); err != nil {
+			return nil, errors.New(cell + " is not a number")
+		}
+		return json.Number(cell), nil
+	}
+	// Structs, slices and the like are expected as JSON; anything else is taken as a string
+	var value interface{}
+	if err := json.Unmarshal([]byte(cell), &value); err == nil {
+		return value, nil
+	}
+	return cell, nil
+}
@@ -0,0 +1,68 @@
+package crud
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+)
+
+// CreateCrudHandlerImportCSV is used to import entities from a CSV body. Route it as e.g. POST /{resource}/_import. Every
+// row is passed through the pipeline of the Service (see EntityPipelineProvider), and added or updated depending on
+// whether an entity with its ID exists. The reply is an ImportReport; pass dryRun=true to check the rows without storing
+// them.
+var CreateCrudHandlerImportCSV = func(ctx servicefoundation.AppContext,
+	svc Service, resourceName string, recoverFunc RecoverFunc,
+	createFunc func() Entity) http.HandlerFunc {
+	return CreateCrudHandlerImportCSVWithPipeline(ctx, svc, resourceName, recoverFunc, createFunc, pipelineFor(svc))
+}
+
+// CreateCrudHandlerImportCSVWithPipeline is used to import entities from a CSV body, passing every row through the
+// given pipeline first
+var CreateCrudHandlerImportCSVWithPipeline = func(ctx servicefoundation.AppContext,
+	svc Service, resourceName string, recoverFunc RecoverFunc,
+	createFunc func() Entity, pipeline *EntityPipeline) http.HandlerFunc {
+	logger := ctx.Logger()
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer recoverFunc("crudHandler", ctx, w, r)
+
+		dryRun, err := ExtractDryRunFromURI(r)
+		if err != nil {
+			WriteOperationResult(w, r, ValidationFailedResult(err))
+			return
+		}
+
+		logger.Debug("CreateCrudHandlerImportCSV", fmt.Sprintf("Interpreted as import command. Resource: %v, dry run: %v", resourceName, dryRun))
+		var report *ImportReport
+		opResult := traceService(r, resourceName, OperationImport, func() OperationResult {
+			if report, err = ImportCSV(svc, r.Body, createFunc, pipeline, dryRun); err != nil {
+				return ValidationFailedResult(err)
+			}
+			return OkResult(report)
+		})
+		if report != nil {
+			logger.Debug("CreateCrudHandlerImportCSV", fmt.Sprintf("Imported %v rows: %v succeeded, %v failed", report.Total, report.Succeeded, report.Failed))
+		}
+		WriteOperationResult(w, r, opResult)
+	}
+}
+
+// ExtractDryRunFromURI is a helper function to parse the dryRun URI parameter. A parameter without a value means true.
+func ExtractDryRunFromURI(r *http.Request) (bool, error) {
+	for k, v := range r.URL.Query() {
+		if strings.ToLower(k) != "dryrun" {
+			continue
+		}
+		if v[0] == "" {
+			return true, nil
+		}
+		dryRun, err := strconv.ParseBool(v[0])
+		if err != nil {
+			return false, fmt.Errorf("Invalid value for dryRun: %v", v[0])
+		}
+		return dryRun, nil
+	}
+	return false, nil
+}
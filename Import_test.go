@@ -0,0 +1,110 @@
+package crud_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+const importCSV = `ID,name,status
+1,renamed,active
+,second,new
+,,new
+x,fourth,new
+`
+
+func TestImportCSV(t *testing.T) {
+	svc := newMemoryService()
+	svc.Add(&testEntity{Name: "first", Status: "new"})
+
+	report, err := crud.ImportCSV(svc, strings.NewReader(importCSV), newTestEntity, crud.DefaultEntityPipeline, false)
+
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.False(t, report.DryRun)
+	assert.Equal(t, 4, report.Total)
+	assert.Equal(t, 2, report.Succeeded)
+	assert.Equal(t, 2, report.Failed)
+
+	assert.Equal(t, 2, report.Rows[0].Line)
+	assert.Equal(t, crud.ImportUpdated, report.Rows[0].Action)
+	assert.Equal(t, crud.ImportCreated, report.Rows[1].Action)
+	assert.Equal(t, 2, report.Rows[1].ID)
+	assert.Equal(t, crud.ImportFailed, report.Rows[2].Action)
+	assert.Equal(t, "name is required", report.Rows[2].Error.Message)
+	assert.Equal(t, crud.ImportFailed, report.Rows[3].Action)
+	assert.Equal(t, 5, report.Rows[3].Line)
+
+	assert.Len(t, svc.entities, 2)
+	assert.Equal(t, "renamed", svc.entities["1"].Name)
+	assert.Equal(t, "active", svc.entities["1"].Status)
+	assert.Equal(t, "second", svc.entities["2"].Name)
+}
+
+func TestImportCSV_DryRun(t *testing.T) {
+	svc := newMemoryService()
+	svc.Add(&testEntity{Name: "first", Status: "new"})
+
+	report, err := crud.ImportCSV(svc, strings.NewReader(importCSV), newTestEntity, crud.DefaultEntityPipeline, true)
+
+	assert.Nil(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 2, report.Succeeded)
+	assert.Equal(t, crud.ImportUpdated, report.Rows[0].Action)
+	assert.Equal(t, crud.ImportCreated, report.Rows[1].Action)
+	assert.Len(t, svc.entities, 1)
+	assert.Equal(t, "first", svc.entities["1"].Name)
+}
+
+func TestImportCSV_InvalidHeader(t *testing.T) {
+	_, err := crud.ImportCSV(newMemoryService(), strings.NewReader("name,secret\na,b\n"), newTestEntity, crud.DefaultEntityPipeline, false)
+	assert.NotNil(t, err)
+
+	_, err = crud.ImportCSV(newMemoryService(), strings.NewReader(""), newTestEntity, crud.DefaultEntityPipeline, false)
+	assert.NotNil(t, err)
+}
+
+func TestImportCSV_TooManyRows(t *testing.T) {
+	defer func(max int) { crud.MaxImportRows = max }(crud.MaxImportRows)
+	crud.MaxImportRows = 3
+	svc := newMemoryService()
+
+	report, err := crud.ImportCSV(svc, strings.NewReader(importCSV), newTestEntity, crud.DefaultEntityPipeline, false)
+
+	assert.NotNil(t, err)
+	assert.Nil(t, report)
+	assert.Len(t, svc.entities, 0)
+}
+
+func TestRegistry_RouteImport(t *testing.T) {
+	registry := newTestRegistry()
+	router := mux.NewRouter()
+	registry.Route(router)
+
+	w := serve(router, "POST", "/api/things/_import?dryRun", "name\nfirst\n\n")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var report crud.ImportReport
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 1, report.Succeeded)
+
+	w = serve(router, "POST", "/api/things/_import?dryRun=maybe", "name\nfirst\n")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(router, "POST", "/api/things/_import", "unknown\nfirst\n")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Resources that don't expose create don't get the route
+	w = serve(router, "POST", "/api/lookups/_import", "name\nfirst\n")
+	assert.NotEqual(t, http.StatusOK, w.Code)
+
+	resource, _ := registry.Resource("things")
+	assert.Contains(t, resource.Capabilities("").Operations, crud.OperationImport)
+}
@@ -0,0 +1,98 @@
+package crud
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// PatchOperation is a single operation of a JSON Patch document, as described by RFC 6902.
+type PatchOperation struct {
+	// Op is the operation: "add", "remove" or "replace".
+	Op string `json:"op"`
+	// Path is the JSON Pointer (RFC 6901) to the value being changed.
+	Path string `json:"path"`
+	// Value is the new value. Omitted for "remove".
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON omits the value of "remove" operations, while keeping explicit nulls for the others.
+func (p PatchOperation) MarshalJSON() ([]byte, error) {
+	if p.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{p.Op, p.Path})
+	}
+	type plain PatchOperation
+	return json.Marshal(plain(p))
+}
+
+// DiffJSON compares the JSON representations of two values, and returns the JSON Patch that turns the first
+// into the second. Objects are compared field by field; arrays and scalars are replaced as a whole.
+func DiffJSON(from, to interface{}) ([]PatchOperation, error) {
+	fromDoc, err := toJSONDocument(from)
+	if err != nil {
+		return nil, err
+	}
+	toDoc, err := toJSONDocument(to)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := make([]PatchOperation, 0)
+	diffJSONValues("", fromDoc, toDoc, &patch)
+	return patch, nil
+}
+
+func toJSONDocument(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	err = json.Unmarshal(data, &doc)
+	return doc, err
+}
+
+func diffJSONValues(path string, from, to interface{}, patch *[]PatchOperation) {
+	fromObject, fromIsObject := from.(map[string]interface{})
+	toObject, toIsObject := to.(map[string]interface{})
+	if !fromIsObject || !toIsObject {
+		if !reflect.DeepEqual(from, to) {
+			*patch = append(*patch, PatchOperation{Op: "replace", Path: path, Value: to})
+		}
+		return
+	}
+
+	// Sorted keys keep the patch stable, which makes it comparable
+	keys := make([]string, 0, len(fromObject)+len(toObject))
+	for k := range fromObject {
+		keys = append(keys, k)
+	}
+	for k := range toObject {
+		if _, ok := fromObject[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		childPath := path + "/" + escapeJSONPointer(k)
+		fromValue, inFrom := fromObject[k]
+		toValue, inTo := toObject[k]
+		switch {
+		case !inTo:
+			*patch = append(*patch, PatchOperation{Op: "remove", Path: childPath})
+		case !inFrom:
+			*patch = append(*patch, PatchOperation{Op: "add", Path: childPath, Value: toValue})
+		default:
+			diffJSONValues(childPath, fromValue, toValue, patch)
+		}
+	}
+}
+
+func escapeJSONPointer(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
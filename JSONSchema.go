@@ -0,0 +1,155 @@
+package crud
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// structField is a field of a struct, as it appears in its JSON representation
+type structField struct {
+	// Name is the JSON name of the field.
+	Name string
+	// Type is the Go type of the field.
+	Type reflect.Type
+	// Index is the index sequence for reflect.Value.FieldByIndex.
+	Index []int
+	// OmitEmpty is true if the field is omitted from the JSON representation when empty.
+	OmitEmpty bool
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// entityType returns the struct type of the entities created by createFunc, dereferencing pointers.
+func entityType(createFunc func() Entity) reflect.Type {
+	if createFunc == nil {
+		return nil
+	}
+	entity := createFunc()
+	if entity == nil {
+		return nil
+	}
+	t := reflect.TypeOf(entity)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// structFields lists the fields of a struct type the way encoding/json sees them: exported fields only, named after
+// their json tag, with the fields of embedded structs promoted.
+func structFields(t reflect.Type) []structField {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := make([]structField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options := tag, ""
+		if comma := strings.Index(tag, ","); comma >= 0 {
+			name, options = tag[:comma], tag[comma+1:]
+		}
+
+		fieldType := f.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if f.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			for _, promoted := range structFields(fieldType) {
+				promoted.Index = append([]int{i}, promoted.Index...)
+				fields = append(fields, promoted)
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			// Unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, structField{
+			Name:      name,
+			Type:      f.Type,
+			Index:     []int{i},
+			OmitEmpty: strings.Contains(options, "omitempty"),
+		})
+	}
+	return fields
+}
+
+// EntitySchema describes the JSON representation of the entities created by createFunc, the way they're described in
+// the OpenAPI document.
+func EntitySchema(createFunc func() Entity) map[string]interface{} {
+	return jsonSchemaFor(entityType(createFunc))
+}
+
+// jsonSchemaFor describes the JSON representation of a Go type as an OpenAPI schema object.
+func jsonSchemaFor(t reflect.Type) map[string]interface{} {
+	return jsonSchemaForType(t, make(map[reflect.Type]bool))
+}
+
+func jsonSchemaForType(t reflect.Type, visiting map[reflect.Type]bool) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		// Custom representation; nothing sensible to say about it
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": jsonSchemaForType(t.Elem(), visiting)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchemaForType(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			// Recursive type; stop describing it
+			return map[string]interface{}{"type": "object"}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		properties := make(map[string]interface{})
+		for _, f := range structFields(t) {
+			properties[f.Name] = jsonSchemaForType(f.Type, visiting)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,34 @@
+package crud
+
+import (
+	"fmt"
+	"net/http"
+
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+	"github.com/gorilla/mux"
+)
+
+// CreateCrudHandlerMeta is used to describe the capabilities of the registered resources: supported operations, entity fields,
+// and the rules for list requests. Route it as e.g. /_meta for all resources, and /_meta/{resource} for a single one.
+var CreateCrudHandlerMeta = func(ctx servicefoundation.AppContext, registry *Registry, recoverFunc RecoverFunc) http.HandlerFunc {
+	logger := ctx.Logger()
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer recoverFunc("crudHandler", ctx, w, r)
+
+		vars := mux.Vars(r)
+		resourceVar, single := vars["resource"]
+
+		logger.Debug("CreateCrudHandlerMeta", fmt.Sprintf("Interpreted as Meta command. Resource: %v", resourceVar))
+		if !single {
+			WriteOperationResult(w, r, OkResult(registry.Capabilities()))
+			return
+		}
+
+		resource, ok := registry.Resource(resourceVar)
+		if !ok {
+			WriteOperationResult(w, r, NotFoundResult())
+			return
+		}
+		WriteOperationResult(w, r, OkResult(resource.Capabilities(registry.ResourcePath(resource))))
+	}
+}
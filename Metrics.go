@@ -0,0 +1,277 @@
+package crud
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the Prometheus metrics of CRUD operations and requests. It's a prometheus.Collector, so it can be
+// registered as a whole, e.g. prometheus.MustRegister(metrics).
+type Metrics struct {
+	// Operations counts the operations performed on Services, by resource, operation and State.
+	Operations *prometheus.CounterVec
+	// OperationDuration observes the duration of the operations performed on Services, by resource, operation and State.
+	OperationDuration *prometheus.HistogramVec
+	// PageSize observes the page size requested from GetAll, by resource.
+	PageSize *prometheus.HistogramVec
+	// ResultCount observes the number of entities returned by GetAll, by resource.
+	ResultCount *prometheus.HistogramVec
+	// Requests counts the HTTP requests handled, by resource, operation and status code.
+	Requests *prometheus.CounterVec
+	// RequestDuration observes the duration of the HTTP requests handled, by resource, operation and status code.
+	RequestDuration *prometheus.HistogramVec
+}
+
+// NewMetrics creates the metrics of CRUD operations and requests. The names of the metrics are prefixed with the
+// namespace, if any, e.g. bookings_crud_operations_total.
+func NewMetrics(namespace string) *Metrics {
+	sizeBuckets := []float64{1, 5, 10, 15, 25, 50, 100, 250, 500, 1000}
+	return &Metrics{
+		Operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "crud",
+			Name:      "operations_total",
+			Help:      "Number of operations performed on CRUD services.",
+		}, []string{"resource", "operation", "state"}),
+		OperationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "crud",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of operations performed on CRUD services.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"resource", "operation", "state"}),
+		PageSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "crud",
+			Name:      "page_size",
+			Help:      "Page size requested from GetAll.",
+			Buckets:   sizeBuckets,
+		}, []string{"resource"}),
+		ResultCount: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "crud",
+			Name:      "result_count",
+			Help:      "Number of entities returned by GetAll.",
+			Buckets:   append([]float64{0}, sizeBuckets...),
+		}, []string{"resource"}),
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "crud",
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests handled by CRUD handlers.",
+		}, []string{"resource", "operation", "code"}),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "crud",
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of HTTP requests handled by CRUD handlers.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"resource", "operation", "code"}),
+	}
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.Operations, m.OperationDuration, m.PageSize, m.ResultCount, m.Requests, m.RequestDuration}
+}
+
+// Describe sends the descriptions of all metrics
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect sends the values of all metrics
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+// observeOperation records an operation that took from start until now.
+func (m *Metrics) observeOperation(resourceName string, operation Operation, result OperationResult, start time.Time) {
+	state := Error.String()
+	if result != nil {
+		state = result.State().String()
+	}
+	m.Operations.WithLabelValues(resourceName, string(operation), state).Inc()
+	m.OperationDuration.WithLabelValues(resourceName, string(operation), state).Observe(time.Since(start).Seconds())
+}
+
+// MetricsService is a Service decorator that records the metrics of every operation. As a ServiceDecorator, it forwards
+// the optional interfaces of the decorated Service, recording aggregations and distinct values as well.
+type MetricsService struct {
+	Service
+
+	// Metrics receives the metrics.
+	Metrics *Metrics
+	// Resource is the name of the resource, used as the resource label.
+	Resource string
+}
+
+// NewMetricsService wraps the given Service with one that records the metrics of its operations for the resource.
+func NewMetricsService(svc Service, metrics *Metrics, resourceName string) *MetricsService {
+	return &MetricsService{Service: svc, Metrics: metrics, Resource: resourceName}
+}
+
+// EntityPipeline uses the pipeline of the decorated Service.
+func (m *MetricsService) EntityPipeline() *EntityPipeline {
+	return pipelineFor(m.Service)
+}
+
+// ResourcePolicy uses the policy of the decorated Service.
+func (m *MetricsService) ResourcePolicy() *ResourcePolicy {
+	return policyFor(m.Service)
+}
+
+// Decorated returns the decorated Service.
+func (m *MetricsService) Decorated() Service {
+	return m.Service
+}
+
+// GetAllAsOf gets the entities as they were at the given moment from the decorated Service, if it's an AsOfService.
+func (m *MetricsService) GetAllAsOf(request *DataSetRequest, asOf time.Time) OperationResult {
+	return getAllAsOf(m.Service, request, asOf)
+}
+
+// GetByIDAsOf gets the entity as it was at the given moment from the decorated Service, if it's an AsOfService.
+func (m *MetricsService) GetByIDAsOf(id EntityKey, asOf time.Time) OperationResult {
+	return getByIDAsOf(m.Service, id, asOf)
+}
+
+// Aggregate aggregates the entities using the decorated Service, if it's an Aggregator.
+func (m *MetricsService) Aggregate(request *AggregationRequest) OperationResult {
+	return m.observe(OperationAggregate, func() OperationResult {
+		return aggregate(m.Service, request)
+	})
+}
+
+// GetDistinctValues gets the distinct values of a column from the decorated Service, if it's a DistinctValuesService
+// or an Aggregator.
+func (m *MetricsService) GetDistinctValues(request *DistinctValuesRequest) OperationResult {
+	return m.observe(OperationDistinct, func() OperationResult {
+		return distinctValuesFor(m.Service, request)
+	})
+}
+
+// GetByIDs gets the entities with the given keys from the decorated Service, in a single call if it's a BatchGetter.
+func (m *MetricsService) GetByIDs(ids []EntityKey) OperationResult {
+	return getByIDs(m.Service, ids)
+}
+
+// GetAll gets the entities, recording the requested page size, if any, and the number of entities returned as well.
+func (m *MetricsService) GetAll(request *DataSetRequest) OperationResult {
+	if request != nil {
+		m.Metrics.PageSize.WithLabelValues(m.Resource).Observe(float64(request.PageSize))
+	}
+
+	start := time.Now()
+	result := m.Service.GetAll(request)
+	m.Metrics.observeOperation(m.Resource, OperationGetList, result, start)
+	if dataSet, ok := result.Value().(*DataSet); ok && dataSet != nil {
+		m.Metrics.ResultCount.WithLabelValues(m.Resource).Observe(float64(len(dataSet.Items)))
+	}
+	return result
+}
+
+// GetByID gets the entity
+func (m *MetricsService) GetByID(id EntityKey) OperationResult {
+	start := time.Now()
+	result := m.Service.GetByID(id)
+	m.Metrics.observeOperation(m.Resource, OperationGetByID, result, start)
+	return result
+}
+
+// Add adds the entity
+func (m *MetricsService) Add(entity Entity) OperationResult {
+	start := time.Now()
+	result := m.Service.Add(entity)
+	m.Metrics.observeOperation(m.Resource, OperationCreate, result, start)
+	return result
+}
+
+// Update updates the entity
+func (m *MetricsService) Update(id EntityKey, entity Entity) OperationResult {
+	start := time.Now()
+	result := m.Service.Update(id, entity)
+	m.Metrics.observeOperation(m.Resource, OperationUpdate, result, start)
+	return result
+}
+
+// Delete deletes the entity
+func (m *MetricsService) Delete(id EntityKey) OperationResult {
+	start := time.Now()
+	result := m.Service.Delete(id)
+	m.Metrics.observeOperation(m.Resource, OperationDelete, result, start)
+	return result
+}
+
+// observe performs an operation on the decorated Service, recording its metrics.
+func (m *MetricsService) observe(operation Operation, call func() OperationResult) OperationResult {
+	start := time.Now()
+	result := call()
+	m.Metrics.observeOperation(m.Resource, operation, result, start)
+	return result
+}
+
+// WithMetrics wraps a CRUD handler, recording the number and duration of the requests it handles by status code.
+func WithMetrics(metrics *Metrics, resourceName string, operation Operation, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		defer func() {
+			code := strconv.Itoa(recorder.statusCode)
+			metrics.Requests.WithLabelValues(resourceName, string(operation), code).Inc()
+			metrics.RequestDuration.WithLabelValues(resourceName, string(operation), code).Observe(time.Since(start).Seconds())
+		}()
+		handler(recorder, r)
+	}
+}
+
+// statusRecorder passes a response on, while keeping its status code.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+}
+
+func (sr *statusRecorder) WriteHeader(statusCode int) {
+	if !sr.wroteHeader {
+		sr.statusCode = statusCode
+		sr.wroteHeader = true
+	}
+	sr.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	sr.wroteHeader = true
+	return sr.ResponseWriter.Write(b)
+}
+
+// Flush flushes the response, if the wrapped ResponseWriter supports it, so that streaming handlers keep working.
+func (sr *statusRecorder) Flush() {
+	if flusher, ok := sr.ResponseWriter.(http.Flusher); ok {
+		sr.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the wrapped ResponseWriter, for http.ResponseController.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// CreateCrudHandlerMetrics is used to expose the metrics of a gatherer in Prometheus text format, e.g. a
+// prometheus.Registry the Metrics are registered on. Route it as e.g. /_metrics.
+var CreateCrudHandlerMetrics = func(ctx servicefoundation.AppContext, gatherer prometheus.Gatherer, recoverFunc RecoverFunc) http.HandlerFunc {
+	handler := promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer recoverFunc("crudHandler", ctx, w, r)
+		handler.ServeHTTP(w, r)
+	}
+}
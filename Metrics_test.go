@@ -0,0 +1,95 @@
+package crud_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(t *testing.T, metrics *crud.Metrics) string {
+	registry := prometheus.NewRegistry()
+	assert.Nil(t, registry.Register(metrics))
+
+	r, _ := http.NewRequest("GET", "/_metrics", nil)
+	w := httptest.NewRecorder()
+	crud.CreateCrudHandlerMetrics(newTestContext(), registry, crud.Recovery)(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
+
+func TestMetricsService(t *testing.T) {
+	metrics := crud.NewMetrics("test")
+	svc := crud.NewMetricsService(newMemoryService(), metrics, "things")
+
+	svc.Add(&testEntity{Name: "first"})
+	svc.Add(&testEntity{Name: "second"})
+	svc.GetByID(1)
+	svc.GetByID(42)
+	svc.GetAll(&crud.DataSetRequest{PageSize: 10, PageNumber: 1})
+
+	text := scrape(t, metrics)
+	assert.Contains(t, text, `test_crud_operations_total{operation="create",resource="things",state="Created"} 2`)
+	assert.Contains(t, text, `test_crud_operations_total{operation="getById",resource="things",state="Ok"} 1`)
+	assert.Contains(t, text, `test_crud_operations_total{operation="getById",resource="things",state="NotFound"} 1`)
+	assert.Contains(t, text, `test_crud_operation_duration_seconds_count{operation="getList",resource="things",state="Ok"} 1`)
+	assert.Contains(t, text, `test_crud_page_size_sum{resource="things"} 10`)
+	assert.Contains(t, text, `test_crud_result_count_sum{resource="things"} 2`)
+}
+
+func TestMetricsService_NilRequest(t *testing.T) {
+	metrics := crud.NewMetrics("test")
+	svc := crud.NewMetricsService(newMemoryService(), metrics, "things")
+
+	result := svc.GetAll(nil)
+
+	assert.Equal(t, crud.Ok, result.State())
+	assert.Contains(t, scrape(t, metrics), `test_crud_operations_total{operation="getList",resource="things",state="Ok"} 1`)
+}
+
+func TestRegistry_RouteWithMetrics(t *testing.T) {
+	metrics := crud.NewMetrics("")
+	registry := newTestRegistry()
+	registry.Metrics = metrics
+	router := mux.NewRouter()
+	registry.Route(router)
+
+	serve(router, "POST", "/api/things", `{"name": "first"}`)
+	serve(router, "GET", "/api/things/1", "")
+	serve(router, "GET", "/api/things/2", "")
+	serve(router, "POST", "/api/lookups", `{"name": "first"}`)
+
+	text := scrape(t, metrics)
+	assert.Contains(t, text, `crud_http_requests_total{code="201",operation="create",resource="things"} 1`)
+	assert.Contains(t, text, `crud_http_requests_total{code="200",operation="getById",resource="things"} 1`)
+	assert.Contains(t, text, `crud_http_requests_total{code="404",operation="getById",resource="things"} 1`)
+	assert.Contains(t, text, `crud_http_request_duration_seconds_count{code="201",operation="create",resource="things"} 1`)
+	assert.Contains(t, text, `crud_http_requests_total{code="405",operation="create",resource="lookups"} 1`)
+}
+
+func TestWithMetrics_Streaming(t *testing.T) {
+	bus := crud.NewChangeEventBus(10)
+	metrics := crud.NewMetrics("test")
+	handler := crud.CreateCrudHandlerChangeFeed(newTestContext(), bus, "things", noRecovery)
+	handler = crud.WithMetrics(metrics, "things", crud.OperationGetList, crud.WithTracing(&crud.Tracing{}, "things", crud.OperationGetList, handler))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	bus.Publish(crud.ChangeEvent{Type: crud.ChangeCreated, Resource: "things", Key: 1})
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "id: 1\n", line)
+}
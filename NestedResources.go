@@ -0,0 +1,362 @@
+package crud
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// maxResourceDepth limits how deeply resources can be nested, guarding against parents referring to each other.
+const maxResourceDepth = 8
+
+// ParentKeys holds the keys of the parents of a child resource, by the names of their path variables, e.g.
+// {"bookingId": "12"} for /bookings/12/passengers.
+type ParentKeys map[string]string
+
+// ParentScopedService implements a child resource. It's like Service, but every operation receives the keys of the
+// parents from the path as well. The registry checks that the parents exist before calling it.
+type ParentScopedService interface {
+	// GetAll is used to get a list of the entities under the parents. The keys of the parents are also injected into the
+	// filters of the request.
+	GetAll(parents ParentKeys, request *DataSetRequest) OperationResult
+	// GetByID returns the entity with the specified ID, under the parents.
+	GetByID(parents ParentKeys, id EntityKey) OperationResult
+	// Add will add the given entity under the parents.
+	Add(parents ParentKeys, entity Entity) OperationResult
+	// Update will update an existing entity under the parents.
+	Update(parents ParentKeys, id EntityKey, entity Entity) OperationResult
+	// Delete will delete the entity with the specified ID, under the parents.
+	Delete(parents ParentKeys, id EntityKey) OperationResult
+}
+
+// BindParents turns a ParentScopedService into a Service for the given parents. The EntityPipeline and ResourcePolicy
+// declared by the scoped service, if any, are declared by the returned Service as well.
+func BindParents(svc ParentScopedService, parents ParentKeys) Service {
+	return &parentBoundService{scoped: svc, parents: parents}
+}
+
+// parentBoundService is a ParentScopedService bound to a set of parents
+type parentBoundService struct {
+	scoped  ParentScopedService
+	parents ParentKeys
+}
+
+func (s *parentBoundService) GetAll(request *DataSetRequest) OperationResult {
+	if request != nil {
+		scoped := *request
+		scoped.Filters = make(map[string]string, len(request.Filters)+len(s.parents))
+		for k, v := range request.Filters {
+			scoped.Filters[k] = v
+		}
+		for k, v := range s.parents {
+			scoped.Filters[k] = v
+		}
+		request = &scoped
+	}
+	return s.scoped.GetAll(s.parents, request)
+}
+
+func (s *parentBoundService) GetByID(id EntityKey) OperationResult {
+	return s.scoped.GetByID(s.parents, id)
+}
+
+func (s *parentBoundService) Add(entity Entity) OperationResult {
+	return s.scoped.Add(s.parents, entity)
+}
+
+func (s *parentBoundService) Update(id EntityKey, entity Entity) OperationResult {
+	return s.scoped.Update(s.parents, id, entity)
+}
+
+func (s *parentBoundService) Delete(id EntityKey) OperationResult {
+	return s.scoped.Delete(s.parents, id)
+}
+
+// EntityPipeline returns the pipeline declared by the scoped service, if any
+func (s *parentBoundService) EntityPipeline() *EntityPipeline {
+	if provider, ok := s.scoped.(EntityPipelineProvider); ok {
+		return provider.EntityPipeline()
+	}
+	return DefaultEntityPipeline
+}
+
+// ResourcePolicy returns the policy declared by the scoped service, if any
+func (s *parentBoundService) ResourcePolicy() *ResourcePolicy {
+	if provider, ok := s.scoped.(ResourcePolicyProvider); ok {
+		return provider.ResourcePolicy()
+	}
+	return nil
+}
+
+// unscopedService adapts a regular Service to ParentScopedService. The entities are expected to hold the key of their
+// parent in the field named after the parent key, e.g. "bookingId": entities of other parents are reported as not
+// found, and new entities are given the key of their parent.
+type unscopedService struct {
+	Service
+	parentKey string
+}
+
+// GetAll gets the entities matching the filters, into which the parent keys are injected. Since a Service may match
+// filters loosely, entities of other parents are dropped before paging: the pages of the Service are read until the
+// requested page is complete, and the total number of records is only known once they're all read.
+func (s *unscopedService) GetAll(parents ParentKeys, request *DataSetRequest) OperationResult {
+	if request == nil {
+		result := s.Service.GetAll(request)
+		dataSet, ok := result.Value().(*DataSet)
+		if result.State() != Ok || !ok || dataSet == nil {
+			return result
+		}
+		scoped := *dataSet
+		scoped.Items = s.scope(parents, dataSet.Items)
+		if len(scoped.Items) != len(dataSet.Items) {
+			scoped.PagingInfo.DoesKnowTotalRecords = false
+			scoped.PagingInfo.TotalRecordsCount = 0
+		}
+		return OkResult(&scoped)
+	}
+
+	pageSize := request.PageSize
+	if pageSize < 1 {
+		pageSize = 1
+	}
+	pageNumber := request.PageNumber
+	if pageNumber < 1 {
+		pageNumber = 1
+	}
+	// Prevent overflowing the offset below; there can't be that many entities anyway
+	if maxInt := int(^uint(0) >> 1); pageNumber-1 > (maxInt-pageSize)/pageSize {
+		return OkResult(&DataSet{Items: make([]interface{}, 0), PagingInfo: PagingInfo{SupportsPaging: true, PageSize: pageSize, PageNumber: pageNumber}})
+	}
+	offset := (pageNumber - 1) * pageSize
+
+	items := make([]interface{}, 0)
+	read, complete := 0, false
+	for n := 1; len(items) < offset+pageSize; n++ {
+		page := *request
+		page.PageSize = pageSize
+		page.PageNumber = n
+		result := s.Service.GetAll(&page)
+		dataSet, ok := result.Value().(*DataSet)
+		if result.State() != Ok || !ok || dataSet == nil {
+			return result
+		}
+		read += len(dataSet.Items)
+		items = append(items, s.scope(parents, dataSet.Items)...)
+		// The Service may cap the page size, so the end is detected by the size of the page it reports
+		servedSize := dataSet.PagingInfo.PageSize
+		if servedSize <= 0 || servedSize > pageSize {
+			servedSize = pageSize
+		}
+		if !dataSet.PagingInfo.SupportsPaging || len(dataSet.Items) == 0 || len(dataSet.Items) < servedSize ||
+			(dataSet.PagingInfo.DoesKnowTotalRecords && read >= dataSet.PagingInfo.TotalRecordsCount) {
+			complete = true
+			break
+		}
+	}
+
+	scoped := &DataSet{
+		Items: make([]interface{}, 0),
+		PagingInfo: PagingInfo{
+			SupportsPaging:       true,
+			DoesKnowTotalRecords: complete,
+			PageSize:             pageSize,
+			PageNumber:           pageNumber,
+		},
+	}
+	if complete {
+		scoped.PagingInfo.TotalRecordsCount = len(items)
+	}
+	if offset < len(items) {
+		end := offset + pageSize
+		if end > len(items) {
+			end = len(items)
+		}
+		scoped.Items = items[offset:end]
+	}
+	return OkResult(scoped)
+}
+
+// scope returns the entities belonging to the parents.
+func (s *unscopedService) scope(parents ParentKeys, entities []interface{}) []interface{} {
+	result := make([]interface{}, 0, len(entities))
+	for _, entity := range entities {
+		if s.belongsTo(parents, entity) {
+			result = append(result, entity)
+		}
+	}
+	return result
+}
+
+func (s *unscopedService) GetByID(parents ParentKeys, id EntityKey) OperationResult {
+	result := s.Service.GetByID(id)
+	if result.State() == Ok && !s.belongsTo(parents, result.Value()) {
+		return NotFoundResult()
+	}
+	return result
+}
+
+func (s *unscopedService) Add(parents ParentKeys, entity Entity) OperationResult {
+	if err := s.assignTo(parents, entity); err != nil {
+		return ValidationFailedResult(err)
+	}
+	return s.Service.Add(entity)
+}
+
+func (s *unscopedService) Update(parents ParentKeys, id EntityKey, entity Entity) OperationResult {
+	if result := s.GetByID(parents, id); result.State() != Ok {
+		return result
+	}
+	if err := s.assignTo(parents, entity); err != nil {
+		return ValidationFailedResult(err)
+	}
+	return s.Service.Update(id, entity)
+}
+
+func (s *unscopedService) Delete(parents ParentKeys, id EntityKey) OperationResult {
+	if result := s.GetByID(parents, id); result.State() != Ok {
+		return result
+	}
+	return s.Service.Delete(id)
+}
+
+func (s *unscopedService) EntityPipeline() *EntityPipeline {
+	return pipelineFor(s.Service)
+}
+
+func (s *unscopedService) ResourcePolicy() *ResourcePolicy {
+	return policyFor(s.Service)
+}
+
+// belongsTo tells whether the parent key of the entity is exactly the key of its parent.
+func (s *unscopedService) belongsTo(parents ParentKeys, entity interface{}) bool {
+	value, ok := fieldValue(entityFields(entity), s.parentKey)
+	return ok && formatKey(value) == parents[s.parentKey]
+}
+
+// assignTo sets the parent key of the entity to the key of its parent, unless it's set already, in which case it has
+// to match.
+func (s *unscopedService) assignTo(parents ParentKeys, entity Entity) error {
+	fields := entityFields(entity)
+	name := s.parentKey
+	if _, ok := fields[name]; !ok {
+		for k := range fields {
+			if strings.EqualFold(k, name) {
+				name = k
+			}
+		}
+	}
+	value, ok := fields[name]
+	if !ok {
+		return fmt.Errorf("The entity has no %v field to hold the key of its parent", s.parentKey)
+	}
+	key := parents[s.parentKey]
+	current := formatKey(value)
+	if current == key {
+		return nil
+	}
+	if current != "" && current != "0" {
+		return fmt.Errorf("The %v of the entity doesn't match its parent: %v", s.parentKey, current)
+	}
+
+	// Set the field through the JSON representation of the entity, as a number if that's how it's represented
+	encoded, _ := json.Marshal(key)
+	if _, isNumber := value.(float64); isNumber {
+		if _, err := strconv.ParseFloat(key, 64); err != nil {
+			return fmt.Errorf("The key of the parent is not a number: %v", key)
+		}
+		encoded = []byte(key)
+	}
+	encodedName, _ := json.Marshal(name)
+	return json.Unmarshal([]byte(fmt.Sprintf("{%s: %s}", encodedName, encoded)), entity)
+}
+
+// formatKey formats a field value holding a key, formatting numbers without exponent or fraction.
+func formatKey(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// parentKey returns the name of the path variable holding the key of the parent.
+func (r *Resource) parentKey() string {
+	if r.ParentKey != "" {
+		return r.ParentKey
+	}
+	return strings.TrimSuffix(r.Parent, "s") + "Id"
+}
+
+// scopedService returns the implementation of the resource as a ParentScopedService.
+func (r *Resource) scopedService() ParentScopedService {
+	if r.ScopedService != nil {
+		return r.ScopedService
+	}
+	return &unscopedService{Service: r.Service, parentKey: r.parentKey()}
+}
+
+// service returns the implementation of the resource for the given parents.
+func (r *Resource) service(parents ParentKeys) Service {
+	if r.Parent == "" {
+		return r.Service
+	}
+	return BindParents(r.scopedService(), parents)
+}
+
+// ancestors returns the parents of a resource, outermost first.
+func (reg *Registry) ancestors(resource *Resource) ([]*Resource, error) {
+	result := make([]*Resource, 0)
+	for current := resource; current.Parent != ""; {
+		if len(result) == maxResourceDepth {
+			return nil, fmt.Errorf("Resource %v is nested too deeply", resource.Name)
+		}
+		parent, ok := reg.Resource(current.Parent)
+		if !ok {
+			return nil, fmt.Errorf("Parent %v of resource %v is not registered", current.Parent, current.Name)
+		}
+		result = append([]*Resource{parent}, result...)
+		current = parent
+	}
+	return result, nil
+}
+
+// scopedHandler serves a request on a child resource: it collects the keys of the parents from the path, checks that
+// the parents exist, and passes the request on to the handler for the Service of the resource bound to the parents.
+func (reg *Registry) scopedHandler(resource *Resource, ancestors []*Resource, handlerFor func(svc Service) http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer reg.recoverFunc("crudHandler", reg.ctx, w, r)
+
+		vars := mux.Vars(r)
+		parents := make(ParentKeys)
+		for i, ancestor := range ancestors {
+			ancestorParents := make(ParentKeys, len(parents))
+			for k, v := range parents {
+				ancestorParents[k] = v
+			}
+			key := ancestorKey(resource, ancestors, i)
+			if result := ancestor.service(ancestorParents).GetByID(vars[key]); result.State() != Ok {
+				reg.ctx.Logger().Debug("CrudHandlerParent", fmt.Sprintf("Parent %v %v is not available: %v", ancestor.Name, vars[key], result.State()))
+				WriteOperationResult(w, r, result)
+				return
+			}
+			parents[key] = vars[key]
+		}
+
+		handlerFor(resource.service(parents))(w, r)
+	}
+}
+
+// ancestorKey returns the name of the path variable holding the key of the i-th ancestor of a resource: the parent key
+// declared by the resource nested directly under it.
+func ancestorKey(resource *Resource, ancestors []*Resource, i int) string {
+	if i == len(ancestors)-1 {
+		return resource.parentKey()
+	}
+	return ancestors[i+1].parentKey()
+}
@@ -0,0 +1,290 @@
+package crud_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// passengerService keeps a separate set of passengers per booking
+type passengerService struct {
+	mutex    sync.Mutex
+	bookings map[string]*memoryService
+	lastGet  *crud.DataSetRequest
+}
+
+func (s *passengerService) of(parents crud.ParentKeys) *memoryService {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	svc, ok := s.bookings[parents["bookingId"]]
+	if !ok {
+		svc = newMemoryService()
+		s.bookings[parents["bookingId"]] = svc
+	}
+	return svc
+}
+
+func (s *passengerService) GetAll(parents crud.ParentKeys, request *crud.DataSetRequest) crud.OperationResult {
+	s.lastGet = request
+	return s.of(parents).GetAll(&crud.DataSetRequest{PageSize: request.PageSize, PageNumber: request.PageNumber})
+}
+
+func (s *passengerService) GetByID(parents crud.ParentKeys, id crud.EntityKey) crud.OperationResult {
+	return s.of(parents).GetByID(id)
+}
+
+func (s *passengerService) Add(parents crud.ParentKeys, entity crud.Entity) crud.OperationResult {
+	return s.of(parents).Add(entity)
+}
+
+func (s *passengerService) Update(parents crud.ParentKeys, id crud.EntityKey, entity crud.Entity) crud.OperationResult {
+	return s.of(parents).Update(id, entity)
+}
+
+func (s *passengerService) Delete(parents crud.ParentKeys, id crud.EntityKey) crud.OperationResult {
+	return s.of(parents).Delete(id)
+}
+
+func newNestedRegistry() (*crud.Registry, *memoryService, *passengerService) {
+	bookings := newMemoryService()
+	passengers := &passengerService{bookings: make(map[string]*memoryService)}
+
+	registry := crud.NewRegistry(newTestContext(), crud.Recovery)
+	registry.BasePath = "/api"
+	registry.Register(&crud.Resource{Name: "bookings", Service: bookings, CreateFunc: newTestEntity})
+	registry.Register(&crud.Resource{Name: "passengers", Parent: "bookings", ScopedService: passengers, CreateFunc: newTestEntity})
+	return registry, bookings, passengers
+}
+
+func serve(router *mux.Router, method, path, body string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestRegistry_ResourcePathOfChild(t *testing.T) {
+	registry, _, _ := newNestedRegistry()
+	resource, _ := registry.Resource("passengers")
+
+	assert.Equal(t, "/api/bookings/{bookingId}/passengers", registry.ResourcePath(resource))
+
+	registry.Register(&crud.Resource{Name: "bags", Parent: "passengers", ParentKey: "passenger", Service: newMemoryService()})
+	resource, _ = registry.Resource("bags")
+	assert.Equal(t, "/api/bookings/{bookingId}/passengers/{passenger}/bags", registry.ResourcePath(resource))
+}
+
+func TestRegistry_RouteChildResource(t *testing.T) {
+	registry, bookings, passengers := newNestedRegistry()
+	router := mux.NewRouter()
+	registry.Route(router)
+	bookings.Add(&testEntity{Name: "first booking"})
+	bookings.Add(&testEntity{Name: "second booking"})
+
+	w := serve(router, "POST", "/api/bookings/1/passengers", `{"name":"john"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = serve(router, "GET", "/api/bookings/1/passengers/1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"john"`)
+
+	w = serve(router, "GET", "/api/bookings/2/passengers/1", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(router, "GET", `/api/bookings/1/passengers?filters={"name":"jo"}`, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[string]string{"name": "jo", "bookingId": "1"}, passengers.lastGet.Filters)
+}
+
+func TestRegistry_RouteChildResourceOfMissingParent(t *testing.T) {
+	registry, _, passengers := newNestedRegistry()
+	router := mux.NewRouter()
+	registry.Route(router)
+
+	w := serve(router, "POST", "/api/bookings/1/passengers", `{"name":"john"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(router, "GET", "/api/bookings/1/passengers", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Nil(t, passengers.lastGet)
+}
+
+func TestRegistry_RouteChildResourceWithRegularService(t *testing.T) {
+	registry, bookings, _ := newNestedRegistry()
+	svc := &capturingService{}
+	registry.Register(&crud.Resource{Name: "notes", Parent: "bookings", Service: svc, CreateFunc: newTestEntity})
+	router := mux.NewRouter()
+	registry.Route(router)
+	bookings.Add(&testEntity{Name: "first booking"})
+
+	w := serve(router, "GET", "/api/bookings/1/notes", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[string]string{"bookingId": "1"}, svc.request.Filters)
+}
+
+func TestRegistry_RouteSkipsChildOfUnknownParent(t *testing.T) {
+	registry, _, _ := newNestedRegistry()
+	registry.Register(&crud.Resource{Name: "orphans", Parent: "unknown", Service: newMemoryService(), CreateFunc: newTestEntity})
+	router := mux.NewRouter()
+	registry.Route(router)
+
+	w := serve(router, "GET", "/api/unknown/1/orphans", "")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGenerateOpenAPI_ChildResource(t *testing.T) {
+	registry, _, _ := newNestedRegistry()
+
+	doc := crud.GenerateOpenAPI(registry, crud.OpenAPIInfo{Title: "Test", Version: "1"})
+
+	parameters := lookup(doc, "paths", "/api/bookings/{bookingId}/passengers/{id}", "get", "parameters").([]interface{})
+	assert.Equal(t, 2, len(parameters))
+	assert.Equal(t, "bookingId", parameters[0].(map[string]interface{})["name"])
+	assert.Equal(t, "id", parameters[1].(map[string]interface{})["name"])
+}
+
+// noteEntity is the entity of a child resource implemented by a regular Service
+type noteEntity struct {
+	ID        int    `json:"id"`
+	BookingID int    `json:"bookingId"`
+	Text      string `json:"text"`
+}
+
+func (e *noteEntity) Validate() error { return nil }
+
+func (e *noteEntity) Format(isNewEntity bool) {}
+
+// noteService stores notes, ignoring the filters of GetAll
+type noteService struct {
+	lastID int
+	notes  map[string]*noteEntity
+}
+
+func (s *noteService) GetAll(request *crud.DataSetRequest) crud.OperationResult {
+	items := make([]interface{}, 0, len(s.notes))
+	for i := 1; i <= s.lastID; i++ {
+		if note, ok := s.notes[strconv.Itoa(i)]; ok {
+			items = append(items, note)
+		}
+	}
+	return crud.OkResult(&crud.DataSet{Items: items, PagingInfo: crud.PagingInfo{PageSize: 15, PageNumber: 1, TotalRecordsCount: len(items), DoesKnowTotalRecords: true}})
+}
+
+func (s *noteService) GetByID(id crud.EntityKey) crud.OperationResult {
+	if note, ok := s.notes[fmt.Sprint(id)]; ok {
+		return crud.OkResult(note)
+	}
+	return crud.NotFoundResult()
+}
+
+func (s *noteService) Add(entity crud.Entity) crud.OperationResult {
+	s.lastID++
+	note := *entity.(*noteEntity)
+	note.ID = s.lastID
+	s.notes[strconv.Itoa(note.ID)] = &note
+	return crud.CreatedWithIDResult(note.ID)
+}
+
+func (s *noteService) Update(id crud.EntityKey, entity crud.Entity) crud.OperationResult {
+	note := *entity.(*noteEntity)
+	note.ID, _ = strconv.Atoi(fmt.Sprint(id))
+	s.notes[fmt.Sprint(id)] = &note
+	return crud.OkResult(&note)
+}
+
+func (s *noteService) Delete(id crud.EntityKey) crud.OperationResult {
+	delete(s.notes, fmt.Sprint(id))
+	return crud.OkResult(nil)
+}
+
+func TestRegistry_RouteChildResourceScopesRegularService(t *testing.T) {
+	registry, bookings, _ := newNestedRegistry()
+	notes := &noteService{notes: make(map[string]*noteEntity)}
+	registry.Register(&crud.Resource{Name: "notes", Parent: "bookings", Service: notes, CreateFunc: func() crud.Entity { return &noteEntity{} }})
+	router := mux.NewRouter()
+	registry.Route(router)
+	for i := 1; i <= 10; i++ {
+		bookings.Add(&testEntity{Name: "booking"})
+	}
+
+	// New entities get the key of their parent, which can't be changed
+	assert.Equal(t, http.StatusCreated, serve(router, "POST", "/api/bookings/1/notes", `{"text": "first"}`).Code)
+	assert.Equal(t, 1, notes.notes["1"].BookingID)
+	assert.Equal(t, http.StatusCreated, serve(router, "POST", "/api/bookings/10/notes", `{"text": "tenth", "bookingId": 10}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(router, "POST", "/api/bookings/1/notes", `{"text": "other", "bookingId": 2}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(router, "PUT", "/api/bookings/1/notes/1", `{"text": "moved", "bookingId": 2}`).Code)
+
+	// Entities of other parents aren't found, even when their key contains the key of the parent
+	w := serve(router, "GET", "/api/bookings/1/notes", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"text":"first"`)
+	assert.NotContains(t, w.Body.String(), `"text":"tenth"`)
+	assert.Equal(t, http.StatusOK, serve(router, "GET", "/api/bookings/1/notes/1", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/api/bookings/2/notes/1", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "PUT", "/api/bookings/2/notes/1", `{"text": "changed"}`).Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "DELETE", "/api/bookings/2/notes/1", "").Code)
+	assert.Equal(t, "first", notes.notes["1"].Text)
+
+	assert.Equal(t, http.StatusOK, serve(router, "PUT", "/api/bookings/1/notes/1", `{"text": "changed"}`).Code)
+	assert.Equal(t, 1, notes.notes["1"].BookingID)
+	assert.Equal(t, http.StatusOK, serve(router, "DELETE", "/api/bookings/1/notes/1", "").Code)
+	assert.Len(t, notes.notes, 1)
+}
+
+// looseNoteService pages the notes, matching filters on their booking by substring
+type looseNoteService struct {
+	*noteService
+	gets int
+}
+
+func (s *looseNoteService) GetAll(request *crud.DataSetRequest) crud.OperationResult {
+	s.gets++
+	matching := make([]interface{}, 0)
+	for i := 1; i <= s.lastID; i++ {
+		if note, ok := s.notes[strconv.Itoa(i)]; ok && strings.Contains(strconv.Itoa(note.BookingID), request.Filters["bookingId"]) {
+			matching = append(matching, note)
+		}
+	}
+	items := make([]interface{}, 0)
+	for i := (request.PageNumber - 1) * request.PageSize; i >= 0 && i < len(matching) && len(items) < request.PageSize; i++ {
+		items = append(items, matching[i])
+	}
+	return crud.OkResult(&crud.DataSet{Items: items, PagingInfo: crud.PagingInfo{SupportsPaging: true, PageSize: request.PageSize, PageNumber: request.PageNumber,
+		TotalRecordsCount: len(matching), DoesKnowTotalRecords: true}})
+}
+
+func TestRegistry_RouteChildResourcePagesScopedEntities(t *testing.T) {
+	registry, bookings, _ := newNestedRegistry()
+	notes := &looseNoteService{noteService: &noteService{notes: make(map[string]*noteEntity)}}
+	registry.Register(&crud.Resource{Name: "notes", Parent: "bookings", Service: notes, CreateFunc: func() crud.Entity { return &noteEntity{} }})
+	router := mux.NewRouter()
+	registry.Route(router)
+	for i := 1; i <= 11; i++ {
+		bookings.Add(&testEntity{Name: "booking"})
+	}
+	for _, bookingID := range []int{1, 10, 1, 11, 1} {
+		notes.Add(&noteEntity{BookingID: bookingID, Text: fmt.Sprint("note of ", bookingID)})
+	}
+
+	w := serve(router, "GET", "/api/bookings/1/notes?pageSize=2&pageNumber=1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":1,`)
+	assert.Contains(t, w.Body.String(), `"id":3,`)
+	assert.Contains(t, w.Body.String(), `"doesKnowTotalRecords":false`)
+
+	w = serve(router, "GET", "/api/bookings/1/notes?pageSize=2&pageNumber=2", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":5,`)
+	assert.NotContains(t, w.Body.String(), `"text":"note of 10"`)
+	assert.Contains(t, w.Body.String(), `"doesKnowTotalRecords":true`)
+	assert.Contains(t, w.Body.String(), `"totalRecordCount":3`)
+}
@@ -0,0 +1,364 @@
+package crud
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+)
+
+// OpenAPIInfo is the info object of a generated OpenAPI document
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// operationStates lists the states each operation can result in, which determine the documented responses. Services
+// can complete Add, Update and Delete asynchronously, resulting in Accepted (see AsyncOperationTracker).
+var operationStates = map[Operation][]State{
+	OperationGetList: {Ok, ValidationFailed, Error},
+	OperationGetByID: {Ok, NotFound, Error},
+	OperationCreate:  {Created, Accepted, ValidationFailed, Conflict, Error},
+	OperationUpdate:  {Ok, Accepted, ValidationFailed, NotFound, Conflict, Error},
+	OperationDelete:  {Ok, Accepted, NotFound, Conflict, Error},
+}
+
+// GenerateOpenAPI describes the resources of the registry as an OpenAPI 3 document. Entity schemas are derived from the
+// entities created by the CreateFunc of each resource. The status of asynchronous operations is described as well, if
+// the registry tracks them.
+func GenerateOpenAPI(registry *Registry, info OpenAPIInfo) map[string]interface{} {
+	schemas := map[string]interface{}{
+		"PagingInfo":     jsonSchemaFor(reflect.TypeOf(PagingInfo{})),
+		"ErrorDetails":   jsonSchemaFor(reflect.TypeOf(ErrorDetails{})),
+		"AsyncOperation": jsonSchemaFor(reflect.TypeOf(AsyncOperation{})),
+	}
+	paths := make(map[string]interface{})
+	if registry.AsyncOperations != nil {
+		operationPath := registry.AsyncOperations.BasePath + "/{id}"
+		paths[operationPath] = map[string]interface{}{
+			"get": openAPIAsyncOperation(pathParameters(operationPath)),
+		}
+	}
+
+	for _, resource := range registry.Resources() {
+		entitySchema := schemaName(resource.Name)
+		schemas[entitySchema] = jsonSchemaFor(entityType(resource.CreateFunc))
+		schemas[entitySchema+"DataSet"] = map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"items":      map[string]interface{}{"type": "array", "items": schemaRef(entitySchema)},
+				"pagingInfo": schemaRef("PagingInfo"),
+			},
+		}
+
+		path := registry.ResourcePath(resource)
+		parentParameters := pathParameters(path)
+		listParameters := append(parentParameters, dataSetRequestParameters(resource.ResourcePolicy())...)
+		paths[path] = map[string]interface{}{
+			"get":  openAPIOperation(resource, OperationGetList, entitySchema, append(listParameters, expandParameters(resource)...)),
+			"post": openAPIOperation(resource, OperationCreate, entitySchema, parentParameters),
+		}
+		idParameters := pathParameters(path + "/{id}")
+		paths[path+"/{id}"] = map[string]interface{}{
+			"get":    openAPIOperation(resource, OperationGetByID, entitySchema, append(idParameters, expandParameters(resource)...)),
+			"put":    openAPIOperation(resource, OperationUpdate, entitySchema, idParameters),
+			"delete": openAPIOperation(resource, OperationDelete, entitySchema, idParameters),
+		}
+		if resource.aggregates() {
+			schemas["AggregationResult"] = jsonSchemaFor(reflect.TypeOf(AggregationResult{}))
+			paths[path+"/_aggregate"] = map[string]interface{}{
+				"get": openAPIAggregateOperation(resource, entitySchema, parentParameters),
+			}
+		}
+		if resource.distinctValues() {
+			schemas["DistinctValue"] = jsonSchemaFor(reflect.TypeOf(DistinctValue{}))
+			paths[path+"/_distinct/{column}"] = map[string]interface{}{
+				"get": openAPIDistinctOperation(resource, entitySchema, pathParameters(path+"/_distinct/{column}")),
+			}
+		}
+		if resource.imports() {
+			schemas["ImportReport"] = jsonSchemaFor(reflect.TypeOf(ImportReport{}))
+			paths[path+"/_import"] = map[string]interface{}{
+				"post": openAPIImportOperation(resource, entitySchema, parentParameters),
+			}
+		}
+		for _, action := range resource.Actions {
+			if action.Validate() != nil {
+				continue
+			}
+			actionPath := path + "/{id}/actions/" + action.Name
+			requestSchema := ""
+			if action.CreateRequest != nil {
+				requestSchema = entitySchema + schemaName(action.Name) + "Request"
+				schemas[requestSchema] = jsonSchemaFor(reflect.TypeOf(action.CreateRequest()))
+			}
+			paths[actionPath] = map[string]interface{}{
+				"post": openAPIActionOperation(resource, action, entitySchema, requestSchema, pathParameters(actionPath)),
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi":    "3.0.0",
+		"info":       info,
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+// CreateCrudHandlerOpenAPI is used to serve the OpenAPI document describing the resources of a registry. The document is
+// generated on every request, so it reflects resources registered later on.
+var CreateCrudHandlerOpenAPI = func(ctx servicefoundation.AppContext, registry *Registry, info OpenAPIInfo, recoverFunc RecoverFunc) http.HandlerFunc {
+	logger := ctx.Logger()
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer recoverFunc("crudHandler", ctx, w, r)
+
+		logger.Debug("CreateCrudHandlerOpenAPI", "Interpreted as OpenAPI command")
+		WriteOperationResult(w, r, OkResult(GenerateOpenAPI(registry, info)))
+	}
+}
+
+func openAPIOperation(resource *Resource, operation Operation, entitySchema string, parameters []interface{}) map[string]interface{} {
+	result := map[string]interface{}{
+		"operationId": string(operation) + entitySchema,
+		"tags":        []string{resource.Name},
+	}
+
+	responses := make(map[string]interface{})
+	if !resource.Supports(operation) {
+		responses[statusKey(NotSupportedByResource)] = openAPIResponse(NotSupportedByResource, nil)
+		result["responses"] = responses
+		return result
+	}
+
+	if len(parameters) > 0 {
+		result["parameters"] = parameters
+	}
+	if operation == OperationCreate || operation == OperationUpdate {
+		result["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": schemaRef(entitySchema)}},
+		}
+	}
+
+	for _, state := range operationStates[operation] {
+		var schema interface{}
+		switch state {
+		case Ok:
+			switch operation {
+			case OperationGetList:
+				schema = schemaRef(entitySchema + "DataSet")
+			case OperationGetByID, OperationUpdate:
+				schema = schemaRef(entitySchema)
+			}
+		case Created:
+			// The ID of the new entity, if the Service returns it
+			schema = map[string]interface{}{}
+		case Accepted:
+			schema = schemaRef("AsyncOperation")
+		case ValidationFailed, Conflict, Error:
+			schema = schemaRef("ErrorDetails")
+		}
+		response := openAPIResponse(state, schema)
+		if state == Accepted {
+			response["headers"] = map[string]interface{}{
+				"Location": map[string]interface{}{
+					"description": "Path where the status of the operation can be requested.",
+					"schema":      map[string]interface{}{"type": "string"},
+				},
+			}
+		}
+		responses[statusKey(state)] = response
+	}
+	result["responses"] = responses
+	return result
+}
+
+func openAPIAsyncOperation(parameters []interface{}) map[string]interface{} {
+	parameters = append(parameters,
+		queryParameter("wait", "Number of seconds to wait for the operation to complete.", map[string]interface{}{"type": "integer", "minimum": 0}),
+	)
+	return map[string]interface{}{
+		"operationId": "getAsyncOperation",
+		"tags":        []string{"operations"},
+		"parameters":  parameters,
+		"responses": map[string]interface{}{
+			statusKey(Ok):               openAPIResponse(Ok, schemaRef("AsyncOperation")),
+			statusKey(ValidationFailed): openAPIResponse(ValidationFailed, schemaRef("ErrorDetails")),
+			statusKey(NotFound):         openAPIResponse(NotFound, nil),
+		},
+	}
+}
+
+func openAPIAggregateOperation(resource *Resource, entitySchema string, parameters []interface{}) map[string]interface{} {
+	parameters = append(parameters,
+		queryParameter("groupBy", "Comma-separated columns to group by. Timestamps can be grouped by year(column), month(column), day(column) or hour(column).", map[string]interface{}{"type": "string"}),
+		queryParameter("measures", "Comma-separated measures to calculate, e.g. count,sum(amount). Supports count, sum, min, max and avg.", map[string]interface{}{"type": "string"}),
+		queryParameter("filters", "JSON object of column names and the values to search for in those columns.", map[string]interface{}{"type": "string"}),
+	)
+	return map[string]interface{}{
+		"operationId": string(OperationAggregate) + entitySchema,
+		"tags":        []string{resource.Name},
+		"parameters":  parameters,
+		"responses": map[string]interface{}{
+			statusKey(Ok):               openAPIResponse(Ok, schemaRef("AggregationResult")),
+			statusKey(ValidationFailed): openAPIResponse(ValidationFailed, schemaRef("ErrorDetails")),
+			statusKey(Error):            openAPIResponse(Error, schemaRef("ErrorDetails")),
+		},
+	}
+}
+
+func openAPIDistinctOperation(resource *Resource, entitySchema string, parameters []interface{}) map[string]interface{} {
+	parameters = append(parameters,
+		queryParameter("filters", "JSON object of column names and the values to search for in those columns.", map[string]interface{}{"type": "string"}),
+	)
+	return map[string]interface{}{
+		"operationId": string(OperationDistinct) + entitySchema,
+		"tags":        []string{resource.Name},
+		"parameters":  parameters,
+		"responses": map[string]interface{}{
+			statusKey(Ok):               openAPIResponse(Ok, map[string]interface{}{"type": "array", "items": schemaRef("DistinctValue")}),
+			statusKey(ValidationFailed): openAPIResponse(ValidationFailed, schemaRef("ErrorDetails")),
+			statusKey(Error):            openAPIResponse(Error, schemaRef("ErrorDetails")),
+		},
+	}
+}
+
+func openAPIImportOperation(resource *Resource, entitySchema string, parameters []interface{}) map[string]interface{} {
+	parameters = append(parameters,
+		queryParameter("dryRun", "Check the rows without adding or updating any entity.", map[string]interface{}{"type": "boolean"}),
+	)
+	return map[string]interface{}{
+		"operationId": string(OperationImport) + entitySchema,
+		"tags":        []string{resource.Name},
+		"parameters":  parameters,
+		"requestBody": map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{"text/csv": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}},
+		},
+		"responses": map[string]interface{}{
+			statusKey(Ok):               openAPIResponse(Ok, schemaRef("ImportReport")),
+			statusKey(ValidationFailed): openAPIResponse(ValidationFailed, schemaRef("ErrorDetails")),
+			statusKey(Error):            openAPIResponse(Error, schemaRef("ErrorDetails")),
+		},
+	}
+}
+
+func openAPIActionOperation(resource *Resource, action *Action, entitySchema, requestSchema string, parameters []interface{}) map[string]interface{} {
+	result := map[string]interface{}{
+		"operationId": action.Name + entitySchema,
+		"tags":        []string{resource.Name},
+		"parameters":  parameters,
+		"responses": map[string]interface{}{
+			// The result of an action is up to the action
+			statusKey(Ok):               openAPIResponse(Ok, map[string]interface{}{}),
+			statusKey(ValidationFailed): openAPIResponse(ValidationFailed, schemaRef("ErrorDetails")),
+			statusKey(NotFound):         openAPIResponse(NotFound, nil),
+			statusKey(Conflict):         openAPIResponse(Conflict, schemaRef("ErrorDetails")),
+			statusKey(Error):            openAPIResponse(Error, schemaRef("ErrorDetails")),
+		},
+	}
+	if requestSchema != "" {
+		result["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": schemaRef(requestSchema)}},
+		}
+	}
+	return result
+}
+
+func openAPIResponse(state State, schema interface{}) map[string]interface{} {
+	response := map[string]interface{}{
+		"description": state.String(),
+	}
+	if schema != nil {
+		response["content"] = map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+	}
+	return response
+}
+
+// dataSetRequestParameters describes the URI parameters of list requests, narrowed down by the policy if there is one.
+func dataSetRequestParameters(policy *ResourcePolicy) []interface{} {
+	pageSize := map[string]interface{}{"type": "integer", "minimum": 1}
+	sortColumn := map[string]interface{}{"type": "string"}
+	if policy != nil {
+		if policy.MinPageSize > 1 {
+			pageSize["minimum"] = policy.MinPageSize
+		}
+		if policy.MaxPageSize > 0 {
+			pageSize["maximum"] = policy.MaxPageSize
+		}
+		if len(policy.SortColumns) > 0 {
+			sortColumn["enum"] = policy.SortColumns
+		}
+	}
+
+	return []interface{}{
+		queryParameter("pageSize", "Number of items per page.", pageSize),
+		queryParameter("pageNumber", "One-based number of the page.", map[string]interface{}{"type": "integer", "minimum": 1}),
+		queryParameter("sortColumn", "Column to sort the results by.", sortColumn),
+		queryParameter("sortDirection", "Sort direction.", map[string]interface{}{"type": "string", "enum": []string{string(Asc), string(Desc)}}),
+		queryParameter("filters", "JSON object of column names and the values to search for in those columns.", map[string]interface{}{"type": "string"}),
+	}
+}
+
+// expandParameters describes the expand URI parameter, if the resource has relations.
+func expandParameters(resource *Resource) []interface{} {
+	if len(resource.Relations) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(resource.Relations))
+	for _, relation := range resource.Relations {
+		names = append(names, relation.Name)
+	}
+	description := "Comma-separated relations to embed in the response: " + strings.Join(names, ", ") + "."
+	return []interface{}{queryParameter("expand", description, map[string]interface{}{"type": "string"})}
+}
+
+// pathParameters describes the variables in a path, such as the keys of parent entities and the ID of the entity.
+func pathParameters(path string) []interface{} {
+	parameters := make([]interface{}, 0)
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     segment[1 : len(segment)-1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+	}
+	return parameters
+}
+
+func queryParameter(name, description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "query",
+		"required":    false,
+		"description": description,
+		"schema":      schema,
+	}
+}
+
+func statusKey(state State) string {
+	return fmt.Sprint(StatusCodeForState(state))
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// schemaName turns a resource name like "booking-passengers" into a schema name like "BookingPassengers".
+func schemaName(resourceName string) string {
+	parts := strings.FieldsFunc(resourceName, func(r rune) bool {
+		return r == '-' || r == '_' || r == '/' || r == ' ' || r == '.'
+	})
+	for i, part := range parts {
+		parts[i] = strings.ToUpper(part[:1]) + part[1:]
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,93 @@
+package crud_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// openAPIDocument round-trips the document through JSON, so it can be inspected generically
+func openAPIDocument(t *testing.T, registry *crud.Registry) map[string]interface{} {
+	data, err := json.Marshal(crud.GenerateOpenAPI(registry, crud.OpenAPIInfo{Title: "Test", Version: "1.0"}))
+	assert.Nil(t, err)
+	var doc map[string]interface{}
+	assert.Nil(t, json.Unmarshal(data, &doc))
+	return doc
+}
+
+func lookup(doc interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		m, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		doc = m[key]
+	}
+	return doc
+}
+
+func TestGenerateOpenAPI_Schemas(t *testing.T) {
+	doc := openAPIDocument(t, newTestRegistry())
+
+	assert.Equal(t, "3.0.0", doc["openapi"])
+	assert.Equal(t, "Test", lookup(doc, "info", "title"))
+	assert.Equal(t, "integer", lookup(doc, "components", "schemas", "Things", "properties", "id", "type"))
+	assert.Equal(t, "string", lookup(doc, "components", "schemas", "Things", "properties", "name", "type"))
+	assert.Equal(t, "#/components/schemas/Things", lookup(doc, "components", "schemas", "ThingsDataSet", "properties", "items", "items", "$ref"))
+	assert.Equal(t, "boolean", lookup(doc, "components", "schemas", "PagingInfo", "properties", "supportsPaging", "type"))
+}
+
+func TestGenerateOpenAPI_Paths(t *testing.T) {
+	doc := openAPIDocument(t, newTestRegistry())
+
+	list := lookup(doc, "paths", "/api/things", "get")
+	assert.Equal(t, "#/components/schemas/ThingsDataSet", lookup(list, "responses", "200", "content", "application/json", "schema", "$ref"))
+	assert.Equal(t, 5, len(lookup(list, "parameters").([]interface{})))
+
+	create := lookup(doc, "paths", "/api/things", "post")
+	assert.NotNil(t, lookup(create, "responses", "201"))
+	assert.Equal(t, "#/components/schemas/ErrorDetails", lookup(create, "responses", "400", "content", "application/json", "schema", "$ref"))
+
+	assert.Equal(t, "NotFound", lookup(doc, "paths", "/api/things/{id}", "delete", "responses", "404", "description"))
+
+	// Unsupported operations are documented as such
+	responses := lookup(doc, "paths", "/api/lookups/{id}", "put", "responses").(map[string]interface{})
+	assert.Equal(t, 1, len(responses))
+	assert.NotNil(t, responses["405"])
+}
+
+func TestGenerateOpenAPI_AsyncOperations(t *testing.T) {
+	registry := newTestRegistry()
+	registry.AsyncOperations = crud.NewAsyncOperationTracker("/api/operations", time.Hour)
+	doc := openAPIDocument(t, registry)
+
+	for _, method := range []string{"put", "delete"} {
+		accepted := lookup(doc, "paths", "/api/things/{id}", method, "responses", "202")
+		assert.Equal(t, "#/components/schemas/AsyncOperation", lookup(accepted, "content", "application/json", "schema", "$ref"), method)
+		assert.NotNil(t, lookup(accepted, "headers", "Location"), method)
+	}
+	assert.Equal(t, "string", lookup(doc, "components", "schemas", "AsyncOperation", "properties", "location", "type"))
+
+	status := lookup(doc, "paths", "/api/operations/{id}", "get")
+	assert.Equal(t, "#/components/schemas/AsyncOperation", lookup(status, "responses", "200", "content", "application/json", "schema", "$ref"))
+	assert.NotNil(t, lookup(status, "responses", "404"))
+	assert.Equal(t, 2, len(lookup(status, "parameters").([]interface{})))
+
+	assert.Nil(t, lookup(openAPIDocument(t, newTestRegistry()), "paths", "/api/operations/{id}"))
+}
+
+func TestCreateCrudHandlerOpenAPI(t *testing.T) {
+	handler := crud.CreateCrudHandlerOpenAPI(newTestContext(), newTestRegistry(), crud.OpenAPIInfo{Title: "Test", Version: "1.0"}, noRecovery)
+	r, _ := http.NewRequest("GET", "/openapi.json", nil)
+	w := httptest.NewRecorder()
+
+	handler(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"openapi":"3.0.0"`)
+}
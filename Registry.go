@@ -0,0 +1,316 @@
+package crud
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+	"github.com/gorilla/mux"
+)
+
+// Operation identifies one of the CRUD operations of a resource
+type Operation string
+
+const (
+	// OperationGetList gets a list of entities
+	OperationGetList Operation = "getList"
+	// OperationGetByID gets a single entity
+	OperationGetByID Operation = "getById"
+	// OperationCreate adds an entity
+	OperationCreate Operation = "create"
+	// OperationUpdate updates an entity
+	OperationUpdate Operation = "update"
+	// OperationDelete deletes an entity
+	OperationDelete Operation = "delete"
+	// OperationAggregate aggregates entities. Only available for resources implemented by an Aggregator.
+	OperationAggregate Operation = "aggregate"
+	// OperationDistinct gets the distinct values of a column. Only available for resources implemented by a
+	// DistinctValuesService or an Aggregator.
+	OperationDistinct Operation = "distinct"
+	// OperationImport adds and updates entities from CSV. Only available for resources exposing create.
+	OperationImport Operation = "import"
+	// OperationAction performs a custom action on an entity. Only available for the actions declared by a resource.
+	OperationAction Operation = "action"
+)
+
+// AllOperations lists all CRUD operations
+var AllOperations = []Operation{OperationGetList, OperationGetByID, OperationCreate, OperationUpdate, OperationDelete}
+
+// Resource describes a CRUD resource, so that it can be registered, routed and described
+type Resource struct {
+	// Name is the name of the resource, which is also its path segment, e.g. "bookings".
+	Name string
+	// Service implements the resource.
+	Service Service
+	// CreateFunc creates an empty entity of the resource. It's used to decode entities, and to describe them.
+	CreateFunc func() Entity
+	// Operations are the operations exposed by the resource. Defaults to AllOperations.
+	Operations []Operation
+	// Policy holds the rules applied to list requests. Optional; defaults to the policy declared by the Service, if any
+	// (see ResourcePolicyProvider).
+	Policy *ResourcePolicy
+	// Parent is the name of the parent resource, for child resources. Child resources are routed under the path of an
+	// entity of their parent, e.g. /bookings/{bookingId}/passengers.
+	Parent string
+	// ParentKey is the name of the path variable holding the key of the parent entity. Defaults to the name of the
+	// parent without its trailing "s", followed by "Id", e.g. "bookingId".
+	ParentKey string
+	// ScopedService implements a child resource that needs the keys of its parents. Used instead of Service. If a child
+	// resource is implemented by a regular Service, its entities hold the key of their parent in the field named after
+	// ParentKey, which the registry checks and sets; the Service sees the keys of the parents in the filters of GetAll.
+	ScopedService ParentScopedService
+	// Relations are the relations to entities of other resources, which clients can have embedded in list and item
+	// responses by passing their names in the expand URI parameter.
+	Relations []Relation
+	// Idempotency stores the responses to create requests carrying an Idempotency-Key header. Optional; without it, the
+	// header is ignored.
+	Idempotency IdempotencyStore
+	// Hooks are run around the mutations of the resource, along with the hooks of its entities (see HookService).
+	// Optional.
+	Hooks *Hooks
+	// Actions are the custom actions that can be performed on the entities of the resource. Optional.
+	Actions []*Action
+}
+
+// Supports tells whether the resource exposes the given operation.
+func (r *Resource) Supports(operation Operation) bool {
+	if len(r.Operations) == 0 {
+		return true
+	}
+	for _, o := range r.Operations {
+		if o == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// aggregates tells whether the resource supports aggregation: it's a top-level resource implemented by an Aggregator.
+func (r *Resource) aggregates() bool {
+	return implements(r.Service, aggregatorInterface) && r.Parent == "" && r.Supports(OperationAggregate)
+}
+
+// distinctValues tells whether the resource supports getting distinct values: it's a top-level resource implemented by
+// a DistinctValuesService or an Aggregator.
+func (r *Resource) distinctValues() bool {
+	return r.Service != nil && supportsDistinctValues(r.Service) && r.Parent == "" && r.Supports(OperationDistinct)
+}
+
+// imports tells whether the resource supports importing entities from CSV, which requires create to be exposed.
+func (r *Resource) imports() bool {
+	return r.Supports(OperationImport) && r.Supports(OperationCreate)
+}
+
+// Registry keeps track of the resources of a service, and routes their CRUD handlers
+type Registry struct {
+	// BasePath is the path the resources are routed under, e.g. "/api". Empty by default.
+	BasePath string
+
+	// AsyncOperations tracks the asynchronous operations started by the services of the resources. Optional; if set, the
+	// status of operations is routed under the base path of the tracker.
+	AsyncOperations *AsyncOperationTracker
+
+	// Batch routes POST on the base path followed by /_batch, for performing several operations in one request (see
+	// CreateCrudHandlerBatch). Disabled by default.
+	Batch bool
+
+	// Metrics receives the number and duration of the requests handled for every resource and operation (see
+	// WithMetrics). Optional.
+	Metrics *Metrics
+
+	// Tracing traces the requests handled for every resource and operation (see WithTracing). Optional.
+	Tracing *Tracing
+
+	ctx         servicefoundation.AppContext
+	recoverFunc RecoverFunc
+
+	mutex     sync.RWMutex
+	resources []*Resource
+}
+
+// NewRegistry creates an empty registry. The context and recovery function are passed to the handlers it routes.
+func NewRegistry(ctx servicefoundation.AppContext, recoverFunc RecoverFunc) *Registry {
+	return &Registry{
+		ctx:         ctx,
+		recoverFunc: recoverFunc,
+		resources:   make([]*Resource, 0),
+	}
+}
+
+// Register adds a resource to the registry. A resource registered under an existing name replaces the existing one.
+func (reg *Registry) Register(resource *Resource) *Resource {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+
+	for i, existing := range reg.resources {
+		if existing.Name == resource.Name {
+			reg.resources[i] = resource
+			return resource
+		}
+	}
+	reg.resources = append(reg.resources, resource)
+	return resource
+}
+
+// Resource returns the registered resource with the given name.
+func (reg *Registry) Resource(name string) (*Resource, bool) {
+	reg.mutex.RLock()
+	defer reg.mutex.RUnlock()
+
+	for _, resource := range reg.resources {
+		if resource.Name == name {
+			return resource, true
+		}
+	}
+	return nil, false
+}
+
+// Resources returns the registered resources, in order of registration.
+func (reg *Registry) Resources() []*Resource {
+	reg.mutex.RLock()
+	defer reg.mutex.RUnlock()
+
+	result := make([]*Resource, len(reg.resources))
+	copy(result, reg.resources)
+	return result
+}
+
+// ResourcePath returns the path a resource is routed under. Child resources are routed under the path of an entity of
+// their parent.
+func (reg *Registry) ResourcePath(resource *Resource) string {
+	path := strings.TrimRight(reg.BasePath, "/")
+	ancestors, _ := reg.ancestors(resource)
+	for i, ancestor := range ancestors {
+		path += "/" + ancestor.Name + "/{" + ancestorKey(resource, ancestors, i) + "}"
+	}
+	return path + "/" + resource.Name
+}
+
+// Capabilities describes all registered resources.
+func (reg *Registry) Capabilities() []*ResourceCapabilities {
+	resources := reg.Resources()
+	result := make([]*ResourceCapabilities, 0, len(resources))
+	for _, resource := range resources {
+		result = append(result, resource.Capabilities(reg.ResourcePath(resource)))
+	}
+	return result
+}
+
+// Route registers the CRUD handlers of all registered resources on the router: GET and POST on the resource path,
+// GET, PUT and DELETE on the resource path followed by /{id}. Operations a resource doesn't expose are routed to
+// ActionNotAvailableHandler. Top-level resources implemented by an Aggregator get GET on the resource path followed by
+// /_aggregate as well, and those implemented by a DistinctValuesService or an Aggregator GET on the resource path
+// followed by /_distinct/{column}. Resources exposing create get POST on the resource path followed by /_import, for
+// importing CSV, and each action of a resource gets POST on the resource path followed by /{id}/actions/{name}. The
+// capabilities of the resources are routed under /_meta, and the status of asynchronous operations under the base path
+// of the AsyncOperations tracker, if set. If Batch is set, batches are routed under /_batch. If Metrics is set, the
+// resource handlers record the metrics of their requests, and if Tracing is set they trace them.
+func (reg *Registry) Route(router *mux.Router) {
+	metaPath := strings.TrimRight(reg.BasePath, "/") + "/_meta"
+	router.HandleFunc(metaPath, CreateCrudHandlerMeta(reg.ctx, reg, reg.recoverFunc)).Methods("GET")
+	router.HandleFunc(metaPath+"/{resource}", CreateCrudHandlerMeta(reg.ctx, reg, reg.recoverFunc)).Methods("GET")
+	if reg.AsyncOperations != nil {
+		router.HandleFunc(reg.AsyncOperations.BasePath+"/{id}", CreateCrudHandlerGetAsyncOperation(reg.ctx, reg.AsyncOperations, reg.recoverFunc)).Methods("GET")
+	}
+
+	if reg.Batch {
+		router.HandleFunc(strings.TrimRight(reg.BasePath, "/")+"/_batch", CreateCrudHandlerBatch(reg.ctx, reg, router, reg.recoverFunc)).Methods("POST")
+	}
+
+	for _, resource := range reg.Resources() {
+		ancestors, err := reg.ancestors(resource)
+		if err != nil {
+			reg.ctx.Logger().Error("RegistryRoute", fmt.Sprintf("Not routing resource %v: %v", resource.Name, err))
+			continue
+		}
+		path := reg.ResourcePath(resource)
+		if resource.aggregates() {
+			router.HandleFunc(path+"/_aggregate", reg.instrument(resource, OperationAggregate, CreateCrudHandlerAggregateWithPolicy(reg.ctx, resource.Service, resource.Name, reg.recoverFunc, resource.ResourcePolicy()))).Methods("GET")
+		}
+		if resource.distinctValues() {
+			router.HandleFunc(path+"/_distinct/{column}", reg.instrument(resource, OperationDistinct, CreateCrudHandlerDistinctValuesWithPolicy(reg.ctx, resource.Service, resource.Name, reg.recoverFunc, resource.ResourcePolicy()))).Methods("GET")
+		}
+		if resource.imports() {
+			router.HandleFunc(path+"/_import", reg.handlerFor(resource, OperationImport, ancestors)).Methods("POST")
+		}
+		router.HandleFunc(path, reg.handlerFor(resource, OperationGetList, ancestors)).Methods("GET")
+		router.HandleFunc(path, reg.handlerFor(resource, OperationCreate, ancestors)).Methods("POST")
+		router.HandleFunc(path+"/{id}", reg.handlerFor(resource, OperationGetByID, ancestors)).Methods("GET")
+		router.HandleFunc(path+"/{id}", reg.handlerFor(resource, OperationUpdate, ancestors)).Methods("PUT")
+		router.HandleFunc(path+"/{id}", reg.handlerFor(resource, OperationDelete, ancestors)).Methods("DELETE")
+		for _, action := range resource.Actions {
+			if err := action.Validate(); err != nil {
+				reg.ctx.Logger().Error("RegistryRoute", fmt.Sprintf("Not routing action of resource %v: %v", resource.Name, err))
+				continue
+			}
+			router.HandleFunc(path+"/{id}/actions/"+action.Name, reg.actionHandler(resource, action, ancestors)).Methods("POST")
+		}
+	}
+}
+
+// instrument records the metrics of the requests handled by the handler, if the registry has Metrics, and traces them,
+// if it has Tracing.
+func (reg *Registry) instrument(resource *Resource, operation Operation, handler http.HandlerFunc) http.HandlerFunc {
+	if reg.Tracing != nil {
+		handler = WithTracing(reg.Tracing, resource.Name, operation, handler)
+	}
+	if reg.Metrics != nil {
+		handler = WithMetrics(reg.Metrics, resource.Name, operation, handler)
+	}
+	return handler
+}
+
+func (reg *Registry) handlerFor(resource *Resource, operation Operation, ancestors []*Resource) http.HandlerFunc {
+	if !resource.Supports(operation) {
+		return reg.instrument(resource, operation, ActionNotAvailableHandler)
+	}
+	if len(ancestors) > 0 {
+		return reg.instrument(resource, operation, reg.scopedHandler(resource, ancestors, func(svc Service) http.HandlerFunc {
+			return reg.operationHandler(resource, svc, operation)
+		}))
+	}
+	return reg.instrument(resource, operation, reg.operationHandler(resource, resource.Service, operation))
+}
+
+func (reg *Registry) actionHandler(resource *Resource, action *Action, ancestors []*Resource) http.HandlerFunc {
+	handlerFor := func(svc Service) http.HandlerFunc {
+		return CreateCrudHandlerAction(reg.ctx, resource.hooked(svc), resource.Name, reg.recoverFunc, action)
+	}
+	if len(ancestors) > 0 {
+		return reg.instrument(resource, OperationAction, reg.scopedHandler(resource, ancestors, handlerFor))
+	}
+	return reg.instrument(resource, OperationAction, handlerFor(resource.Service))
+}
+
+// operationHandler returns the handler for an operation on a resource implemented by the given Service, expanding
+// relations if the resource has any.
+func (reg *Registry) operationHandler(resource *Resource, svc Service, operation Operation) http.HandlerFunc {
+	if len(resource.Relations) > 0 && (operation == OperationGetList || operation == OperationGetByID) {
+		return reg.expandingHandler(resource, svc, operation)
+	}
+	return reg.handlerForService(resource, svc, operation)
+}
+
+func (reg *Registry) handlerForService(resource *Resource, svc Service, operation Operation) http.HandlerFunc {
+	switch operation {
+	case OperationGetList:
+		return CreateCrudHandlerGetListWithPolicy(reg.ctx, svc, resource.Name, reg.recoverFunc, resource.ResourcePolicy())
+	case OperationGetByID:
+		return CreateCrudHandlerGetByID(reg.ctx, svc, resource.Name, reg.recoverFunc)
+	case OperationCreate:
+		handler := CreateCrudHandlerCreateEntity(reg.ctx, resource.hooked(svc), resource.Name, reg.recoverFunc, resource.CreateFunc)
+		if resource.Idempotency != nil {
+			return WithIdempotency(reg.ctx, resource.Idempotency, handler)
+		}
+		return handler
+	case OperationImport:
+		return CreateCrudHandlerImportCSV(reg.ctx, resource.hooked(svc), resource.Name, reg.recoverFunc, resource.CreateFunc)
+	case OperationUpdate:
+		return CreateCrudHandlerUpdateEntity(reg.ctx, resource.hooked(svc), resource.Name, reg.recoverFunc, resource.CreateFunc)
+	case OperationDelete:
+		return CreateCrudHandlerDeleteByID(reg.ctx, resource.hooked(svc), resource.Name, reg.recoverFunc)
+	}
+	return ActionNotAvailableHandler
+}
@@ -0,0 +1,63 @@
+package crud_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEntity() crud.Entity {
+	return &testEntity{}
+}
+
+func newTestRegistry() *crud.Registry {
+	registry := crud.NewRegistry(newTestContext(), crud.Recovery)
+	registry.BasePath = "/api/"
+	registry.Register(&crud.Resource{Name: "things", Service: newMemoryService(), CreateFunc: newTestEntity})
+	registry.Register(&crud.Resource{
+		Name:       "lookups",
+		Service:    newMemoryService(),
+		CreateFunc: newTestEntity,
+		Operations: []crud.Operation{crud.OperationGetList, crud.OperationGetByID},
+	})
+	return registry
+}
+
+func TestRegistry_Route(t *testing.T) {
+	router := mux.NewRouter()
+	newTestRegistry().Route(router)
+
+	r, _ := http.NewRequest("POST", "/api/things", strings.NewReader(`{"name":"first"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	r, _ = http.NewRequest("GET", "/api/things/1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"first"`)
+
+	r, _ = http.NewRequest("POST", "/api/lookups", strings.NewReader(`{"name":"first"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestRegistry_RegisterReplacesByName(t *testing.T) {
+	registry := newTestRegistry()
+	replacement := &crud.Resource{Name: "things", Service: newMemoryService(), CreateFunc: newTestEntity}
+
+	registry.Register(replacement)
+
+	resource, ok := registry.Resource("things")
+	assert.True(t, ok)
+	assert.Equal(t, replacement, resource)
+	assert.Equal(t, 2, len(registry.Resources()))
+	assert.Equal(t, "things", registry.Resources()[0].Name)
+}
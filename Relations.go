@@ -0,0 +1,191 @@
+package crud
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Relation declares that the entities of a resource refer to the entities of another registered resource
+type Relation struct {
+	// Name is the name clients pass in the expand URI parameter. The related entity is embedded under this name.
+	Name string `json:"name"`
+	// Resource is the name of the related resource.
+	Resource string `json:"resource"`
+	// ForeignKey is the JSON field holding the ID of the related entity.
+	ForeignKey string `json:"foreignKey"`
+}
+
+// BatchGetter can be implemented by a Service to resolve relations to it in a single call, rather than calling GetByID for
+// every related entity.
+type BatchGetter interface {
+	// GetByIDs returns the entities with the given IDs, as a map[string]interface{} keyed by the string representation of
+	// their IDs. Entities that don't exist are left out.
+	GetByIDs(ids []EntityKey) OperationResult
+}
+
+// relation returns the relation of the resource with the given name.
+func (r *Resource) relation(name string) (Relation, bool) {
+	for _, relation := range r.Relations {
+		if strings.EqualFold(relation.Name, name) {
+			return relation, true
+		}
+	}
+	return Relation{}, false
+}
+
+// expandingHandler serves list and item requests on a resource with relations: if the request asks to expand any of
+// them, the related entities are embedded in the response.
+func (reg *Registry) expandingHandler(resource *Resource, svc Service, operation Operation) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expand := ExtractExpandFromURI(r)
+		if len(expand) == 0 {
+			reg.handlerForService(resource, svc, operation)(w, r)
+			return
+		}
+
+		relations := make([]Relation, 0, len(expand))
+		for _, name := range expand {
+			relation, ok := resource.relation(name)
+			if !ok {
+				WriteOperationResult(w, r, ValidationFailedResult(errors.New("Unknown relation: "+name)))
+				return
+			}
+			relations = append(relations, relation)
+		}
+		expanding := &expandingService{Service: svc, registry: reg, relations: relations}
+		reg.handlerForService(resource, expanding, operation)(w, r)
+	}
+}
+
+// expandingService embeds related entities in the entities returned by the wrapped Service
+type expandingService struct {
+	Service
+	registry  *Registry
+	relations []Relation
+}
+
+func (s *expandingService) GetAll(request *DataSetRequest) OperationResult {
+	return s.expandDataSet(s.Service.GetAll(request))
+}
+
+func (s *expandingService) GetByID(id EntityKey) OperationResult {
+	return s.expandEntity(s.Service.GetByID(id))
+}
+
+// GetAllAsOf expands the entities as they were at the given moment, if the wrapped Service supports it
+func (s *expandingService) GetAllAsOf(request *DataSetRequest, asOf time.Time) OperationResult {
+	if asOfSvc, ok := s.Service.(AsOfService); ok {
+		return s.expandDataSet(asOfSvc.GetAllAsOf(request, asOf))
+	}
+	return NotSupportedByResourceResult()
+}
+
+// GetByIDAsOf expands the entity as it was at the given moment, if the wrapped Service supports it
+func (s *expandingService) GetByIDAsOf(id EntityKey, asOf time.Time) OperationResult {
+	if asOfSvc, ok := s.Service.(AsOfService); ok {
+		return s.expandEntity(asOfSvc.GetByIDAsOf(id, asOf))
+	}
+	return NotSupportedByResourceResult()
+}
+
+func (s *expandingService) expandDataSet(result OperationResult) OperationResult {
+	if result.State() != Ok {
+		return result
+	}
+	dataSet, ok := result.Value().(*DataSet)
+	if !ok || dataSet == nil {
+		return result
+	}
+	items, err := s.expand(dataSet.Items)
+	if err != nil {
+		return ErrorResult(err)
+	}
+	return OkResult(&DataSet{Items: items, PagingInfo: dataSet.PagingInfo})
+}
+
+func (s *expandingService) expandEntity(result OperationResult) OperationResult {
+	if result.State() != Ok || result.Value() == nil {
+		return result
+	}
+	items, err := s.expand([]interface{}{result.Value()})
+	if err != nil {
+		return ErrorResult(err)
+	}
+	return OkResult(items[0])
+}
+
+// expand flattens the entities and embeds the related entities, resolving each relation in one batch.
+func (s *expandingService) expand(entities []interface{}) ([]interface{}, error) {
+	flattened := make([]map[string]interface{}, len(entities))
+	for i, entity := range entities {
+		fields := entityFields(entity)
+		if fields == nil {
+			return nil, fmt.Errorf("Can't expand relations of %T", entity)
+		}
+		// Entities held as maps may be shared by the Service; embed the relations in a copy
+		flattened[i] = make(map[string]interface{}, len(fields)+len(s.relations))
+		for k, v := range fields {
+			flattened[i][k] = v
+		}
+	}
+
+	for _, relation := range s.relations {
+		keys := make([]EntityKey, 0)
+		seen := make(map[string]bool)
+		for _, fields := range flattened {
+			if key, ok := foreignKey(fields, relation); ok && !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+
+		related, err := s.resolve(relation, keys)
+		if err != nil {
+			return nil, err
+		}
+		for _, fields := range flattened {
+			key, _ := foreignKey(fields, relation)
+			fields[relation.Name] = related[key]
+		}
+	}
+
+	result := make([]interface{}, len(flattened))
+	for i, fields := range flattened {
+		result[i] = fields
+	}
+	return result, nil
+}
+
+// resolve gets the related entities with the given keys, by the string representation of their keys. Entities that don't
+// exist are left out.
+func (s *expandingService) resolve(relation Relation, keys []EntityKey) (map[string]interface{}, error) {
+	if len(keys) == 0 {
+		return make(map[string]interface{}), nil
+	}
+	resource, ok := s.registry.Resource(relation.Resource)
+	if !ok {
+		return nil, fmt.Errorf("Related resource %v of relation %v is not registered", relation.Resource, relation.Name)
+	}
+	svc := resource.service(ParentKeys{})
+
+	opResult := getByIDs(svc, keys)
+	if opResult.State() != Ok {
+		return nil, fmt.Errorf("Failed to resolve relation %v: %v", relation.Name, opResult.Error())
+	}
+	if entities, ok := opResult.Value().(map[string]interface{}); ok {
+		return entities, nil
+	}
+	return nil, fmt.Errorf("Failed to resolve relation %v: unexpected value %T", relation.Name, opResult.Value())
+}
+
+// foreignKey returns the string representation of the foreign key of a relation in a flattened entity.
+func foreignKey(fields map[string]interface{}, relation Relation) (string, bool) {
+	value, ok := fieldValue(fields, relation.ForeignKey)
+	if !ok || value == nil {
+		return "", false
+	}
+	return formatKey(value), true
+}
@@ -0,0 +1,182 @@
+package crud_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingService counts the calls to GetByID
+type countingService struct {
+	*memoryService
+	gets int
+}
+
+func (s *countingService) GetByID(id crud.EntityKey) crud.OperationResult {
+	s.gets++
+	return s.memoryService.GetByID(id)
+}
+
+// batchService resolves relations in one call
+type batchService struct {
+	*countingService
+	batches [][]crud.EntityKey
+}
+
+func (s *batchService) GetByIDs(ids []crud.EntityKey) crud.OperationResult {
+	s.batches = append(s.batches, ids)
+	result := make(map[string]interface{})
+	for _, id := range ids {
+		if opResult := s.memoryService.GetByID(id); opResult.State() == crud.Ok {
+			result[fmt.Sprint(id)] = opResult.Value()
+		}
+	}
+	return crud.OkResult(result)
+}
+
+// newRelationsRouter routes orders, whose status holds the ID of their customer
+func newRelationsRouter(customers crud.Service) *mux.Router {
+	orders := newMemoryService()
+	orders.Add(&testEntity{Name: "first order", Status: "1"})
+	orders.Add(&testEntity{Name: "second order", Status: "2"})
+	orders.Add(&testEntity{Name: "third order", Status: "1"})
+	orders.Add(&testEntity{Name: "orphaned order", Status: "9"})
+
+	registry := crud.NewRegistry(newTestContext(), crud.Recovery)
+	registry.Register(&crud.Resource{Name: "customers", Service: customers, CreateFunc: newTestEntity})
+	registry.Register(&crud.Resource{
+		Name:       "orders",
+		Service:    orders,
+		CreateFunc: newTestEntity,
+		Relations:  []crud.Relation{{Name: "customer", Resource: "customers", ForeignKey: "status"}},
+	})
+	router := mux.NewRouter()
+	registry.Route(router)
+	return router
+}
+
+func newCustomers() *memoryService {
+	customers := newMemoryService()
+	customers.Add(&testEntity{Name: "alice"})
+	customers.Add(&testEntity{Name: "bob"})
+	return customers
+}
+
+func TestRegistry_ExpandList(t *testing.T) {
+	customers := &countingService{memoryService: newCustomers()}
+	router := newRelationsRouter(customers)
+
+	w := serve(router, "GET", "/orders?expand=customer", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var dataSet struct {
+		Items []struct {
+			Name     string      `json:"name"`
+			Customer *testEntity `json:"customer"`
+		} `json:"items"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &dataSet))
+	assert.Equal(t, 4, len(dataSet.Items))
+	assert.Equal(t, "alice", dataSet.Items[0].Customer.Name)
+	assert.Equal(t, "bob", dataSet.Items[1].Customer.Name)
+	assert.Equal(t, "alice", dataSet.Items[2].Customer.Name)
+	assert.Nil(t, dataSet.Items[3].Customer)
+	// Every customer is requested once
+	assert.Equal(t, 3, customers.gets)
+}
+
+func TestRegistry_ExpandListInBatch(t *testing.T) {
+	customers := &batchService{countingService: &countingService{memoryService: newCustomers()}}
+	router := newRelationsRouter(customers)
+
+	w := serve(router, "GET", "/orders?expand=customer", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, len(customers.batches))
+	assert.Equal(t, []crud.EntityKey{"1", "2", "9"}, customers.batches[0])
+	assert.Equal(t, 0, customers.gets)
+	assert.Contains(t, w.Body.String(), `"customer":{"id":2,"name":"bob","status":""}`)
+}
+
+func TestRegistry_ExpandItem(t *testing.T) {
+	router := newRelationsRouter(newCustomers())
+
+	w := serve(router, "GET", "/orders/2?expand=customer", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"customer":{"id":2,"name":"bob","status":""}`)
+}
+
+func TestRegistry_ExpandNothing(t *testing.T) {
+	router := newRelationsRouter(newCustomers())
+
+	w := serve(router, "GET", "/orders/2", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "customer")
+}
+
+func TestRegistry_ExpandUnknownRelation(t *testing.T) {
+	router := newRelationsRouter(newCustomers())
+
+	w := serve(router, "GET", "/orders?expand=customer,supplier", "")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "supplier")
+}
+
+// mapService holds its entities as maps, as decoded from JSON
+type mapService struct {
+	*memoryService
+	items []interface{}
+}
+
+func (s *mapService) GetAll(request *crud.DataSetRequest) crud.OperationResult {
+	return crud.OkResult(&crud.DataSet{Items: s.items, PagingInfo: crud.PagingInfo{PageSize: len(s.items), PageNumber: 1}})
+}
+
+func (s *mapService) GetByID(id crud.EntityKey) crud.OperationResult {
+	return crud.OkResult(s.items[0])
+}
+
+func TestRegistry_ExpandMaps(t *testing.T) {
+	customers := newMemoryService()
+	customers.lastID = 999999
+	customers.Add(&testEntity{Name: "alice"})
+	order := map[string]interface{}{"name": "big order", "customerId": float64(1000000)}
+	registry := crud.NewRegistry(newTestContext(), crud.Recovery)
+	registry.Register(&crud.Resource{Name: "customers", Service: customers, CreateFunc: newTestEntity})
+	registry.Register(&crud.Resource{
+		Name:       "orders",
+		Service:    &mapService{memoryService: newMemoryService(), items: []interface{}{order}},
+		CreateFunc: newTestEntity,
+		Relations:  []crud.Relation{{Name: "customer", Resource: "customers", ForeignKey: "customerId"}},
+	})
+	router := mux.NewRouter()
+	registry.Route(router)
+
+	for _, path := range []string{"/orders?expand=customer", "/orders/1?expand=customer"} {
+		w := serve(router, "GET", path, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"customer":{"id":1000000,"name":"alice","status":""}`, path)
+	}
+	// The entities of the Service are left alone
+	assert.Equal(t, map[string]interface{}{"name": "big order", "customerId": float64(1000000)}, order)
+}
+
+func TestExtractExpandFromURI(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/orders?Expand=customer,%20supplier,,", nil)
+
+	assert.Equal(t, []string{"customer", "supplier"}, crud.ExtractExpandFromURI(r))
+
+	// Relations are collected from the parameter in any casing
+	r, _ = http.NewRequest("GET", "/orders?expand=customer&Expand=supplier", nil)
+	assert.ElementsMatch(t, []string{"customer", "supplier"}, crud.ExtractExpandFromURI(r))
+	r, _ = http.NewRequest("GET", "/orders?expand=", nil)
+	assert.Nil(t, crud.ExtractExpandFromURI(r))
+}
@@ -0,0 +1,128 @@
+package crud
+
+import "reflect"
+
+// ResourcePolicy declares the rules for list requests on a resource. It replaces calling ConstrainPagingRequest,
+// ConstrainSortColumns and ConstrainFilterColumns from within the Service, so the rules can be applied by the list
+// handler and described to clients.
+type ResourcePolicy struct {
+	// MinPageSize is the minimum page size. Values below 1 are treated as 1.
+	MinPageSize int `json:"minPageSize"`
+	// MaxPageSize is the maximum page size. Zero means unlimited.
+	MaxPageSize int `json:"maxPageSize"`
+	// DefaultSortColumn is used when sorting on a column that's not allowed.
+	DefaultSortColumn string `json:"defaultSortColumn"`
+	// SortColumns are the columns that can be sorted on. Empty means any column.
+	SortColumns []string `json:"sortColumns"`
+	// FilterColumns are the columns that can be filtered on. Nil means any column; an empty collection means none.
+	FilterColumns []string `json:"filterColumns"`
+	// Strict makes the list handler reject requests that break the rules, instead of silently constraining them. Invalid
+	// and unknown URI parameters are rejected as well (see ParseDataSetRequestStrict).
+	Strict bool `json:"strict"`
+}
+
+// ResourcePolicyProvider can be implemented by a Service to have its policy applied by the list handler.
+type ResourcePolicyProvider interface {
+	ResourcePolicy() *ResourcePolicy
+}
+
+// Apply constrains the request to the policy.
+func (p *ResourcePolicy) Apply(r *DataSetRequest) {
+	minPageSize := p.MinPageSize
+	if minPageSize < 1 {
+		minPageSize = 1
+	}
+	if r.PageSize < minPageSize {
+		r.PageSize = minPageSize
+	}
+	if p.MaxPageSize > 0 {
+		ConstrainPagingRequest(r, minPageSize, p.MaxPageSize)
+	}
+	if len(p.SortColumns) > 0 {
+		ConstrainSortColumns(r, p.DefaultSortColumn, p.SortColumns...)
+	}
+	if p.FilterColumns != nil {
+		ConstrainFilterColumns(r, p.FilterColumns...)
+	}
+}
+
+// ResourceCapabilities describes what clients can do with a resource
+type ResourceCapabilities struct {
+	// Name is the name of the resource.
+	Name string `json:"name"`
+	// Path is where the resource is routed.
+	Path string `json:"path"`
+	// Parent is the name of the parent resource, for child resources.
+	Parent string `json:"parent,omitempty"`
+	// Operations are the supported operations. Others result in NotSupportedByResource.
+	Operations []Operation `json:"operations"`
+	// Fields are the JSON fields of the entities, if they're known.
+	Fields []string `json:"fields"`
+	// Policy holds the rules for list requests, if any.
+	Policy *ResourcePolicy `json:"policy"`
+	// Relations are the relations that can be expanded, if any.
+	Relations []Relation `json:"relations,omitempty"`
+	// Actions are the names of the custom actions that can be performed on the entities, if any.
+	Actions []string `json:"actions,omitempty"`
+}
+
+// policyFor returns the policy of a Service, or nil if it doesn't declare one.
+func policyFor(svc Service) *ResourcePolicy {
+	if provider, ok := svc.(ResourcePolicyProvider); ok {
+		return provider.ResourcePolicy()
+	}
+	return nil
+}
+
+// ResourcePolicy returns the policy applied to the list requests of the resource: the one set on the resource itself, or
+// else the one declared by its Service. Adapters exposing the resource other than through the registry should use it.
+func (r *Resource) ResourcePolicy() *ResourcePolicy {
+	if r.Policy != nil {
+		return r.Policy
+	}
+	if r.ScopedService != nil {
+		if provider, ok := r.ScopedService.(ResourcePolicyProvider); ok {
+			return provider.ResourcePolicy()
+		}
+		return nil
+	}
+	return policyFor(r.Service)
+}
+
+// Capabilities describes the resource.
+func (r *Resource) Capabilities(path string) *ResourceCapabilities {
+	capabilities := &ResourceCapabilities{
+		Name:       r.Name,
+		Path:       path,
+		Parent:     r.Parent,
+		Relations:  r.Relations,
+		Operations: make([]Operation, 0, len(AllOperations)),
+		Fields:     make([]string, 0),
+		Policy:     r.ResourcePolicy(),
+	}
+	for _, operation := range AllOperations {
+		if r.Supports(operation) {
+			capabilities.Operations = append(capabilities.Operations, operation)
+		}
+	}
+	if r.aggregates() {
+		capabilities.Operations = append(capabilities.Operations, OperationAggregate)
+	}
+	if r.distinctValues() {
+		capabilities.Operations = append(capabilities.Operations, OperationDistinct)
+	}
+	if r.imports() {
+		capabilities.Operations = append(capabilities.Operations, OperationImport)
+	}
+	for _, action := range r.Actions {
+		if action.Validate() == nil {
+			capabilities.Actions = append(capabilities.Actions, action.Name)
+		}
+	}
+	if t := entityType(r.CreateFunc); t != nil && t.Kind() == reflect.Struct {
+		for _, f := range structFields(t) {
+			capabilities.Fields = append(capabilities.Fields, f.Name)
+		}
+	}
+	return capabilities
+}
@@ -0,0 +1,104 @@
+package crud_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+var testPolicy = &crud.ResourcePolicy{
+	MinPageSize:       5,
+	MaxPageSize:       50,
+	DefaultSortColumn: "id",
+	SortColumns:       []string{"id", "name"},
+	FilterColumns:     []string{"status"},
+}
+
+// capturingService remembers the last list request, and declares testPolicy
+type capturingService struct {
+	crud.Service
+	request *crud.DataSetRequest
+}
+
+func (s *capturingService) GetAll(request *crud.DataSetRequest) crud.OperationResult {
+	s.request = request
+	return crud.OkResult(&crud.DataSet{Items: []interface{}{}})
+}
+
+func (s *capturingService) ResourcePolicy() *crud.ResourcePolicy {
+	return testPolicy
+}
+
+func TestResourcePolicy_Apply(t *testing.T) {
+	request := &crud.DataSetRequest{
+		PageSize:   500,
+		SortColumn: "secret",
+		Filters:    map[string]string{"status": "new", "secret": "x"},
+	}
+
+	testPolicy.Apply(request)
+
+	assert.Equal(t, 50, request.PageSize)
+	assert.Equal(t, "id", request.SortColumn)
+	assert.Equal(t, map[string]string{"status": "new"}, request.Filters)
+}
+
+func TestResourcePolicy_ApplyWithoutRestrictions(t *testing.T) {
+	request := &crud.DataSetRequest{
+		PageSize:   0,
+		SortColumn: "anything",
+		Filters:    map[string]string{"anything": "x"},
+	}
+
+	(&crud.ResourcePolicy{}).Apply(request)
+
+	assert.Equal(t, 1, request.PageSize)
+	assert.Equal(t, "anything", request.SortColumn)
+	assert.Equal(t, 1, len(request.Filters))
+}
+
+func TestCreateCrudHandlerGetList_AppliesPolicyOfService(t *testing.T) {
+	svc := &capturingService{}
+	handler := crud.CreateCrudHandlerGetList(newTestContext(), svc, "things", noRecovery)
+	r, _ := http.NewRequest("GET", `/things?pageSize=2&sortColumn=name&filters={"secret":"x"}`, nil)
+	w := httptest.NewRecorder()
+
+	handler(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 5, svc.request.PageSize)
+	assert.Equal(t, "name", svc.request.SortColumn)
+	assert.Equal(t, 0, len(svc.request.Filters))
+}
+
+func TestCreateCrudHandlerMeta(t *testing.T) {
+	registry := newTestRegistry()
+	registry.Register(&crud.Resource{Name: "captured", Service: &capturingService{}, Operations: []crud.Operation{crud.OperationGetList}})
+	router := mux.NewRouter()
+	registry.Route(router)
+
+	r, _ := http.NewRequest("GET", "/api/_meta", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var capabilities []*crud.ResourceCapabilities
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &capabilities))
+	assert.Equal(t, 3, len(capabilities))
+	assert.Equal(t, "/api/things", capabilities[0].Path)
+	assert.Equal(t, []string{"id", "name", "status"}, capabilities[0].Fields)
+	assert.Nil(t, capabilities[0].Policy)
+	assert.Equal(t, []crud.Operation{crud.OperationGetList, crud.OperationGetByID}, capabilities[1].Operations)
+	assert.Equal(t, testPolicy, capabilities[2].Policy)
+
+	r, _ = http.NewRequest("GET", "/api/_meta/unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,179 @@
+package crud
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DataSetQueryParameters are the URI parameters understood by the list handler, in lower case. Strict parsing rejects any
+// other parameter.
+var DataSetQueryParameters = []string{"pagesize", "pagenumber", "sortcolumn", "sortdirection", "filters", "asof", "expand"}
+
+// QueryProblem describes a single problem with the URI parameters of a list request
+type QueryProblem struct {
+	// Parameter is the name of the offending URI parameter.
+	Parameter string `json:"parameter"`
+	// Value is the offending value, if applicable.
+	Value string `json:"value,omitempty"`
+	// Message describes the problem.
+	Message string `json:"message"`
+}
+
+// QueryValidationError lists all problems found in the URI parameters of a list request
+type QueryValidationError struct {
+	Problems []QueryProblem
+}
+
+// Error summarizes the problems
+func (e *QueryValidationError) Error() string {
+	messages := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		messages = append(messages, p.Parameter+": "+p.Message)
+	}
+	return "Invalid query: " + strings.Join(messages, "; ")
+}
+
+// Details returns the individual problems
+func (e *QueryValidationError) Details() interface{} {
+	return e.Problems
+}
+
+// ParseDataSetRequestStrict parses URI parameters into a DataSet request like ExtractDataSetRequestFromURI does, but instead
+// of ignoring invalid values, it reports them. If a policy is given, requests breaking its rules are reported too, rather
+// than constrained. The error is nil if no problems were found.
+func ParseDataSetRequestStrict(r *http.Request, policy *ResourcePolicy) (*DataSetRequest, *QueryValidationError) {
+	dsReq := &DataSetRequest{
+		PageSize:      15,
+		PageNumber:    1,
+		SortColumn:    "id",
+		SortDirection: string(Asc),
+		Filters:       nil,
+	}
+	if policy != nil {
+		// Defaults never break the policy; only the parameters given do
+		policy.Apply(dsReq)
+		if policy.DefaultSortColumn != "" {
+			dsReq.SortColumn = policy.DefaultSortColumn
+		}
+	}
+	problems := make([]QueryProblem, 0)
+	report := func(parameter, value, message string) {
+		problems = append(problems, QueryProblem{Parameter: parameter, Value: value, Message: message})
+	}
+
+	sortColumnGiven := false
+	query := r.URL.Query()
+	// Sorted, so the problems are reported in a stable order
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := query[k][0]
+		switch strings.ToLower(k) {
+		case "pagesize":
+			intVal, err := strconv.Atoi(v)
+			if err != nil || intVal < 1 {
+				report(k, v, "must be a whole number of at least 1")
+				continue
+			}
+			dsReq.PageSize = intVal
+		case "pagenumber":
+			intVal, err := strconv.Atoi(v)
+			if err != nil || intVal < 1 {
+				report(k, v, "must be a whole number of at least 1")
+				continue
+			}
+			dsReq.PageNumber = intVal
+		case "sortcolumn":
+			dsReq.SortColumn = v
+			sortColumnGiven = true
+		case "sortdirection":
+			switch strings.ToLower(v) {
+			case "asc":
+				dsReq.SortDirection = string(Asc)
+			case "desc":
+				dsReq.SortDirection = string(Desc)
+			default:
+				report(k, v, "must be Asc or Desc")
+			}
+		case "filters":
+			filterKvs := make(map[string]string)
+			if err := json.Unmarshal([]byte(v), &filterKvs); err != nil {
+				report(k, v, "must be a JSON object of column names and string values")
+				continue
+			}
+			dsReq.Filters = filterKvs
+		case "asof":
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				report(k, v, "must be an RFC 3339 timestamp")
+			}
+		default:
+			if !isDataSetQueryParameter(k) {
+				report(k, v, "unknown parameter")
+			}
+		}
+	}
+
+	if policy != nil {
+		for _, problem := range policy.Check(dsReq) {
+			// The default sort column is used, not checked, if no sort column is given
+			if problem.Parameter == "sortColumn" && !sortColumnGiven {
+				continue
+			}
+			problems = append(problems, problem)
+		}
+	}
+	if len(problems) > 0 {
+		return dsReq, &QueryValidationError{Problems: problems}
+	}
+	return dsReq, nil
+}
+
+// Check reports the ways in which a request breaks the rules of the policy. It's the strict counterpart of Apply.
+func (p *ResourcePolicy) Check(r *DataSetRequest) []QueryProblem {
+	problems := make([]QueryProblem, 0)
+
+	if p.MinPageSize > 1 && r.PageSize < p.MinPageSize {
+		problems = append(problems, QueryProblem{Parameter: "pageSize", Value: strconv.Itoa(r.PageSize), Message: fmt.Sprintf("must be at least %v", p.MinPageSize)})
+	}
+	if p.MaxPageSize > 0 && r.PageSize > p.MaxPageSize {
+		problems = append(problems, QueryProblem{Parameter: "pageSize", Value: strconv.Itoa(r.PageSize), Message: fmt.Sprintf("must be at most %v", p.MaxPageSize)})
+	}
+	if len(p.SortColumns) > 0 && !containsString(p.SortColumns, r.SortColumn) {
+		problems = append(problems, QueryProblem{Parameter: "sortColumn", Value: r.SortColumn, Message: "can't sort on this column, use one of: " + strings.Join(p.SortColumns, ", ")})
+	}
+	if p.FilterColumns != nil {
+		columns := make([]string, 0, len(r.Filters))
+		for k := range r.Filters {
+			columns = append(columns, k)
+		}
+		sort.Strings(columns)
+		for _, column := range columns {
+			if !containsString(p.FilterColumns, column) {
+				problems = append(problems, QueryProblem{Parameter: "filters", Value: column, Message: "can't filter on this column"})
+			}
+		}
+	}
+	return problems
+}
+
+func isDataSetQueryParameter(name string) bool {
+	return containsString(DataSetQueryParameters, strings.ToLower(name))
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package crud_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/stretchr/testify/assert"
+)
+
+var strictTestPolicy = &crud.ResourcePolicy{
+	MinPageSize:       5,
+	MaxPageSize:       50,
+	DefaultSortColumn: "id",
+	SortColumns:       []string{"id", "name"},
+	FilterColumns:     []string{"status"},
+	Strict:            true,
+}
+
+func TestParseDataSetRequestStrict(t *testing.T) {
+	r, _ := http.NewRequest("GET", `/things?pageSize=20&pageNumber=3&sortColumn=name&sortDirection=desc&filters={"status":"new"}`, nil)
+
+	request, problems := crud.ParseDataSetRequestStrict(r, strictTestPolicy)
+
+	assert.Nil(t, problems)
+	assert.Equal(t, 20, request.PageSize)
+	assert.Equal(t, 3, request.PageNumber)
+	assert.Equal(t, "name", request.SortColumn)
+	assert.Equal(t, string(crud.Desc), request.SortDirection)
+	assert.Equal(t, map[string]string{"status": "new"}, request.Filters)
+}
+
+func TestParseDataSetRequestStrict_DefaultsFollowPolicy(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/things", nil)
+
+	request, problems := crud.ParseDataSetRequestStrict(r, &crud.ResourcePolicy{MaxPageSize: 10, Strict: true})
+
+	assert.Nil(t, problems)
+	assert.Equal(t, 10, request.PageSize)
+}
+
+func TestParseDataSetRequestStrict_ReportsAllProblems(t *testing.T) {
+	r, _ := http.NewRequest("GET", `/things?pageSize=500&pageNumber=x&sortColumn=secret&sortDirection=up&filters={"secret":"x"}&bogus=1`, nil)
+
+	_, problems := crud.ParseDataSetRequestStrict(r, strictTestPolicy)
+
+	if assert.NotNil(t, problems) {
+		parameters := make([]string, 0)
+		for _, p := range problems.Problems {
+			parameters = append(parameters, p.Parameter)
+		}
+		assert.Equal(t, []string{"bogus", "pageNumber", "sortDirection", "pageSize", "sortColumn", "filters"}, parameters)
+	}
+}
+
+func TestParseDataSetRequestStrict_DefaultSortColumn(t *testing.T) {
+	policy := &crud.ResourcePolicy{DefaultSortColumn: "secret", SortColumns: []string{"id", "name"}, Strict: true}
+
+	r, _ := http.NewRequest("GET", "/things", nil)
+	request, problems := crud.ParseDataSetRequestStrict(r, policy)
+	assert.Nil(t, problems)
+	assert.Equal(t, "secret", request.SortColumn)
+
+	// Asking for the default sort column explicitly is checked like any other column
+	r, _ = http.NewRequest("GET", "/things?sortColumn=secret", nil)
+	_, problems = crud.ParseDataSetRequestStrict(r, policy)
+	if assert.NotNil(t, problems) {
+		assert.Equal(t, "sortColumn", problems.Problems[0].Parameter)
+	}
+}
+
+func TestParseDataSetRequestStrict_InvalidValuesWithoutPolicy(t *testing.T) {
+	r, _ := http.NewRequest("GET", `/things?pageSize=0&filters=nope&asOf=yesterday`, nil)
+
+	_, problems := crud.ParseDataSetRequestStrict(r, nil)
+
+	if assert.NotNil(t, problems) {
+		assert.Equal(t, 3, len(problems.Problems))
+	}
+}
+
+func TestCreateCrudHandlerGetListWithPolicy_Strict(t *testing.T) {
+	svc := &capturingService{}
+	handler := crud.CreateCrudHandlerGetListWithPolicy(newTestContext(), svc, "things", noRecovery, strictTestPolicy)
+	r, _ := http.NewRequest("GET", `/things?pageSize=2&filters={"secret":"x"}`, nil)
+	w := httptest.NewRecorder()
+
+	handler(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Nil(t, svc.request)
+
+	var details struct {
+		Message string              `json:"message"`
+		Details []crud.QueryProblem `json:"details"`
+	}
+	if assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &details)) {
+		assert.Equal(t, 2, len(details.Details))
+		assert.Equal(t, "pageSize", details.Details[0].Parameter)
+		assert.Equal(t, "filters", details.Details[1].Parameter)
+		assert.Equal(t, "secret", details.Details[1].Value)
+	}
+}
+
+func TestCreateCrudHandlerGetListWithPolicy_StrictAcceptsValidRequest(t *testing.T) {
+	svc := &capturingService{}
+	handler := crud.CreateCrudHandlerGetListWithPolicy(newTestContext(), svc, "things", noRecovery, strictTestPolicy)
+	r, _ := http.NewRequest("GET", `/things?PageSize=10&sortColumn=name`, nil)
+	w := httptest.NewRecorder()
+
+	handler(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 10, svc.request.PageSize)
+	assert.Equal(t, "name", svc.request.SortColumn)
+}
@@ -0,0 +1,169 @@
+package crud
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the tracer of the spans, i.e. the instrumentation scope.
+const tracerName = "github.com/Travix-International/crud-go"
+
+// The attributes of the spans
+const (
+	// AttributeResource is the name of the resource.
+	AttributeResource = attribute.Key("crud.resource")
+	// AttributeOperation is the operation performed.
+	AttributeOperation = attribute.Key("crud.operation")
+	// AttributeKey is the key of the entity the operation is performed on.
+	AttributeKey = attribute.Key("crud.key")
+	// AttributeState is the State of the result of the operation.
+	AttributeState = attribute.Key("crud.state")
+	// AttributePageSize is the page size requested from GetAll.
+	AttributePageSize = attribute.Key("crud.page_size")
+	// AttributePageNumber is the page number requested from GetAll.
+	AttributePageNumber = attribute.Key("crud.page_number")
+	// AttributeSortColumn is the column GetAll is requested to sort on.
+	AttributeSortColumn = attribute.Key("crud.sort_column")
+	// AttributeSortDirection is the direction GetAll is requested to sort in.
+	AttributeSortDirection = attribute.Key("crud.sort_direction")
+	// AttributeResultCount is the number of entities returned by GetAll.
+	AttributeResultCount = attribute.Key("crud.result_count")
+	// AttributeAction is the name of the custom action performed.
+	AttributeAction = attribute.Key("crud.action")
+)
+
+// The phases of handling a request, each traced by a span of its own. Decoding covers reading the request, including
+// the pipeline entities go through.
+const (
+	PhaseDecode  = "crud.decode"
+	PhaseService = "crud.service"
+	PhaseEncode  = "crud.encode"
+)
+
+// Tracing configures the OpenTelemetry tracing of CRUD requests (see WithTracing).
+type Tracing struct {
+	// Provider provides the tracer of the spans. The global provider is used if nil, see otel.SetTracerProvider.
+	Provider trace.TracerProvider
+	// Propagator extracts the context of the caller from the headers of a request. The global propagator is used if
+	// nil, see otel.SetTextMapPropagator.
+	Propagator propagation.TextMapPropagator
+}
+
+func (t *Tracing) tracer() trace.Tracer {
+	provider := t.Provider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName)
+}
+
+func (t *Tracing) propagator() propagation.TextMapPropagator {
+	if t.Propagator != nil {
+		return t.Propagator
+	}
+	return otel.GetTextMapPropagator()
+}
+
+// WithTracing wraps a CRUD handler, tracing the requests it handles with a server span. The span continues the trace of
+// the caller, as propagated through the headers of the request, unless the request is already traced, e.g. as part of a
+// batch. The CRUD handlers trace the phases of handling the request as children of the span.
+func WithTracing(tracing *Tracing, resourceName string, operation Operation, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			ctx = tracing.propagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+		}
+
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		ctx, span := tracing.tracer().Start(ctx, fmt.Sprintf("%v %v", r.Method, route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				AttributeResource.String(resourceName),
+				AttributeOperation.String(string(operation)),
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", r.URL.Path),
+			))
+		recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		defer func() {
+			span.SetAttributes(attribute.Int("http.response.status_code", recorder.statusCode))
+			if recorder.statusCode >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(recorder.statusCode))
+			}
+			span.End()
+		}()
+		handler(recorder, r.WithContext(ctx))
+	}
+}
+
+// startPhase starts the span of a phase of handling a request, as a child of the span of the request. It's a no-op if
+// the request isn't traced.
+func startPhase(r *http.Request, phase string, attributes ...attribute.KeyValue) trace.Span {
+	parent := trace.SpanFromContext(r.Context())
+	_, span := parent.TracerProvider().Tracer(tracerName).Start(r.Context(), phase, trace.WithAttributes(attributes...))
+	return span
+}
+
+// endPhase ends the span of a phase, recording the error the phase failed with, if any.
+func endPhase(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traceService calls a Service within the span of the service phase of a request, recording the State of the result.
+func traceService(r *http.Request, resourceName string, operation Operation, call func() OperationResult, attributes ...attribute.KeyValue) OperationResult {
+	span := startPhase(r, PhaseService, append([]attribute.KeyValue{
+		AttributeResource.String(resourceName),
+		AttributeOperation.String(string(operation)),
+	}, attributes...)...)
+	defer span.End()
+
+	result := call()
+	if result == nil {
+		return result
+	}
+	span.SetAttributes(AttributeState.String(result.State().String()))
+	if dataSet, ok := result.Value().(*DataSet); ok && dataSet != nil {
+		span.SetAttributes(AttributeResultCount.Int(len(dataSet.Items)))
+	}
+	if result.State() == Error {
+		if result.Error() != nil {
+			span.RecordError(result.Error())
+		}
+		span.SetStatus(codes.Error, result.State().String())
+	}
+	return result
+}
+
+// keyAttribute is the attribute of the key of an entity.
+func keyAttribute(id EntityKey) attribute.KeyValue {
+	return AttributeKey.String(fmt.Sprint(id))
+}
+
+// pagingAttributes are the attributes of the paging of a request for a list.
+func pagingAttributes(request *DataSetRequest) []attribute.KeyValue {
+	if request == nil {
+		return nil
+	}
+	return []attribute.KeyValue{
+		AttributePageSize.Int(request.PageSize),
+		AttributePageNumber.Int(request.PageNumber),
+		AttributeSortColumn.String(request.SortColumn),
+		AttributeSortDirection.String(request.SortDirection),
+	}
+}
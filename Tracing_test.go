@@ -0,0 +1,115 @@
+package crud_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTracedRouter() (*mux.Router, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	registry := newTestRegistry()
+	registry.Tracing = &crud.Tracing{
+		Provider:   sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+		Propagator: propagation.TraceContext{},
+	}
+	router := mux.NewRouter()
+	registry.Route(router)
+	return router, exporter
+}
+
+func spanNamed(spans tracetest.SpanStubs, name string) *tracetest.SpanStub {
+	for i := range spans {
+		if spans[i].Name == name {
+			return &spans[i]
+		}
+	}
+	return nil
+}
+
+func attributeOf(span *tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestRegistry_RouteWithTracing(t *testing.T) {
+	router, exporter := newTracedRouter()
+	serve(router, "POST", "/api/things", `{"name": "first"}`)
+	exporter.Reset()
+
+	r, _ := http.NewRequest("GET", "/api/things/1", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 4)
+	server := spanNamed(spans, "GET /api/things/{id}")
+	if assert.NotNil(t, server) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+		assert.Equal(t, "things", attributeOf(server, crud.AttributeResource).AsString())
+		assert.Equal(t, "getById", attributeOf(server, crud.AttributeOperation).AsString())
+		assert.Equal(t, int64(200), attributeOf(server, "http.response.status_code").AsInt64())
+	}
+	for _, phase := range []string{crud.PhaseDecode, crud.PhaseService, crud.PhaseEncode} {
+		span := spanNamed(spans, phase)
+		if assert.NotNil(t, span, phase) {
+			assert.Equal(t, server.SpanContext.SpanID(), span.Parent.SpanID(), phase)
+		}
+	}
+	service := spanNamed(spans, crud.PhaseService)
+	assert.Equal(t, "1", attributeOf(service, crud.AttributeKey).AsString())
+	assert.Equal(t, "Ok", attributeOf(service, crud.AttributeState).AsString())
+}
+
+func TestRegistry_RouteWithTracing_GetList(t *testing.T) {
+	router, exporter := newTracedRouter()
+	serve(router, "POST", "/api/things", `{"name": "first"}`)
+	exporter.Reset()
+
+	serve(router, "GET", "/api/things?pageSize=5&pageNumber=2&sortColumn=name&sortDirection=desc", "")
+	service := spanNamed(exporter.GetSpans(), crud.PhaseService)
+	if assert.NotNil(t, service) {
+		assert.Equal(t, "getList", attributeOf(service, crud.AttributeOperation).AsString())
+		assert.Equal(t, int64(5), attributeOf(service, crud.AttributePageSize).AsInt64())
+		assert.Equal(t, int64(2), attributeOf(service, crud.AttributePageNumber).AsInt64())
+		assert.Equal(t, "name", attributeOf(service, crud.AttributeSortColumn).AsString())
+		assert.Equal(t, int64(1), attributeOf(service, crud.AttributeResultCount).AsInt64())
+	}
+}
+
+func TestRegistry_RouteWithTracing_DecodeFailure(t *testing.T) {
+	router, exporter := newTracedRouter()
+
+	r, _ := http.NewRequest("POST", "/api/things", strings.NewReader(`{"name": `))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	spans := exporter.GetSpans()
+	decode := spanNamed(spans, crud.PhaseDecode)
+	if assert.NotNil(t, decode) {
+		assert.Equal(t, codes.Error, decode.Status.Code)
+	}
+	assert.Nil(t, spanNamed(spans, crud.PhaseService))
+	encode := spanNamed(spans, crud.PhaseEncode)
+	if assert.NotNil(t, encode) {
+		assert.Equal(t, "ValidationFailed", attributeOf(encode, crud.AttributeState).AsString())
+	}
+}
@@ -22,6 +22,26 @@ func CreatedResult() OperationResult {
 	return result
 }
 
+// CreatedWithIDResult constructs an operation result for State 'Created', carrying the ID of the new entity.
+func CreatedWithIDResult(id EntityKey) OperationResult {
+	result := &crudOperationResult{
+		state: Created,
+		error: nil,
+		value: id,
+	}
+	return result
+}
+
+// AcceptedResult constructs an operation result for State 'Accepted', carrying the operation that tracks the work.
+func AcceptedResult(operation *AsyncOperation) OperationResult {
+	result := &crudOperationResult{
+		state: Accepted,
+		error: nil,
+		value: operation,
+	}
+	return result
+}
+
 // ErrorResult constructs an operation result for State 'Error'.
 func ErrorResult(err error) OperationResult {
 	result := &crudOperationResult{
@@ -111,3 +131,16 @@ func ConstrainFilterColumns(r *DataSetRequest, allowedColumns ...string) {
 		}
 	}
 }
+
+func isSuccess(result OperationResult) bool {
+	return result.State() == Ok || result.State() == Created
+}
+
+// readBack gets the current state of an entity from the Service, for decorators that need more than what the caller
+// passed in (e.g. the ID assigned by Add). Falls back to the given value if the entity can't be read.
+func readBack(svc Service, id EntityKey, fallback interface{}) interface{} {
+	if stored := svc.GetByID(id); stored.State() == Ok && stored.Value() != nil {
+		return stored.Value()
+	}
+	return fallback
+}
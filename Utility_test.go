@@ -36,6 +36,15 @@ func TestCreatedResult(t *testing.T) {
 	assert.Nil(t, result.Value())
 }
 
+func TestCreatedWithIDResult(t *testing.T) {
+	result := crud.CreatedWithIDResult(42)
+	assert.NotNil(t, result)
+
+	assert.Equal(t, crud.Created, result.State())
+	assert.Nil(t, result.Error())
+	assert.Equal(t, 42, result.Value())
+}
+
 func TestErrorResultNotNil(t *testing.T) {
 	value := errors.New("Sample error")
 	result := crud.ErrorResult(value)
@@ -84,6 +93,11 @@ func TestNotSupportedByResourceResult(t *testing.T) {
 	assert.Nil(t, result.Error())
 }
 
+func TestStateString(t *testing.T) {
+	assert.Equal(t, "NotSupportedByResource", crud.NotSupportedByResource.String())
+	assert.Equal(t, "State(42)", crud.State(42).String())
+}
+
 func TestConstrainPagingRequestMin(t *testing.T) {
 	ds := &crud.DataSetRequest{
 		PageSize: 0,
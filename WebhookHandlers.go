@@ -0,0 +1,28 @@
+package crud
+
+import (
+	"fmt"
+	"net/http"
+
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+	"github.com/gorilla/mux"
+)
+
+// CreateCrudHandlerGetWebhookDeliveries is used to list the recorded delivery attempts of a webhook subscription. Route it as
+// e.g. /{resource}/{id}/deliveries, next to the regular CRUD handlers for the subscription resource.
+var CreateCrudHandlerGetWebhookDeliveries = func(ctx servicefoundation.AppContext, dispatcher *WebhookDispatcher, resourceName string, recoverFunc RecoverFunc) http.HandlerFunc {
+	logger := ctx.Logger()
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer recoverFunc("crudHandler", ctx, w, r)
+
+		vars := mux.Vars(r)
+		idVar := vars["id"]
+
+		logger.Debug("CreateCrudHandlerGetWebhookDeliveries", fmt.Sprintf("Interpreted as GetWebhookDeliveries command. ID: %v", idVar))
+		if dispatcher.subscriptions.GetByID(idVar).State() != Ok {
+			WriteOperationResult(w, r, NotFoundResult())
+			return
+		}
+		WriteOperationResult(w, r, OkResult(dispatcher.Deliveries(idVar)))
+	}
+}
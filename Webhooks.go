@@ -0,0 +1,562 @@
+package crud
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// WebhookSignatureHeader carries the HMAC-SHA256 signature of the payload, formatted as "sha256=<hex>".
+	WebhookSignatureHeader = "X-Crud-Signature"
+	// WebhookEventHeader carries the change type of the delivered event.
+	WebhookEventHeader = "X-Crud-Event"
+	// WebhookDeliveryHeader carries the ID of the delivered event. Retries of the same event use the same ID.
+	WebhookDeliveryHeader = "X-Crud-Delivery"
+)
+
+// WebhookSubscription subscribes a URL to the change events of a resource
+type WebhookSubscription struct {
+	// ID is assigned when the subscription is added.
+	ID string `json:"id"`
+	// URL is the absolute http(s) URL the events are POSTed to.
+	URL string `json:"url"`
+	// Resource is the name of the resource to receive events for.
+	Resource string `json:"resource"`
+	// Types restricts the change types to deliver. Empty means all of them.
+	Types []ChangeType `json:"types"`
+	// Secret is the key used to sign payloads. It is write-only: it's never returned by the subscription service.
+	Secret string `json:"secret,omitempty"`
+	// Active can be used to pause deliveries without removing the subscription.
+	Active bool `json:"active"`
+}
+
+// NewWebhookSubscription creates an active subscription. It's meant as the createFunc of the create and update
+// handlers of the subscription resource.
+func NewWebhookSubscription() Entity {
+	return &WebhookSubscription{Active: true}
+}
+
+// Validate checks that the subscription can be delivered to
+func (s *WebhookSubscription) Validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if s.Resource == "" {
+		return errors.New("resource is required")
+	}
+	for _, t := range s.Types {
+		if t != ChangeCreated && t != ChangeUpdated && t != ChangeDeleted {
+			return fmt.Errorf("unknown change type '%v'", t)
+		}
+	}
+	return nil
+}
+
+// Format normalizes the URL, resource name and change types
+func (s *WebhookSubscription) Format(isNewEntity bool) {
+	s.URL = strings.TrimSpace(s.URL)
+	s.Resource = strings.TrimSpace(s.Resource)
+	for i, t := range s.Types {
+		s.Types[i] = ChangeType(strings.ToLower(strings.TrimSpace(string(t))))
+	}
+}
+
+func (s *WebhookSubscription) accepts(event ChangeEvent) bool {
+	if !s.Active || s.Resource != event.Resource {
+		return false
+	}
+	if len(s.Types) == 0 {
+		return true
+	}
+	for _, t := range s.Types {
+		if t == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookSubscriptionService is an in-memory Service for webhook subscriptions, so they can be managed through the
+// regular CRUD handlers. URLs of loopback, private and link-local addresses are rejected, so subscriptions can't be used
+// to reach internal services, unless AllowPrivateAddresses is set.
+type WebhookSubscriptionService struct {
+	// AllowPrivateAddresses allows URLs of loopback, private and link-local addresses, e.g. for testing purposes.
+	AllowPrivateAddresses bool
+
+	mutex         sync.RWMutex
+	lastID        int
+	subscriptions map[string]*WebhookSubscription
+	deleted       []func(id string)
+}
+
+// NewWebhookSubscriptionService creates an empty subscription service.
+func NewWebhookSubscriptionService() *WebhookSubscriptionService {
+	return &WebhookSubscriptionService{
+		subscriptions: make(map[string]*WebhookSubscription),
+	}
+}
+
+// EntityPipeline opts out of the handler pipeline, since Add and Update format and validate subscriptions themselves.
+func (s *WebhookSubscriptionService) EntityPipeline() *EntityPipeline {
+	return NoEntityPipeline
+}
+
+// GetAll returns the subscriptions, without their secrets.
+func (s *WebhookSubscriptionService) GetAll(request *DataSetRequest) OperationResult {
+	s.mutex.RLock()
+	items := make([]interface{}, 0, len(s.subscriptions))
+	for _, subscription := range s.subscriptions {
+		items = append(items, withoutSecret(subscription))
+	}
+	s.mutex.RUnlock()
+
+	return OkResult(applyDataSetRequest(items, request))
+}
+
+// GetByID returns the subscription, without its secret.
+func (s *WebhookSubscriptionService) GetByID(id EntityKey) OperationResult {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	subscription, ok := s.subscriptions[fmt.Sprint(id)]
+	if !ok {
+		return NotFoundResult()
+	}
+	return OkResult(withoutSecret(subscription))
+}
+
+// Add adds the subscription. The returned value is the ID of the new subscription.
+func (s *WebhookSubscriptionService) Add(entity Entity) OperationResult {
+	subscription, ok := entity.(*WebhookSubscription)
+	if !ok {
+		return ValidationFailedResult(errors.New("Entity is not a webhook subscription"))
+	}
+	subscription.Format(true)
+	if err := s.validate(subscription); err != nil {
+		return ValidationFailedResult(err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastID++
+	stored := *subscription
+	stored.ID = strconv.Itoa(s.lastID)
+	s.subscriptions[stored.ID] = &stored
+	return CreatedWithIDResult(stored.ID)
+}
+
+// Update replaces the subscription. The secret is kept if the update doesn't specify a new one.
+func (s *WebhookSubscriptionService) Update(id EntityKey, entity Entity) OperationResult {
+	subscription, ok := entity.(*WebhookSubscription)
+	if !ok {
+		return ValidationFailedResult(errors.New("Entity is not a webhook subscription"))
+	}
+	subscription.Format(false)
+	if err := s.validate(subscription); err != nil {
+		return ValidationFailedResult(err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := fmt.Sprint(id)
+	existing, ok := s.subscriptions[key]
+	if !ok {
+		return NotFoundResult()
+	}
+	stored := *subscription
+	stored.ID = key
+	if stored.Secret == "" {
+		stored.Secret = existing.Secret
+	}
+	s.subscriptions[key] = &stored
+	return OkResult(withoutSecret(&stored))
+}
+
+// Delete removes the subscription, and the deliveries recorded for it by dispatchers.
+func (s *WebhookSubscriptionService) Delete(id EntityKey) OperationResult {
+	s.mutex.Lock()
+	key := fmt.Sprint(id)
+	if _, ok := s.subscriptions[key]; !ok {
+		s.mutex.Unlock()
+		return NotFoundResult()
+	}
+	delete(s.subscriptions, key)
+	deleted := s.deleted
+	s.mutex.Unlock()
+
+	for _, f := range deleted {
+		f(key)
+	}
+	return OkResult(nil)
+}
+
+// validate checks the subscription, and that its URL doesn't refer to a private address, unless allowed.
+func (s *WebhookSubscriptionService) validate(subscription *WebhookSubscription) error {
+	if err := subscription.Validate(); err != nil {
+		return err
+	}
+	if s.AllowPrivateAddresses {
+		return nil
+	}
+	u, _ := url.Parse(subscription.URL)
+	host := u.Hostname()
+	if ip := net.ParseIP(host); (ip != nil && isPrivateIP(ip)) || strings.EqualFold(host, "localhost") {
+		return errors.New("url must not refer to a private address")
+	}
+	return nil
+}
+
+// exists tells whether the subscription with the given ID exists.
+func (s *WebhookSubscriptionService) exists(id string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, ok := s.subscriptions[id]
+	return ok
+}
+
+// onDelete registers a function called with the ID of every deleted subscription.
+func (s *WebhookSubscriptionService) onDelete(f func(id string)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.deleted = append(s.deleted, f)
+}
+
+// matching returns copies of the subscriptions, secrets included, that want to receive the event.
+func (s *WebhookSubscriptionService) matching(event ChangeEvent) []WebhookSubscription {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := make([]WebhookSubscription, 0)
+	for _, subscription := range s.subscriptions {
+		if subscription.accepts(event) {
+			result = append(result, *subscription)
+		}
+	}
+	return result
+}
+
+func withoutSecret(subscription *WebhookSubscription) *WebhookSubscription {
+	result := *subscription
+	result.Secret = ""
+	return &result
+}
+
+// WebhookDelivery records a single attempt to deliver an event to a subscription
+type WebhookDelivery struct {
+	SubscriptionID string    `json:"subscriptionId"`
+	EventID        uint64    `json:"eventId"`
+	Attempt        int       `json:"attempt"`
+	Timestamp      time.Time `json:"timestamp"`
+	StatusCode     int       `json:"statusCode"`
+	Error          string    `json:"error,omitempty"`
+	Success        bool      `json:"success"`
+}
+
+// SignWebhookPayload computes the value of the signature header for a payload.
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature is meant for receivers of webhooks, to check that a payload was signed with the shared secret.
+func VerifyWebhookSignature(secret string, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhookPayload(secret, payload)), []byte(signature))
+}
+
+// WebhookDispatcher delivers the events published on a bus to the matching subscriptions, using a fixed number of
+// workers. Failed deliveries are retried with exponential backoff; every attempt is recorded, until the subscription is
+// deleted.
+type WebhookDispatcher struct {
+	// Client is used to deliver the payloads. The default client refuses to connect to loopback, private and link-local
+	// addresses, unless AllowPrivateAddresses is set; replacing it drops that protection.
+	Client *http.Client
+	// AllowPrivateAddresses allows the default client to connect to loopback, private and link-local addresses.
+	AllowPrivateAddresses bool
+	// Workers is the number of deliveries in progress at the same time, including the ones waiting to be retried. Events
+	// are queued while all workers are busy.
+	Workers int
+	// MaxAttempts is the maximum number of delivery attempts per event and subscription.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. It doubles with every retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries.
+	MaxBackoff time.Duration
+	// MaxRecordedDeliveries is the number of delivery attempts kept per subscription.
+	MaxRecordedDeliveries int
+	// Now returns the current time. Replaceable for testing purposes; defaults to time.Now.
+	Now func() time.Time
+
+	bus           *ChangeEventBus
+	subscriptions *WebhookSubscriptionService
+
+	mutex      sync.Mutex
+	deliveries map[string][]WebhookDelivery
+	stop       chan struct{}
+	running    sync.WaitGroup
+}
+
+// webhookJob is a delivery of an event to a subscription, waiting for a worker
+type webhookJob struct {
+	subscription WebhookSubscription
+	event        ChangeEvent
+}
+
+// NewWebhookDispatcher creates a dispatcher with default settings. Call Start to begin delivering.
+func NewWebhookDispatcher(bus *ChangeEventBus, subscriptions *WebhookSubscriptionService) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		Workers:               10,
+		MaxAttempts:           5,
+		InitialBackoff:        time.Second,
+		MaxBackoff:            5 * time.Minute,
+		MaxRecordedDeliveries: 100,
+		Now:                   time.Now,
+		bus:                   bus,
+		subscriptions:         subscriptions,
+		deliveries:            make(map[string][]WebhookDelivery),
+	}
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		// Checked on the resolved address, so host names resolving to private addresses are refused as well
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip != nil && isPrivateIP(ip) && !d.AllowPrivateAddresses {
+				return errors.New("Refusing to deliver to private address " + host)
+			}
+			return nil
+		},
+	}
+	d.Client = &http.Client{Timeout: 10 * time.Second, Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, DialContext: dialer.DialContext}}
+	subscriptions.onDelete(d.forget)
+	return d
+}
+
+// Start subscribes to the bus, and delivers events until Stop is called.
+func (d *WebhookDispatcher) Start() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.stop != nil {
+		return
+	}
+	d.stop = make(chan struct{})
+
+	workers := d.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan webhookJob, workers)
+	for i := 0; i < workers; i++ {
+		d.running.Add(1)
+		go d.work(jobs, d.stop)
+	}
+
+	events, unsubscribe := d.bus.Subscribe(0, nil)
+	d.running.Add(1)
+	go d.consume(events, unsubscribe, jobs, d.stop)
+}
+
+// Stop stops delivering, abandoning pending retries, and waits for deliveries in progress to finish.
+func (d *WebhookDispatcher) Stop() {
+	d.mutex.Lock()
+	stop := d.stop
+	d.stop = nil
+	d.mutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+		d.running.Wait()
+	}
+}
+
+// Deliveries returns the recorded delivery attempts for a subscription, oldest first.
+func (d *WebhookDispatcher) Deliveries(subscriptionID EntityKey) []WebhookDelivery {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	deliveries := d.deliveries[fmt.Sprint(subscriptionID)]
+	result := make([]WebhookDelivery, len(deliveries))
+	copy(result, deliveries)
+	return result
+}
+
+// forget removes the recorded deliveries of a deleted subscription.
+func (d *WebhookDispatcher) forget(subscriptionID string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	delete(d.deliveries, subscriptionID)
+}
+
+// consume queues the deliveries of the events for the workers. While all workers are busy, events are left on the bus,
+// which drops the subscription if they pile up; it's then resumed.
+func (d *WebhookDispatcher) consume(events <-chan ChangeEvent, unsubscribe func(), jobs chan<- webhookJob, stop chan struct{}) {
+	defer d.running.Done()
+
+	var lastID uint64
+	for {
+		select {
+		case <-stop:
+			unsubscribe()
+			return
+		case event, ok := <-events:
+			if !ok {
+				// Dropped by the bus for being too slow; resume after the last event we saw
+				events, unsubscribe = d.bus.Subscribe(lastID, nil)
+				continue
+			}
+			lastID = event.ID
+			if event.Type == ChangeReset {
+				// Events were missed while resubscribing; they can't be delivered anymore
+				continue
+			}
+			for _, subscription := range d.subscriptions.matching(event) {
+				select {
+				case <-stop:
+					unsubscribe()
+					return
+				case jobs <- webhookJob{subscription: subscription, event: event}:
+				}
+			}
+		}
+	}
+}
+
+// work delivers the queued events until stopped.
+func (d *WebhookDispatcher) work(jobs <-chan webhookJob, stop chan struct{}) {
+	defer d.running.Done()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case job := <-jobs:
+			d.deliver(job.subscription, job.event, stop)
+		}
+	}
+}
+
+func (d *WebhookDispatcher) deliver(subscription WebhookSubscription, event ChangeEvent, stop chan struct{}) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		d.record(WebhookDelivery{SubscriptionID: subscription.ID, EventID: event.ID, Attempt: 1, Error: err.Error()})
+		return
+	}
+
+	backoff := d.InitialBackoff
+	for attempt := 1; attempt <= d.MaxAttempts; attempt++ {
+		if !d.subscriptions.exists(subscription.ID) {
+			return
+		}
+		statusCode, err := d.post(subscription, event, payload)
+		delivery := WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        event.ID,
+			Attempt:        attempt,
+			StatusCode:     statusCode,
+			Success:        err == nil && statusCode >= 200 && statusCode < 300,
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		d.record(delivery)
+
+		if delivery.Success || (err == nil && !isRetryableStatus(statusCode)) || attempt == d.MaxAttempts {
+			return
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > d.MaxBackoff {
+			backoff = d.MaxBackoff
+		}
+	}
+}
+
+func (d *WebhookDispatcher) post(subscription WebhookSubscription, event ChangeEvent, payload []byte) (int, error) {
+	req, err := http.NewRequest("POST", subscription.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, string(event.Type))
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatUint(event.ID, 10))
+	if subscription.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(subscription.Secret, payload))
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// record records a delivery attempt, unless the subscription was deleted in the meantime.
+func (d *WebhookDispatcher) record(delivery WebhookDelivery) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if !d.subscriptions.exists(delivery.SubscriptionID) {
+		return
+	}
+
+	delivery.Timestamp = d.Now()
+	deliveries := append(d.deliveries[delivery.SubscriptionID], delivery)
+	if d.MaxRecordedDeliveries > 0 && len(deliveries) > d.MaxRecordedDeliveries {
+		deliveries = deliveries[len(deliveries)-d.MaxRecordedDeliveries:]
+	}
+	d.deliveries[delivery.SubscriptionID] = deliveries
+}
+
+// isRetryableStatus tells whether a failed delivery might succeed later. Other client errors won't.
+func isRetryableStatus(statusCode int) bool {
+	return statusCode >= 500 || statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests
+}
+
+// privateNetworks are the loopback, private, shared and link-local networks webhooks aren't delivered to by default.
+var privateNetworks = parseNetworks("0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12",
+	"192.168.0.0/16", "::/128", "::1/128", "fc00::/7", "fe80::/10")
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, networks[i], _ = net.ParseCIDR(cidr)
+	}
+	return networks
+}
+
+// isPrivateIP tells whether the address is in one of the private networks.
+func isPrivateIP(ip net.IP) bool {
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
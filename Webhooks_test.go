@@ -0,0 +1,242 @@
+package crud_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// waitFor polls the condition until it holds, failing the test after a second
+func waitFor(t *testing.T, condition func() bool) {
+	for i := 0; i < 100; i++ {
+		if condition() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("condition not met in time")
+}
+
+func newTestDispatcher(bus *crud.ChangeEventBus, subscriptions *crud.WebhookSubscriptionService) *crud.WebhookDispatcher {
+	dispatcher := crud.NewWebhookDispatcher(bus, subscriptions)
+	dispatcher.InitialBackoff = time.Millisecond
+	dispatcher.MaxBackoff = 2 * time.Millisecond
+	dispatcher.MaxAttempts = 3
+	dispatcher.AllowPrivateAddresses = true
+	return dispatcher
+}
+
+// newTestSubscriptions creates a subscription service allowing the addresses of test servers
+func newTestSubscriptions() *crud.WebhookSubscriptionService {
+	subscriptions := crud.NewWebhookSubscriptionService()
+	subscriptions.AllowPrivateAddresses = true
+	return subscriptions
+}
+
+func TestWebhookSubscriptionService_ThroughCrudHandlers(t *testing.T) {
+	svc := crud.NewWebhookSubscriptionService()
+	ctx := newTestContext()
+	router := mux.NewRouter()
+	router.HandleFunc("/webhooks", crud.CreateCrudHandlerCreateEntity(ctx, svc, "webhooks", noRecovery, crud.NewWebhookSubscription)).Methods("POST")
+	router.HandleFunc("/webhooks/{id}", crud.CreateCrudHandlerGetByID(ctx, svc, "webhooks", noRecovery)).Methods("GET")
+
+	r, _ := http.NewRequest("POST", "/webhooks", strings.NewReader(`{"url":" http://example.com/hook ","resource":"things","secret":"s3cr3t"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `"1"`, strings.TrimSpace(w.Body.String()))
+
+	r, _ = http.NewRequest("GET", "/webhooks/1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"url":"http://example.com/hook"`)
+	assert.Contains(t, w.Body.String(), `"active":true`)
+	assert.NotContains(t, w.Body.String(), "s3cr3t")
+}
+
+func TestWebhookSubscriptionService_RejectsInvalidSubscriptions(t *testing.T) {
+	svc := crud.NewWebhookSubscriptionService()
+
+	result := svc.Add(&crud.WebhookSubscription{URL: "ftp://example.com", Resource: "things"})
+	assert.Equal(t, crud.ValidationFailed, result.State())
+
+	result = svc.Add(&crud.WebhookSubscription{URL: "http://example.com", Resource: "things", Types: []crud.ChangeType{"moved"}})
+	assert.Equal(t, crud.ValidationFailed, result.State())
+}
+
+func TestWebhookSubscriptionService_RejectsPrivateAddresses(t *testing.T) {
+	svc := crud.NewWebhookSubscriptionService()
+
+	for _, url := range []string{"http://localhost/hook", "http://127.0.0.1:8080/hook", "http://10.1.2.3/hook", "http://169.254.169.254/latest",
+		"http://[::1]/hook", "http://[::ffff:192.168.0.1]/hook"} {
+		result := svc.Add(&crud.WebhookSubscription{URL: url, Resource: "things"})
+		assert.Equal(t, crud.ValidationFailed, result.State(), url)
+	}
+	assert.Equal(t, crud.Created, svc.Add(&crud.WebhookSubscription{URL: "http://93.184.216.34/hook", Resource: "things"}).State())
+	assert.Equal(t, crud.ValidationFailed, svc.Update("1", &crud.WebhookSubscription{URL: "http://192.168.1.1/hook", Resource: "things"}).State())
+
+	svc.AllowPrivateAddresses = true
+	assert.Equal(t, crud.Created, svc.Add(&crud.WebhookSubscription{URL: "http://127.0.0.1:8080/hook", Resource: "things"}).State())
+}
+
+func TestWebhookDispatcher_RefusesPrivateAddresses(t *testing.T) {
+	calls := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer receiver.Close()
+
+	bus := crud.NewChangeEventBus(10)
+	// The subscription service may allow what the dispatcher doesn't, e.g. for host names resolving to private addresses
+	subscriptions := newTestSubscriptions()
+	subscriptions.Add(&crud.WebhookSubscription{URL: receiver.URL, Resource: "things", Active: true})
+	dispatcher := newTestDispatcher(bus, subscriptions)
+	dispatcher.AllowPrivateAddresses = false
+	dispatcher.MaxAttempts = 1
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	bus.Publish(crud.ChangeEvent{Type: crud.ChangeCreated, Resource: "things", Key: 1})
+
+	waitFor(t, func() bool { return len(dispatcher.Deliveries("1")) == 1 })
+	assert.False(t, dispatcher.Deliveries("1")[0].Success)
+	assert.Contains(t, dispatcher.Deliveries("1")[0].Error, "private address")
+	assert.Equal(t, 0, calls)
+}
+
+func TestWebhookDispatcher_BoundsConcurrentDeliveries(t *testing.T) {
+	var mutex sync.Mutex
+	concurrent, maxConcurrent := 0, 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		concurrent++
+		if concurrent > maxConcurrent {
+			maxConcurrent = concurrent
+		}
+		mutex.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mutex.Lock()
+		concurrent--
+		mutex.Unlock()
+	}))
+	defer receiver.Close()
+
+	bus := crud.NewChangeEventBus(100)
+	subscriptions := newTestSubscriptions()
+	subscriptions.Add(&crud.WebhookSubscription{URL: receiver.URL, Resource: "things", Active: true})
+	dispatcher := newTestDispatcher(bus, subscriptions)
+	dispatcher.Workers = 2
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	for i := 1; i <= 10; i++ {
+		bus.Publish(crud.ChangeEvent{Type: crud.ChangeCreated, Resource: "things", Key: i})
+	}
+
+	waitFor(t, func() bool { return len(dispatcher.Deliveries("1")) == 10 })
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, 2, maxConcurrent)
+}
+
+func TestWebhookDispatcher_ForgetsDeliveriesOfDeletedSubscriptions(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+
+	bus := crud.NewChangeEventBus(10)
+	subscriptions := newTestSubscriptions()
+	subscriptions.Add(&crud.WebhookSubscription{URL: receiver.URL, Resource: "things", Active: true})
+	dispatcher := newTestDispatcher(bus, subscriptions)
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	bus.Publish(crud.ChangeEvent{Type: crud.ChangeCreated, Resource: "things", Key: 1})
+	waitFor(t, func() bool { return len(dispatcher.Deliveries("1")) == 1 })
+
+	assert.Equal(t, crud.Ok, subscriptions.Delete("1").State())
+	assert.Equal(t, 0, len(dispatcher.Deliveries("1")))
+}
+
+func TestWebhookDispatcher_SignsAndRetries(t *testing.T) {
+	var mutex sync.Mutex
+	calls := 0
+	var lastBody []byte
+	var lastSignature string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls++
+		lastBody, _ = ioutil.ReadAll(r.Body)
+		lastSignature = r.Header.Get(crud.WebhookSignatureHeader)
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	bus := crud.NewChangeEventBus(10)
+	subscriptions := newTestSubscriptions()
+	subscriptions.Add(&crud.WebhookSubscription{URL: receiver.URL, Resource: "things", Secret: "s3cr3t", Active: true})
+	subscriptions.Add(&crud.WebhookSubscription{URL: receiver.URL, Resource: "others", Active: true})
+	dispatcher := newTestDispatcher(bus, subscriptions)
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	bus.Publish(crud.ChangeEvent{Type: crud.ChangeCreated, Resource: "things", Key: 1})
+
+	waitFor(t, func() bool { return len(dispatcher.Deliveries("1")) == 2 })
+	deliveries := dispatcher.Deliveries("1")
+	assert.False(t, deliveries[0].Success)
+	assert.Equal(t, http.StatusServiceUnavailable, deliveries[0].StatusCode)
+	assert.True(t, deliveries[1].Success)
+	assert.Equal(t, 2, deliveries[1].Attempt)
+	assert.Equal(t, 0, len(dispatcher.Deliveries("2")))
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.True(t, crud.VerifyWebhookSignature("s3cr3t", lastBody, lastSignature))
+	assert.False(t, crud.VerifyWebhookSignature("other", lastBody, lastSignature))
+}
+
+func TestWebhookDispatcher_DoesNotRetryClientErrors(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer receiver.Close()
+
+	bus := crud.NewChangeEventBus(10)
+	subscriptions := newTestSubscriptions()
+	subscriptions.Add(&crud.WebhookSubscription{URL: receiver.URL, Resource: "things", Active: true})
+	dispatcher := newTestDispatcher(bus, subscriptions)
+	dispatcher.Start()
+
+	bus.Publish(crud.ChangeEvent{Type: crud.ChangeDeleted, Resource: "things", Key: 1})
+
+	waitFor(t, func() bool { return len(dispatcher.Deliveries("1")) == 1 })
+	dispatcher.Stop()
+	assert.Equal(t, 1, len(dispatcher.Deliveries("1")))
+	assert.Equal(t, http.StatusGone, dispatcher.Deliveries("1")[0].StatusCode)
+}
+
+func TestCreateCrudHandlerGetWebhookDeliveries_UnknownSubscription(t *testing.T) {
+	dispatcher := crud.NewWebhookDispatcher(crud.NewChangeEventBus(10), crud.NewWebhookSubscriptionService())
+	router := mux.NewRouter()
+	router.HandleFunc("/webhooks/{id}/deliveries", crud.CreateCrudHandlerGetWebhookDeliveries(newTestContext(), dispatcher, "webhooks", noRecovery))
+
+	r, _ := http.NewRequest("GET", "/webhooks/7/deliveries", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
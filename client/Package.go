@@ -0,0 +1,2 @@
+// Package client provides access to remote CRUD resources, by implementing crud.Service over HTTP according to the CRUD protocol.
+package client
@@ -0,0 +1,306 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	crud "github.com/Travix-International/crud-go"
+)
+
+// RemoteService implements crud.Service for a resource exposed by the CRUD handlers of another service. HTTP status codes
+// are mapped back to the State values they were derived from, so a remote resource can be used anywhere a local Service is.
+type RemoteService struct {
+	// BaseURL is the URL of the resource, e.g. http://host/api/bookings. Entity URLs are formed by appending the ID.
+	BaseURL string
+	// Client is used to perform the requests.
+	Client *http.Client
+	// Header is added to every request, e.g. for authentication.
+	Header http.Header
+	// UpdateMethod is the HTTP method used for updates. Defaults to PUT.
+	UpdateMethod string
+
+	createFunc func() crud.Entity
+}
+
+// NewRemoteService creates a RemoteService for the resource at the given URL. Entities are decoded into values created by
+// createFunc; if it's nil, they're decoded as generic JSON maps.
+func NewRemoteService(baseURL string, createFunc func() crud.Entity) *RemoteService {
+	return &RemoteService{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		Client:       http.DefaultClient,
+		Header:       make(http.Header),
+		UpdateMethod: "PUT",
+		createFunc:   createFunc,
+	}
+}
+
+// GetAll is used to get a list of entities, passing paging, filtering and sorting as URI parameters.
+func (s *RemoteService) GetAll(request *crud.DataSetRequest) crud.OperationResult {
+	query, err := EncodeDataSetRequest(request)
+	if err != nil {
+		return crud.ValidationFailedResult(err)
+	}
+
+	resp, err := s.do("GET", s.BaseURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return crud.ErrorResult(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resultFromStatus(resp)
+	}
+
+	var envelope struct {
+		Items      []json.RawMessage `json:"items"`
+		PagingInfo crud.PagingInfo   `json:"pagingInfo"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return crud.ErrorResult(errors.New("Failed to parse data set: " + err.Error()))
+	}
+
+	dataSet := &crud.DataSet{
+		Items:      make([]interface{}, 0, len(envelope.Items)),
+		PagingInfo: envelope.PagingInfo,
+	}
+	for _, raw := range envelope.Items {
+		item, err := s.decodeEntity(raw)
+		if err != nil {
+			return crud.ErrorResult(errors.New("Failed to parse entity: " + err.Error()))
+		}
+		dataSet.Items = append(dataSet.Items, item)
+	}
+	return crud.OkResult(dataSet)
+}
+
+// GetByID returns the entity with the specified ID.
+func (s *RemoteService) GetByID(id crud.EntityKey) crud.OperationResult {
+	resp, err := s.do("GET", s.entityURL(id), nil)
+	if err != nil {
+		return crud.ErrorResult(err)
+	}
+	defer resp.Body.Close()
+
+	return s.entityResult(resp)
+}
+
+// Add will add the given entity. If the remote resource replies with the ID of the new entity, it's the returned value.
+func (s *RemoteService) Add(entity crud.Entity) crud.OperationResult {
+	resp, err := s.doWithEntity("POST", s.BaseURL, entity)
+	if err != nil {
+		return crud.ErrorResult(err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil || len(bytes.TrimSpace(body)) == 0 {
+			return crud.CreatedResult()
+		}
+		// Numeric IDs are kept as json.Number, so they're not turned into floats
+		var id crud.EntityKey
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&id); err != nil {
+			return crud.CreatedResult()
+		}
+		return crud.CreatedWithIDResult(id)
+	case http.StatusOK:
+		return s.entityResult(resp)
+	}
+	return resultFromStatus(resp)
+}
+
+// Update will update an existing entity. The returned value is the new state of the entity.
+func (s *RemoteService) Update(id crud.EntityKey, entity crud.Entity) crud.OperationResult {
+	resp, err := s.doWithEntity(s.UpdateMethod, s.entityURL(id), entity)
+	if err != nil {
+		return crud.ErrorResult(err)
+	}
+	defer resp.Body.Close()
+
+	return s.entityResult(resp)
+}
+
+// Delete will delete the entity with the specified ID.
+func (s *RemoteService) Delete(id crud.EntityKey) crud.OperationResult {
+	resp, err := s.do("DELETE", s.entityURL(id), nil)
+	if err != nil {
+		return crud.ErrorResult(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNoContent {
+		return crud.OkResult(nil)
+	}
+	return resultFromStatus(resp)
+}
+
+// WaitForOperation polls the status of an asynchronous operation, as returned in the value of an Accepted result, until
+// it completes or the timeout expires. The last known status is returned; its Result holds the final result, if completed.
+func (s *RemoteService) WaitForOperation(operation *crud.AsyncOperation, timeout time.Duration) (*crud.AsyncOperation, error) {
+	base, err := url.Parse(s.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	location, err := base.Parse(operation.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		wait := time.Until(deadline) / time.Second
+		status := location.String() + "?wait=" + strconv.Itoa(int(wait))
+		resp, err := s.do("GET", status, nil)
+		if err != nil {
+			return operation, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := readErrorDetails(resp)
+			resp.Body.Close()
+			return operation, err
+		}
+		var current crud.AsyncOperation
+		err = json.NewDecoder(resp.Body).Decode(&current)
+		resp.Body.Close()
+		if err != nil {
+			return operation, errors.New("Failed to parse operation: " + err.Error())
+		}
+
+		operation = &current
+		if operation.Status == crud.AsyncOperationCompleted || !time.Now().Before(deadline) || wait == 0 {
+			return operation, nil
+		}
+	}
+}
+
+// EncodeDataSetRequest turns a DataSetRequest into the URI parameters understood by crud.ExtractDataSetRequestFromURI.
+func EncodeDataSetRequest(request *crud.DataSetRequest) (url.Values, error) {
+	query := make(url.Values)
+	if request == nil {
+		return query, nil
+	}
+	if request.PageSize > 0 {
+		query.Set("pageSize", strconv.Itoa(request.PageSize))
+	}
+	if request.PageNumber > 0 {
+		query.Set("pageNumber", strconv.Itoa(request.PageNumber))
+	}
+	if request.SortColumn != "" {
+		query.Set("sortColumn", request.SortColumn)
+	}
+	if request.SortDirection != "" {
+		query.Set("sortDirection", request.SortDirection)
+	}
+	if len(request.Filters) > 0 {
+		filters, err := json.Marshal(request.Filters)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("filters", string(filters))
+	}
+	return query, nil
+}
+
+func (s *RemoteService) entityURL(id crud.EntityKey) string {
+	return s.BaseURL + "/" + url.PathEscape(fmt.Sprint(id))
+}
+
+func (s *RemoteService) doWithEntity(method, target string, entity crud.Entity) (*http.Response, error) {
+	body, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	return s.do(method, target, bytes.NewReader(body))
+}
+
+func (s *RemoteService) do(method, target string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return s.Client.Do(req)
+}
+
+// entityResult handles responses that carry an entity when successful.
+func (s *RemoteService) entityResult(resp *http.Response) crud.OperationResult {
+	if resp.StatusCode != http.StatusOK {
+		return resultFromStatus(resp)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return crud.ErrorResult(err)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return crud.OkResult(nil)
+	}
+	entity, err := s.decodeEntity(body)
+	if err != nil {
+		return crud.ErrorResult(errors.New("Failed to parse entity: " + err.Error()))
+	}
+	return crud.OkResult(entity)
+}
+
+func (s *RemoteService) decodeEntity(data []byte) (interface{}, error) {
+	if s.createFunc == nil {
+		var entity map[string]interface{}
+		err := json.Unmarshal(data, &entity)
+		return entity, err
+	}
+	entity := s.createFunc()
+	err := json.Unmarshal(data, entity)
+	return entity, err
+}
+
+// resultFromStatus maps the status code of an unsuccessful response back to the State it was derived from.
+func resultFromStatus(resp *http.Response) crud.OperationResult {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return crud.OkResult(nil)
+	case http.StatusCreated:
+		return crud.CreatedResult()
+	case http.StatusBadRequest:
+		return crud.ValidationFailedResult(readErrorDetails(resp))
+	case http.StatusNotFound:
+		return crud.NotFoundResult()
+	case http.StatusConflict:
+		return crud.ConflictResult(readErrorDetails(resp))
+	case http.StatusMethodNotAllowed:
+		return crud.NotSupportedByResourceResult()
+	case http.StatusAccepted:
+		var operation crud.AsyncOperation
+		if err := json.NewDecoder(resp.Body).Decode(&operation); err != nil {
+			return crud.ErrorResult(fmt.Errorf("Failed to parse operation from HTTP body: %v", err))
+		}
+		return crud.AcceptedResult(&operation)
+	}
+	return crud.ErrorResult(readErrorDetails(resp))
+}
+
+func readErrorDetails(resp *http.Response) error {
+	var details crud.ErrorDetails
+	body, _ := ioutil.ReadAll(resp.Body)
+	if err := json.Unmarshal(body, &details); err == nil && details.Message != "" {
+		return errors.New(details.Message)
+	}
+	return fmt.Errorf("Remote resource replied with %v", resp.Status)
+}
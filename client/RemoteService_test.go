@@ -0,0 +1,140 @@
+package client_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/Travix-International/crud-go/client"
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+	"github.com/Travix-International/logger"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer(svc crud.Service) *httptest.Server {
+	loggy, _ := logger.New(make(map[string]string))
+	ctx := &servicefoundation.ContextBase{}
+	ctx.SetLogger(loggy)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/webhooks", crud.CreateCrudHandlerGetList(ctx, svc, "webhooks", crud.Recovery)).Methods("GET")
+	router.HandleFunc("/webhooks", crud.CreateCrudHandlerCreateEntity(ctx, svc, "webhooks", crud.Recovery, crud.NewWebhookSubscription)).Methods("POST")
+	router.HandleFunc("/webhooks/{id}", crud.CreateCrudHandlerGetByID(ctx, svc, "webhooks", crud.Recovery)).Methods("GET")
+	router.HandleFunc("/webhooks/{id}", crud.CreateCrudHandlerUpdateEntity(ctx, svc, "webhooks", crud.Recovery, crud.NewWebhookSubscription)).Methods("PUT")
+	router.HandleFunc("/webhooks/{id}", crud.CreateCrudHandlerDeleteByID(ctx, svc, "webhooks", crud.Recovery)).Methods("DELETE")
+	router.HandleFunc("/readonly", crud.ActionNotAvailableHandler).Methods("POST")
+	return httptest.NewServer(router)
+}
+
+func TestRemoteService_RoundTrip(t *testing.T) {
+	server := newTestServer(crud.NewWebhookSubscriptionService())
+	defer server.Close()
+	remote := client.NewRemoteService(server.URL+"/webhooks/", crud.NewWebhookSubscription)
+
+	result := remote.Add(&crud.WebhookSubscription{URL: "http://example.com/a", Resource: "things", Active: true})
+	assert.Equal(t, crud.Created, result.State())
+	assert.Equal(t, "1", result.Value())
+	remote.Add(&crud.WebhookSubscription{URL: "http://example.com/b", Resource: "others", Active: true})
+
+	result = remote.GetByID("1")
+	assert.Equal(t, crud.Ok, result.State())
+	assert.Equal(t, "http://example.com/a", result.Value().(*crud.WebhookSubscription).URL)
+
+	result = remote.GetAll(&crud.DataSetRequest{PageSize: 10, PageNumber: 1, SortColumn: "id", SortDirection: string(crud.Desc), Filters: map[string]string{"resource": "other"}})
+	assert.Equal(t, crud.Ok, result.State())
+	dataSet := result.Value().(*crud.DataSet)
+	assert.Equal(t, 1, dataSet.PagingInfo.TotalRecordsCount)
+	assert.Equal(t, "2", dataSet.Items[0].(*crud.WebhookSubscription).ID)
+
+	result = remote.Update("1", &crud.WebhookSubscription{URL: "http://example.com/c", Resource: "things"})
+	assert.Equal(t, crud.Ok, result.State())
+	assert.Equal(t, "http://example.com/c", result.Value().(*crud.WebhookSubscription).URL)
+
+	result = remote.Delete("1")
+	assert.Equal(t, crud.Ok, result.State())
+
+	result = remote.GetByID("1")
+	assert.Equal(t, crud.NotFound, result.State())
+}
+
+func TestRemoteService_MapsStates(t *testing.T) {
+	server := newTestServer(crud.NewWebhookSubscriptionService())
+	defer server.Close()
+
+	remote := client.NewRemoteService(server.URL+"/webhooks", nil)
+	result := remote.Add(&crud.WebhookSubscription{URL: "not a url", Resource: "things"})
+	assert.Equal(t, crud.ValidationFailed, result.State())
+	assert.Equal(t, "url must be an absolute http or https URL", result.Error().Error())
+
+	result = remote.Delete("42")
+	assert.Equal(t, crud.NotFound, result.State())
+
+	remote = client.NewRemoteService(server.URL+"/readonly", nil)
+	result = remote.Add(&crud.WebhookSubscription{})
+	assert.Equal(t, crud.NotSupportedByResource, result.State())
+}
+
+func TestRemoteService_GenericEntities(t *testing.T) {
+	server := newTestServer(crud.NewWebhookSubscriptionService())
+	defer server.Close()
+	remote := client.NewRemoteService(server.URL+"/webhooks", nil)
+	remote.Add(&crud.WebhookSubscription{URL: "http://example.com/a", Resource: "things"})
+
+	result := remote.GetByID("1")
+
+	assert.Equal(t, crud.Ok, result.State())
+	assert.Equal(t, "things", result.Value().(map[string]interface{})["resource"])
+}
+
+func TestEncodeDataSetRequest(t *testing.T) {
+	query, err := client.EncodeDataSetRequest(&crud.DataSetRequest{PageSize: 5, PageNumber: 2, SortColumn: "name", SortDirection: "Desc", Filters: map[string]string{"a": "b"}})
+	assert.Nil(t, err)
+
+	r, _ := http.NewRequest("GET", "/things?"+query.Encode(), nil)
+	dsRequest := crud.ExtractDataSetRequestFromURI(r)
+	assert.Equal(t, &crud.DataSetRequest{PageSize: 5, PageNumber: 2, SortColumn: "name", SortDirection: "Desc", Filters: map[string]string{"a": "b"}}, dsRequest)
+}
+
+func TestRemoteService_WaitForOperation(t *testing.T) {
+	loggy, _ := logger.New(make(map[string]string))
+	ctx := &servicefoundation.ContextBase{}
+	ctx.SetLogger(loggy)
+	tracker := crud.NewAsyncOperationTracker("/operations", time.Hour)
+	release := make(chan struct{})
+	svc := &asyncWebhookService{WebhookSubscriptionService: crud.NewWebhookSubscriptionService(), tracker: tracker, release: release}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/webhooks", crud.CreateCrudHandlerCreateEntity(ctx, svc, "webhooks", crud.Recovery, crud.NewWebhookSubscription)).Methods("POST")
+	router.HandleFunc("/operations/{id}", crud.CreateCrudHandlerGetAsyncOperation(ctx, tracker, crud.Recovery)).Methods("GET")
+	server := httptest.NewServer(router)
+	defer server.Close()
+	remote := client.NewRemoteService(server.URL+"/webhooks", crud.NewWebhookSubscription)
+
+	result := remote.Add(&crud.WebhookSubscription{URL: "http://example.com/a", Resource: "things", Active: true})
+	assert.Equal(t, crud.Accepted, result.State())
+	operation := result.Value().(*crud.AsyncOperation)
+	assert.Equal(t, crud.AsyncOperationRunning, operation.Status)
+
+	close(release)
+	operation, err := remote.WaitForOperation(operation, 5*time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, crud.AsyncOperationCompleted, operation.Status)
+	assert.Equal(t, crud.Created, operation.Result.OperationResult().State())
+	assert.Equal(t, "1", operation.Result.Value)
+}
+
+type asyncWebhookService struct {
+	*crud.WebhookSubscriptionService
+	tracker *crud.AsyncOperationTracker
+	release chan struct{}
+}
+
+func (s *asyncWebhookService) Add(entity crud.Entity) crud.OperationResult {
+	return s.tracker.Start("webhooks", func() crud.OperationResult {
+		<-s.release
+		return s.WebhookSubscriptionService.Add(entity)
+	})
+}
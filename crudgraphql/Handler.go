@@ -0,0 +1,96 @@
+package crudgraphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	crud "github.com/Travix-International/crud-go"
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// Request is a GraphQL request, as POSTed by GraphQL clients
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// CreateCrudHandlerGraphQL is used to execute GraphQL requests against a schema generated by NewSchema. Route it as
+// e.g. /graphql. Requests are accepted as a JSON body of a POST, or as the query, operationName and variables URI
+// parameters of a GET; mutations are only accepted over POST. The reply is the GraphQL result; errors of the operations
+// are part of it, so it's always OK unless the request can't be read.
+var CreateCrudHandlerGraphQL = func(ctx servicefoundation.AppContext, schema graphql.Schema, recoverFunc crud.RecoverFunc) http.HandlerFunc {
+	logger := ctx.Logger()
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer recoverFunc("crudHandler", ctx, w, r)
+
+		request, err := ExtractGraphQLRequest(r)
+		if err != nil {
+			crud.WriteOperationResult(w, r, crud.ValidationFailedResult(err))
+			return
+		}
+		if r.Method != "POST" && isMutation(request) {
+			crud.WriteOperationResult(w, r, crud.ValidationFailedResult(errors.New("Mutations must be POSTed")))
+			return
+		}
+
+		logger.Debug("CreateCrudHandlerGraphQL", fmt.Sprintf("Interpreted as GraphQL command. Operation: %v", request.OperationName))
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  request.Query,
+			OperationName:  request.OperationName,
+			VariableValues: request.Variables,
+			Context:        r.Context(),
+		})
+		crud.WriteOperationResult(w, r, crud.OkResult(result))
+	}
+}
+
+// ExtractGraphQLRequest is a helper function to read a GraphQL request from the body of a POST, or the URI parameters
+// of any other request.
+func ExtractGraphQLRequest(r *http.Request) (*Request, error) {
+	request := &Request{}
+	if r.Method == "POST" {
+		if err := crud.ReadEntityFromBody(r.Body, request); err != nil {
+			return nil, errors.New("Failed to parse GraphQL request from HTTP body: " + err.Error())
+		}
+	} else {
+		query := r.URL.Query()
+		request.Query = query.Get("query")
+		request.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				return nil, errors.New("Failed to parse variables: " + err.Error())
+			}
+		}
+	}
+	if request.Query == "" {
+		return nil, errors.New("A query is required")
+	}
+	return request, nil
+}
+
+// isMutation tells whether the operation to execute is a mutation. Requests that can't be parsed are left to the
+// executor to report.
+func isMutation(request *Request) bool {
+	document, err := parser.Parse(parser.ParseParams{Source: request.Query})
+	if err != nil {
+		return false
+	}
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if request.OperationName != "" && (operation.Name == nil || operation.Name.Value != request.OperationName) {
+			continue
+		}
+		return operation.Operation == ast.OperationTypeMutation
+	}
+	return false
+}
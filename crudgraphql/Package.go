@@ -0,0 +1,3 @@
+// Package crudgraphql exposes CRUD resources through GraphQL. NewSchema generates a schema from the resources of a
+// crud.Registry, with list and item queries and create, update and delete mutations performed on their Services.
+package crudgraphql
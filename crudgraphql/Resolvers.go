@@ -0,0 +1,160 @@
+package crudgraphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/graphql-go/graphql"
+)
+
+// OperationError reports an operation that didn't succeed. The state of the operation, and the HTTP status code the
+// CRUD handlers would have replied with, are passed to the client as the extensions of the GraphQL error.
+type OperationError struct {
+	// State is the state of the failed operation.
+	State crud.State
+	// Err is the error of the operation, if any.
+	Err error
+}
+
+func (e *OperationError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.State.String()
+}
+
+// Extensions returns the state, status code and details of the error
+func (e *OperationError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{
+		"state":  e.State.String(),
+		"status": crud.StatusCodeForState(e.State),
+	}
+	if detailed, ok := e.Err.(crud.DetailedError); ok {
+		extensions["details"] = detailed.Details()
+	}
+	return extensions
+}
+
+// errorForResult returns an OperationError for a result that isn't successful.
+func errorForResult(result crud.OperationResult) error {
+	switch result.State() {
+	case crud.Ok, crud.Created, crud.Accepted:
+		return nil
+	}
+	return &OperationError{State: result.State(), Err: result.Error()}
+}
+
+func resolveGetAll(resource *crud.Resource) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		request := &crud.DataSetRequest{
+			PageSize:      15,
+			PageNumber:    1,
+			SortColumn:    "id",
+			SortDirection: string(crud.Asc),
+		}
+		if pageSize, ok := p.Args["pageSize"].(int); ok && pageSize > 0 {
+			request.PageSize = pageSize
+		}
+		if pageNumber, ok := p.Args["pageNumber"].(int); ok && pageNumber > 0 {
+			request.PageNumber = pageNumber
+		}
+		if sortColumn, ok := p.Args["sortColumn"].(string); ok && sortColumn != "" {
+			request.SortColumn = sortColumn
+		}
+		if sortDirection, ok := p.Args["sortDirection"].(string); ok && strings.EqualFold(sortDirection, string(crud.Desc)) {
+			request.SortDirection = string(crud.Desc)
+		}
+		if filters, ok := p.Args["filters"].([]interface{}); ok && len(filters) > 0 {
+			request.Filters = make(map[string]string, len(filters))
+			for _, f := range filters {
+				if filter, ok := f.(map[string]interface{}); ok {
+					request.Filters[fmt.Sprint(filter["column"])] = fmt.Sprint(filter["value"])
+				}
+			}
+		}
+		if policy := resource.ResourcePolicy(); policy != nil {
+			policy.Apply(request)
+		}
+
+		result := resource.Service.GetAll(request)
+		if err := errorForResult(result); err != nil {
+			return nil, err
+		}
+		if _, ok := result.Value().(*crud.DataSet); !ok {
+			return nil, fmt.Errorf("Unexpected result of GetAll: %T", result.Value())
+		}
+		return toJSON(result.Value())
+	}
+}
+
+func resolveGetByID(resource *crud.Resource) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		result := resource.Service.GetByID(p.Args["id"])
+		if err := errorForResult(result); err != nil {
+			return nil, err
+		}
+		return toJSON(result.Value())
+	}
+}
+
+func resolveAdd(resource *crud.Resource) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		entity, err := decodeEntity(resource, p.Args["input"], true)
+		if err != nil {
+			return nil, err
+		}
+		result := resource.MutatingService().Add(entity)
+		if err := errorForResult(result); err != nil {
+			return nil, err
+		}
+		if result.State() != crud.Created {
+			return nil, nil
+		}
+		return result.Value(), nil
+	}
+}
+
+func resolveUpdate(resource *crud.Resource) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		entity, err := decodeEntity(resource, p.Args["input"], false)
+		if err != nil {
+			return nil, err
+		}
+		result := resource.MutatingService().Update(p.Args["id"], entity)
+		if err := errorForResult(result); err != nil {
+			return nil, err
+		}
+		return toJSON(result.Value())
+	}
+}
+
+func resolveDelete(resource *crud.Resource) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		result := resource.MutatingService().Delete(p.Args["id"])
+		if err := errorForResult(result); err != nil {
+			return nil, err
+		}
+		return true, nil
+	}
+}
+
+// decodeEntity converts the input of a mutation to an entity of the resource, and passes it through the pipeline.
+func decodeEntity(resource *crud.Resource, input interface{}, isNewEntity bool) (crud.Entity, error) {
+	if resource.CreateFunc == nil {
+		return nil, &OperationError{State: crud.NotSupportedByResource}
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, &OperationError{State: crud.ValidationFailed, Err: err}
+	}
+	entity := resource.CreateFunc()
+	if err := json.Unmarshal(data, entity); err != nil {
+		return nil, &OperationError{State: crud.ValidationFailed, Err: fmt.Errorf("Failed to parse entity: %v", err)}
+	}
+	if result := resource.EntityPipeline().Run(entity, isNewEntity); result != nil {
+		return nil, errorForResult(result)
+	}
+	return entity, nil
+}
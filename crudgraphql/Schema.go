@@ -0,0 +1,256 @@
+package crudgraphql
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// validName matches the names GraphQL accepts for types, fields and arguments.
+var validName = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+// JSON is a scalar holding any JSON value. It's used for entity fields that have no GraphQL equivalent, such as nested
+// objects, and for the entities of resources that can't be described.
+var JSON = graphql.NewScalar(graphql.ScalarConfig{
+	Name:         "JSON",
+	Description:  "Any JSON value",
+	Serialize:    func(value interface{}) interface{} { return value },
+	ParseValue:   func(value interface{}) interface{} { return value },
+	ParseLiteral: parseLiteral,
+})
+
+// SortDirection is the direction of the sortDirection argument of list queries.
+var SortDirection = graphql.NewEnum(graphql.EnumConfig{
+	Name: "SortDirection",
+	Values: graphql.EnumValueConfigMap{
+		string(crud.Asc):  &graphql.EnumValueConfig{Value: string(crud.Asc)},
+		string(crud.Desc): &graphql.EnumValueConfig{Value: string(crud.Desc)},
+	},
+})
+
+// Filter is an element of the filters argument of list queries, as in the filters of a crud.DataSetRequest.
+var Filter = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "Filter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"column": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"value":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+// PagingInfo describes the page of a list query, as in crud.PagingInfo.
+var PagingInfo = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PagingInfo",
+	Fields: graphql.Fields{
+		"supportsPaging":       &graphql.Field{Type: graphql.Boolean},
+		"doesKnowTotalRecords": &graphql.Field{Type: graphql.Boolean},
+		"pageSize":             &graphql.Field{Type: graphql.Int},
+		"pageNumber":           &graphql.Field{Type: graphql.Int},
+		"totalRecordCount":     &graphql.Field{Type: graphql.Int},
+	},
+})
+
+// NewSchema generates a GraphQL schema for the top-level resources of a registry. Every resource gets, depending on the
+// operations it exposes:
+//
+//	things(pageSize, pageNumber, sortColumn, sortDirection, filters): ThingDataSet  lists entities with GetAll
+//	thing(id): Thing                                                               gets an entity with GetByID
+//	createThing(input): ID                                                         adds an entity, returning its ID
+//	updateThing(id, input): Thing                                                  updates an entity
+//	deleteThing(id): Boolean                                                       deletes an entity
+//
+// The entity types are generated from the entities created by the CreateFunc of the resources. Fields whose JSON names
+// aren't valid GraphQL names are left out. Operations that don't succeed are reported as OperationErrors.
+func NewSchema(registry *crud.Registry) (graphql.Schema, error) {
+	queries := graphql.Fields{}
+	mutations := graphql.Fields{}
+
+	for _, resource := range registry.Resources() {
+		if resource.Parent != "" || resource.Service == nil {
+			continue
+		}
+		typeName := typeName(resource.Name)
+		if !validName.MatchString(typeName) {
+			return graphql.Schema{}, errors.New("Resource name can't be used in GraphQL: " + resource.Name)
+		}
+		singular := typeName
+		if strings.HasSuffix(singular, "s") && len(singular) > 1 {
+			singular = strings.TrimSuffix(singular, "s")
+		}
+		listField := lowerFirst(typeName)
+		itemField := lowerFirst(singular)
+		if itemField == listField {
+			itemField += "ById"
+		}
+
+		schema := crud.EntitySchema(resource.CreateFunc)
+		entityType, inputType := entityTypes(singular, schema)
+		idArgs := graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}}
+
+		if resource.Supports(crud.OperationGetList) {
+			queries[listField] = &graphql.Field{
+				Type: graphql.NewObject(graphql.ObjectConfig{
+					Name: singular + "DataSet",
+					Fields: graphql.Fields{
+						"items":      &graphql.Field{Type: graphql.NewList(entityType)},
+						"pagingInfo": &graphql.Field{Type: PagingInfo},
+					},
+				}),
+				Args: graphql.FieldConfigArgument{
+					"pageSize":      &graphql.ArgumentConfig{Type: graphql.Int},
+					"pageNumber":    &graphql.ArgumentConfig{Type: graphql.Int},
+					"sortColumn":    &graphql.ArgumentConfig{Type: graphql.String},
+					"sortDirection": &graphql.ArgumentConfig{Type: SortDirection},
+					"filters":       &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(Filter))},
+				},
+				Resolve: resolveGetAll(resource),
+			}
+		}
+		if resource.Supports(crud.OperationGetByID) {
+			queries[itemField] = &graphql.Field{Type: entityType, Args: idArgs, Resolve: resolveGetByID(resource)}
+		}
+		if resource.Supports(crud.OperationCreate) {
+			mutations["create"+singular] = &graphql.Field{
+				Type:    graphql.ID,
+				Args:    graphql.FieldConfigArgument{"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(inputType)}},
+				Resolve: resolveAdd(resource),
+			}
+		}
+		if resource.Supports(crud.OperationUpdate) {
+			mutations["update"+singular] = &graphql.Field{
+				Type: entityType,
+				Args: graphql.FieldConfigArgument{
+					"id":    idArgs["id"],
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(inputType)},
+				},
+				Resolve: resolveUpdate(resource),
+			}
+		}
+		if resource.Supports(crud.OperationDelete) {
+			mutations["delete"+singular] = &graphql.Field{Type: graphql.Boolean, Args: idArgs, Resolve: resolveDelete(resource)}
+		}
+	}
+
+	if len(queries) == 0 {
+		return graphql.Schema{}, errors.New("None of the resources can be queried")
+	}
+	config := graphql.SchemaConfig{Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: queries})}
+	if len(mutations) > 0 {
+		config.Mutation = graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: mutations})
+	}
+	return graphql.NewSchema(config)
+}
+
+// entityTypes generates the output and input types of an entity from its JSON schema. Entities without properties are
+// passed as JSON.
+func entityTypes(name string, schema map[string]interface{}) (graphql.Output, graphql.Input) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(properties))
+	for property := range properties {
+		if validName.MatchString(property) {
+			names = append(names, property)
+		}
+	}
+	if len(names) == 0 {
+		return JSON, JSON
+	}
+	sort.Strings(names)
+
+	fields := graphql.Fields{}
+	inputFields := graphql.InputObjectConfigFieldMap{}
+	for _, property := range names {
+		propertySchema, _ := properties[property].(map[string]interface{})
+		t := fieldType(propertySchema)
+		fields[property] = &graphql.Field{Type: t}
+		inputFields[property] = &graphql.InputObjectFieldConfig{Type: t}
+	}
+	return graphql.NewObject(graphql.ObjectConfig{Name: name, Fields: fields}),
+		graphql.NewInputObject(graphql.InputObjectConfig{Name: name + "Input", Fields: inputFields})
+}
+
+// fieldType maps the JSON schema of a field to a type that can be used for both output and input.
+func fieldType(schema map[string]interface{}) graphql.Type {
+	switch schema["type"] {
+	case "boolean":
+		return graphql.Boolean
+	case "integer":
+		return graphql.Int
+	case "number":
+		return graphql.Float
+	case "string":
+		return graphql.String
+	case "array":
+		items, _ := schema["items"].(map[string]interface{})
+		if t := fieldType(items); t != JSON {
+			return graphql.NewList(t)
+		}
+		return graphql.NewList(JSON)
+	}
+	return JSON
+}
+
+// parseLiteral converts a literal of a query to the JSON value it represents.
+func parseLiteral(value ast.Value) interface{} {
+	switch v := value.(type) {
+	case *ast.StringValue:
+		return v.Value
+	case *ast.BooleanValue:
+		return v.Value
+	case *ast.EnumValue:
+		return v.Value
+	case *ast.IntValue:
+		n, _ := strconv.ParseInt(v.Value, 10, 64)
+		return n
+	case *ast.FloatValue:
+		f, _ := strconv.ParseFloat(v.Value, 64)
+		return f
+	case *ast.ListValue:
+		list := make([]interface{}, 0, len(v.Values))
+		for _, item := range v.Values {
+			list = append(list, parseLiteral(item))
+		}
+		return list
+	case *ast.ObjectValue:
+		fields := make(map[string]interface{}, len(v.Fields))
+		for _, field := range v.Fields {
+			fields[field.Name.Value] = parseLiteral(field.Value)
+		}
+		return fields
+	}
+	return nil
+}
+
+// toJSON converts a value to its JSON representation, so that entities can be resolved by their JSON field names.
+func toJSON(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+// typeName turns a resource name into a type name, e.g. booking-items into BookingItems.
+func typeName(resourceName string) string {
+	parts := strings.FieldsFunc(resourceName, func(r rune) bool {
+		return r == '-' || r == '_' || r == '/' || r == ' ' || r == '.'
+	})
+	for i, part := range parts {
+		parts[i] = strings.ToUpper(part[:1]) + part[1:]
+	}
+	return strings.Join(parts, "")
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,157 @@
+package crudgraphql_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/Travix-International/crud-go/crudgraphql"
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+	"github.com/Travix-International/logger"
+	"github.com/graphql-go/graphql"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestContext() servicefoundation.AppContext {
+	loggy, _ := logger.New(make(map[string]string))
+	ctx := &servicefoundation.ContextBase{}
+	ctx.SetLogger(loggy)
+	return ctx
+}
+
+func newTestSchema(t *testing.T) (graphql.Schema, *crud.WebhookSubscriptionService) {
+	webhooks := crud.NewWebhookSubscriptionService()
+	registry := crud.NewRegistry(newTestContext(), crud.Recovery)
+	registry.Register(&crud.Resource{Name: "webhooks", Service: webhooks, CreateFunc: crud.NewWebhookSubscription})
+	registry.Register(&crud.Resource{
+		Name:       "readonly-webhooks",
+		Service:    crud.NewWebhookSubscriptionService(),
+		CreateFunc: crud.NewWebhookSubscription,
+		Operations: []crud.Operation{crud.OperationGetList, crud.OperationGetByID},
+	})
+
+	schema, err := crudgraphql.NewSchema(registry)
+	assert.Nil(t, err)
+	return schema, webhooks
+}
+
+func do(schema graphql.Schema, query string) map[string]interface{} {
+	result := graphql.Do(graphql.Params{Schema: schema, RequestString: query})
+	data, _ := json.Marshal(result)
+	var decoded map[string]interface{}
+	json.Unmarshal(data, &decoded)
+	return decoded
+}
+
+func TestNewSchema(t *testing.T) {
+	schema, webhooks := newTestSchema(t)
+
+	result := do(schema, `mutation {
+		first: createWebhook(input: {url: "https://example.com/first", resource: "bookings", types: ["created"]})
+		second: createWebhook(input: {url: "https://example.com/second", resource: "payments"})
+	}`)
+	assert.Nil(t, result["errors"])
+	assert.Equal(t, map[string]interface{}{"first": "1", "second": "2"}, result["data"])
+	assert.Len(t, webhooks.GetAll(&crud.DataSetRequest{PageSize: 10, PageNumber: 1}).Value().(*crud.DataSet).Items, 2)
+
+	result = do(schema, `{
+		webhooks(pageSize: 10, sortColumn: "url", sortDirection: Desc, filters: [{column: "resource", value: "bookings"}]) {
+			items { id url types }
+			pagingInfo { pageNumber }
+		}
+		webhook(id: "2") { resource active }
+	}`)
+	assert.Nil(t, result["errors"])
+	assert.Equal(t, map[string]interface{}{
+		"webhooks": map[string]interface{}{
+			"items":      []interface{}{map[string]interface{}{"id": "1", "url": "https://example.com/first", "types": []interface{}{"created"}}},
+			"pagingInfo": map[string]interface{}{"pageNumber": float64(1)},
+		},
+		"webhook": map[string]interface{}{"resource": "payments", "active": true},
+	}, result["data"])
+
+	result = do(schema, `mutation {
+		updateWebhook(id: "1", input: {url: "https://example.com/updated", resource: "bookings"}) { url }
+		deleteWebhook(id: "2")
+	}`)
+	assert.Nil(t, result["errors"])
+	assert.Equal(t, map[string]interface{}{
+		"updateWebhook": map[string]interface{}{"url": "https://example.com/updated"},
+		"deleteWebhook": true,
+	}, result["data"])
+}
+
+func TestNewSchema_Errors(t *testing.T) {
+	schema, _ := newTestSchema(t)
+
+	result := do(schema, `{ webhook(id: "42") { url } }`)
+	errors := result["errors"].([]interface{})
+	assert.Len(t, errors, 1)
+	assert.Equal(t, map[string]interface{}{"state": "NotFound", "status": float64(404)}, errors[0].(map[string]interface{})["extensions"])
+
+	result = do(schema, `mutation { createWebhook(input: {url: "not a url", resource: "bookings"}) }`)
+	errors = result["errors"].([]interface{})
+	assert.Equal(t, "ValidationFailed", errors[0].(map[string]interface{})["extensions"].(map[string]interface{})["state"])
+
+	// Operations a resource doesn't expose aren't in the schema
+	result = do(schema, `mutation { createReadonlyWebhook(input: {url: "https://example.com"}) }`)
+	assert.NotNil(t, result["errors"])
+	result = do(schema, `{ readonlyWebhooks { items { id } } }`)
+	assert.Nil(t, result["errors"])
+}
+
+func TestCreateCrudHandlerGraphQL(t *testing.T) {
+	schema, _ := newTestSchema(t)
+	handler := crudgraphql.CreateCrudHandlerGraphQL(newTestContext(), schema, crud.Recovery)
+
+	r, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "mutation Add($url: String) { createWebhook(input: {url: $url, resource: \"bookings\"}) }", "variables": {"url": "https://example.com"}}`))
+	w := httptest.NewRecorder()
+	handler(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data": {"createWebhook": "1"}}`, w.Body.String())
+
+	r, _ = http.NewRequest("GET", "/graphql?query="+url.QueryEscape(`{ webhook(id: "1") { url } }`), nil)
+	w = httptest.NewRecorder()
+	handler(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data": {"webhook": {"url": "https://example.com"}}}`, w.Body.String())
+
+	r, _ = http.NewRequest("GET", "/graphql?query="+url.QueryEscape(`mutation { deleteWebhook(id: "1") }`), nil)
+	w = httptest.NewRecorder()
+	handler(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	r, _ = http.NewRequest("POST", "/graphql", strings.NewReader(`{}`))
+	w = httptest.NewRecorder()
+	handler(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestNewSchema_RunsHooks(t *testing.T) {
+	webhooks := crud.NewWebhookSubscriptionService()
+	registry := crud.NewRegistry(newTestContext(), crud.Recovery)
+	registry.Register(&crud.Resource{
+		Name:       "webhooks",
+		Service:    webhooks,
+		CreateFunc: crud.NewWebhookSubscription,
+		Hooks: &crud.Hooks{
+			BeforeDelete: func(id crud.EntityKey, entity crud.Entity) crud.OperationResult {
+				return crud.ConflictResult(nil)
+			},
+		},
+	})
+	schema, err := crudgraphql.NewSchema(registry)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	result := do(schema, `mutation { createWebhook(input: {url: "https://example.com/first", resource: "bookings"}) }`)
+	assert.Nil(t, result["errors"])
+	result = do(schema, `mutation { deleteWebhook(id: "1") }`)
+	assert.NotNil(t, result["errors"])
+	assert.Equal(t, crud.Ok, webhooks.GetByID("1").State())
+}
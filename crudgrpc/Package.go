@@ -0,0 +1,9 @@
+// Package crudgrpc exposes CRUD resources over gRPC. crud.proto defines a generic service for the CRUD protocol, with
+// entities as google.protobuf.Struct; Server implements it for the resources of a crud.Registry, mapping the State of
+// every operation to a gRPC status code.
+//
+// crud.pb.go and crud_grpc.pb.go are generated from crud.proto; regenerate them after changing it with make proto, which
+// uses the versions of protoc, protoc-gen-go and protoc-gen-go-grpc pinned in the Makefile.
+package crudgrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative crud.proto
@@ -0,0 +1,304 @@
+package crudgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	crud "github.com/Travix-International/crud-go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Server implements the Crud service for the top-level resources of a registry. Operations are performed on the
+// Service of the resource named in the request, the same way the CRUD handlers perform them: list requests are
+// constrained by the policy of the resource, or rejected if the policy is strict and they break it, entities are passed
+// through the pipeline of the Service before they're added or updated, and mutations run the hooks of the resource.
+type Server struct {
+	UnimplementedCrudServer
+	Registry *crud.Registry
+}
+
+// NewServer creates a server for the resources of the registry. Register it on a gRPC server with RegisterCrudServer.
+func NewServer(registry *crud.Registry) *Server {
+	return &Server{Registry: registry}
+}
+
+// CodeForState maps the State of an operation to a gRPC status code, the way StatusCodeForState maps it to an HTTP
+// status code.
+func CodeForState(state crud.State) codes.Code {
+	switch state {
+	case crud.Ok, crud.Created, crud.Accepted:
+		return codes.OK
+	case crud.ValidationFailed:
+		return codes.InvalidArgument
+	case crud.NotFound:
+		return codes.NotFound
+	case crud.Conflict:
+		return codes.Aborted
+	case crud.NotSupportedByResource:
+		return codes.Unimplemented
+	}
+	return codes.Internal
+}
+
+// GetAll gets a page of the entities of a resource.
+func (s *Server) GetAll(ctx context.Context, in *GetAllRequest) (*GetAllResponse, error) {
+	resource, err := s.resource(in.Resource, crud.OperationGetList)
+	if err != nil {
+		return nil, err
+	}
+
+	request := dataSetRequestFromProto(in.Request)
+	if policy := resource.ResourcePolicy(); policy != nil {
+		if policy.Strict {
+			if request, err = strictDataSetRequestFromProto(in.Request, policy); err != nil {
+				return nil, err
+			}
+		} else {
+			policy.Apply(request)
+		}
+	}
+	result := resource.Service.GetAll(request)
+	if err := errorForResult(result); err != nil {
+		return nil, err
+	}
+	dataSet, ok := result.Value().(*crud.DataSet)
+	if !ok || dataSet == nil {
+		return nil, status.Errorf(codes.Internal, "Unexpected result of GetAll: %T", result.Value())
+	}
+
+	response := &GetAllResponse{
+		State: State(result.State()),
+		DataSet: &DataSet{
+			Items: make([]*structpb.Struct, 0, len(dataSet.Items)),
+			PagingInfo: &PagingInfo{
+				SupportsPaging:       dataSet.PagingInfo.SupportsPaging,
+				DoesKnowTotalRecords: dataSet.PagingInfo.DoesKnowTotalRecords,
+				PageSize:             int32(dataSet.PagingInfo.PageSize),
+				PageNumber:           int32(dataSet.PagingInfo.PageNumber),
+				TotalRecordsCount:    int32(dataSet.PagingInfo.TotalRecordsCount),
+			},
+		},
+	}
+	for _, item := range dataSet.Items {
+		value, err := toValue(item)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		entity := value.GetStructValue()
+		if entity == nil {
+			return nil, status.Errorf(codes.Internal, "Entity is not an object: %v", item)
+		}
+		response.DataSet.Items = append(response.DataSet.Items, entity)
+	}
+	return response, nil
+}
+
+// GetByID gets a single entity of a resource.
+func (s *Server) GetByID(ctx context.Context, in *GetByIDRequest) (*OperationResponse, error) {
+	resource, err := s.resource(in.Resource, crud.OperationGetByID)
+	if err != nil {
+		return nil, err
+	}
+	return operationResponse(resource.Service.GetByID(in.Id))
+}
+
+// Add adds an entity to a resource. The value of the response is the ID of the new entity, if the Service returns it.
+func (s *Server) Add(ctx context.Context, in *AddRequest) (*OperationResponse, error) {
+	resource, err := s.resource(in.Resource, crud.OperationCreate)
+	if err != nil {
+		return nil, err
+	}
+	entity, err := decodeEntity(resource, in.Entity, true)
+	if err != nil {
+		return nil, err
+	}
+	return operationResponse(resource.MutatingService().Add(entity))
+}
+
+// Update updates an entity of a resource. The value of the response is the new state of the entity.
+func (s *Server) Update(ctx context.Context, in *UpdateRequest) (*OperationResponse, error) {
+	resource, err := s.resource(in.Resource, crud.OperationUpdate)
+	if err != nil {
+		return nil, err
+	}
+	entity, err := decodeEntity(resource, in.Entity, false)
+	if err != nil {
+		return nil, err
+	}
+	return operationResponse(resource.MutatingService().Update(in.Id, entity))
+}
+
+// Delete deletes an entity of a resource.
+func (s *Server) Delete(ctx context.Context, in *DeleteRequest) (*OperationResponse, error) {
+	resource, err := s.resource(in.Resource, crud.OperationDelete)
+	if err != nil {
+		return nil, err
+	}
+	return operationResponse(resource.MutatingService().Delete(in.Id))
+}
+
+// resource looks up a resource that exposes the operation.
+func (s *Server) resource(name string, operation crud.Operation) (*crud.Resource, error) {
+	resource, ok := s.Registry.Resource(name)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Unknown resource: %v", name)
+	}
+	if resource.Parent != "" || resource.Service == nil {
+		return nil, status.Errorf(codes.Unimplemented, "Resource %v is a child resource, which isn't available over gRPC", name)
+	}
+	if !resource.Supports(operation) {
+		return nil, status.Errorf(codes.Unimplemented, "Resource %v doesn't support %v", name, operation)
+	}
+	return resource, nil
+}
+
+// dataSetRequestFromProto converts a list request, applying the defaults of the list handler.
+func dataSetRequestFromProto(in *DataSetRequest) *crud.DataSetRequest {
+	request := &crud.DataSetRequest{
+		PageSize:      15,
+		PageNumber:    1,
+		SortColumn:    "id",
+		SortDirection: string(crud.Asc),
+	}
+	if in == nil {
+		return request
+	}
+	if in.PageSize > 0 {
+		request.PageSize = int(in.PageSize)
+	}
+	if in.PageNumber > 0 {
+		request.PageNumber = int(in.PageNumber)
+	}
+	if in.SortColumn != "" {
+		request.SortColumn = in.SortColumn
+	}
+	if strings.ToLower(in.SortDirection) == "desc" {
+		request.SortDirection = string(crud.Desc)
+	}
+	if len(in.Filters) > 0 {
+		request.Filters = in.Filters
+	}
+	return request
+}
+
+// strictDataSetRequestFromProto converts a list request for a resource with a strict policy, rejecting it if it breaks
+// the rules of the policy, as ParseDataSetRequestStrict does for the URI parameters of the list handler.
+func strictDataSetRequestFromProto(in *DataSetRequest, policy *crud.ResourcePolicy) (*crud.DataSetRequest, error) {
+	// Defaults never break the policy; only the values given do
+	request := dataSetRequestFromProto(nil)
+	policy.Apply(request)
+	if policy.DefaultSortColumn != "" {
+		request.SortColumn = policy.DefaultSortColumn
+	}
+	if in == nil {
+		in = &DataSetRequest{}
+	}
+
+	problems := make([]crud.QueryProblem, 0)
+	report := func(parameter, value, message string) {
+		problems = append(problems, crud.QueryProblem{Parameter: parameter, Value: value, Message: message})
+	}
+	switch {
+	case in.PageSize < 0:
+		report("pageSize", fmt.Sprint(in.PageSize), "must be a whole number of at least 1")
+	case in.PageSize > 0:
+		request.PageSize = int(in.PageSize)
+	}
+	switch {
+	case in.PageNumber < 0:
+		report("pageNumber", fmt.Sprint(in.PageNumber), "must be a whole number of at least 1")
+	case in.PageNumber > 0:
+		request.PageNumber = int(in.PageNumber)
+	}
+	if in.SortColumn != "" {
+		request.SortColumn = in.SortColumn
+	}
+	switch strings.ToLower(in.SortDirection) {
+	case "":
+	case "asc":
+		request.SortDirection = string(crud.Asc)
+	case "desc":
+		request.SortDirection = string(crud.Desc)
+	default:
+		report("sortDirection", in.SortDirection, "must be Asc or Desc")
+	}
+	if len(in.Filters) > 0 {
+		request.Filters = in.Filters
+	}
+
+	for _, problem := range policy.Check(request) {
+		if problem.Parameter == "sortColumn" && in.SortColumn == "" {
+			continue
+		}
+		problems = append(problems, problem)
+	}
+	if len(problems) > 0 {
+		return nil, status.Error(codes.InvalidArgument, (&crud.QueryValidationError{Problems: problems}).Error())
+	}
+	return request, nil
+}
+
+// decodeEntity converts the entity of a request to an entity of the resource, and passes it through the pipeline.
+func decodeEntity(resource *crud.Resource, in *structpb.Struct, isNewEntity bool) (crud.Entity, error) {
+	if resource.CreateFunc == nil {
+		return nil, status.Errorf(codes.Unimplemented, "Resource %v can't decode entities", resource.Name)
+	}
+	fields := make(map[string]interface{})
+	if in != nil {
+		fields = in.AsMap()
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	entity := resource.CreateFunc()
+	if err := json.Unmarshal(data, entity); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Failed to parse entity: "+err.Error())
+	}
+	if result := resource.EntityPipeline().Run(entity, isNewEntity); result != nil {
+		return nil, errorForResult(result)
+	}
+	return entity, nil
+}
+
+// operationResponse converts the result of an operation on a single entity, or returns the status of a failed one.
+func operationResponse(result crud.OperationResult) (*OperationResponse, error) {
+	if err := errorForResult(result); err != nil {
+		return nil, err
+	}
+	value, err := toValue(result.Value())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &OperationResponse{State: State(result.State()), Value: value}, nil
+}
+
+// errorForResult returns the status for a result that isn't successful.
+func errorForResult(result crud.OperationResult) error {
+	code := CodeForState(result.State())
+	if code == codes.OK {
+		return nil
+	}
+	message := result.State().String()
+	if result.Error() != nil {
+		message = result.Error().Error()
+	}
+	return status.Error(code, message)
+}
+
+// toValue converts any value to a protobuf Value, by way of its JSON representation.
+func toValue(v interface{}) (*structpb.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	return structpb.NewValue(decoded)
+}
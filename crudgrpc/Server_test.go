@@ -0,0 +1,193 @@
+package crudgrpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/Travix-International/crud-go/crudgrpc"
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+	"github.com/Travix-International/logger"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newTestServer() *crudgrpc.Server {
+	loggy, _ := logger.New(make(map[string]string))
+	ctx := &servicefoundation.ContextBase{}
+	ctx.SetLogger(loggy)
+
+	registry := crud.NewRegistry(ctx, crud.Recovery)
+	registry.Register(&crud.Resource{Name: "webhooks", Service: crud.NewWebhookSubscriptionService(), CreateFunc: crud.NewWebhookSubscription})
+	registry.Register(&crud.Resource{
+		Name:       "readonly",
+		Service:    crud.NewWebhookSubscriptionService(),
+		CreateFunc: crud.NewWebhookSubscription,
+		Operations: []crud.Operation{crud.OperationGetList},
+	})
+	return crudgrpc.NewServer(registry)
+}
+
+func subscription(url string) *structpb.Struct {
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		"url":      structpb.NewStringValue(url),
+		"resource": structpb.NewStringValue("bookings"),
+	}}
+}
+
+func TestServer(t *testing.T) {
+	server := newTestServer()
+	ctx := context.Background()
+
+	added, err := server.Add(ctx, &crudgrpc.AddRequest{Resource: "webhooks", Entity: subscription("https://example.com/hook")})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, crudgrpc.State_CREATED, added.State)
+	id := added.Value.GetStringValue()
+	assert.NotEmpty(t, id)
+
+	got, err := server.GetByID(ctx, &crudgrpc.GetByIDRequest{Resource: "webhooks", Id: id})
+	assert.Nil(t, err)
+	assert.Equal(t, crudgrpc.State_OK, got.State)
+	assert.Equal(t, "https://example.com/hook", got.Value.GetStructValue().Fields["url"].GetStringValue())
+
+	updated, err := server.Update(ctx, &crudgrpc.UpdateRequest{Resource: "webhooks", Id: id, Entity: subscription("https://example.com/other")})
+	assert.Nil(t, err)
+	assert.Equal(t, crudgrpc.State_OK, updated.State)
+
+	list, err := server.GetAll(ctx, &crudgrpc.GetAllRequest{Resource: "webhooks", Request: &crudgrpc.DataSetRequest{PageSize: 10}})
+	assert.Nil(t, err)
+	if assert.Len(t, list.DataSet.Items, 1) {
+		assert.Equal(t, "https://example.com/other", list.DataSet.Items[0].Fields["url"].GetStringValue())
+	}
+	assert.Equal(t, int32(1), list.DataSet.PagingInfo.PageNumber)
+
+	_, err = server.Delete(ctx, &crudgrpc.DeleteRequest{Resource: "webhooks", Id: id})
+	assert.Nil(t, err)
+	_, err = server.GetByID(ctx, &crudgrpc.GetByIDRequest{Resource: "webhooks", Id: id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_OverConnection(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	crudgrpc.RegisterCrudServer(grpcServer, newTestServer())
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}))
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer conn.Close()
+	client := crudgrpc.NewCrudClient(conn)
+	ctx := context.Background()
+
+	added, err := client.Add(ctx, &crudgrpc.AddRequest{Resource: "webhooks", Entity: subscription("https://example.com/hook")})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, crudgrpc.State_CREATED, added.State)
+
+	list, err := client.GetAll(ctx, &crudgrpc.GetAllRequest{Resource: "webhooks", Request: &crudgrpc.DataSetRequest{Filters: map[string]string{"resource": "bookings"}}})
+	assert.Nil(t, err)
+	if assert.Len(t, list.DataSet.Items, 1) {
+		assert.Equal(t, "https://example.com/hook", list.DataSet.Items[0].Fields["url"].GetStringValue())
+	}
+
+	_, err = client.GetByID(ctx, &crudgrpc.GetByIDRequest{Resource: "webhooks", Id: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_Errors(t *testing.T) {
+	server := newTestServer()
+	ctx := context.Background()
+
+	_, err := server.Add(ctx, &crudgrpc.AddRequest{Resource: "webhooks", Entity: subscription("not a url")})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = server.Add(ctx, &crudgrpc.AddRequest{Resource: "readonly", Entity: subscription("https://example.com/hook")})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+
+	_, err = server.GetAll(ctx, &crudgrpc.GetAllRequest{Resource: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_StrictPolicy(t *testing.T) {
+	server := newTestServer()
+	resource, _ := server.Registry.Resource("webhooks")
+	resource.Policy = &crud.ResourcePolicy{
+		MaxPageSize:   10,
+		SortColumns:   []string{"id"},
+		FilterColumns: []string{"resource"},
+		Strict:        true,
+	}
+	ctx := context.Background()
+
+	_, err := server.GetAll(ctx, &crudgrpc.GetAllRequest{Resource: "webhooks"})
+	assert.Nil(t, err)
+	_, err = server.GetAll(ctx, &crudgrpc.GetAllRequest{Resource: "webhooks", Request: &crudgrpc.DataSetRequest{SortColumn: "id", Filters: map[string]string{"resource": "bookings"}}})
+	assert.Nil(t, err)
+
+	for _, request := range []*crudgrpc.DataSetRequest{
+		{PageSize: 50},
+		{SortColumn: "url"},
+		{SortDirection: "up"},
+		{Filters: map[string]string{"url": "https://example.com"}},
+	} {
+		_, err = server.GetAll(ctx, &crudgrpc.GetAllRequest{Resource: "webhooks", Request: request})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), "%v", request)
+	}
+}
+
+func TestCodeForState(t *testing.T) {
+	assert.Equal(t, codes.OK, crudgrpc.CodeForState(crud.Created))
+	assert.Equal(t, codes.OK, crudgrpc.CodeForState(crud.Accepted))
+	assert.Equal(t, codes.InvalidArgument, crudgrpc.CodeForState(crud.ValidationFailed))
+	assert.Equal(t, codes.NotFound, crudgrpc.CodeForState(crud.NotFound))
+	assert.Equal(t, codes.Aborted, crudgrpc.CodeForState(crud.Conflict))
+	assert.Equal(t, codes.Unimplemented, crudgrpc.CodeForState(crud.NotSupportedByResource))
+	assert.Equal(t, codes.Internal, crudgrpc.CodeForState(crud.Error))
+}
+
+func TestServer_RunsHooks(t *testing.T) {
+	server := newTestServer()
+	resource, _ := server.Registry.Resource("webhooks")
+	deleted := make([]crud.EntityKey, 0)
+	resource.Hooks = &crud.Hooks{
+		BeforeAdd: func(entity crud.Entity) crud.OperationResult {
+			if entity.(*crud.WebhookSubscription).Resource == "payments" {
+				return crud.ConflictResult(nil)
+			}
+			return nil
+		},
+		AfterDelete: func(id crud.EntityKey, entity crud.Entity, result crud.OperationResult) {
+			deleted = append(deleted, id)
+		},
+	}
+	ctx := context.Background()
+
+	vetoed := subscription("https://example.com/hook")
+	vetoed.Fields["resource"] = structpb.NewStringValue("payments")
+	_, err := server.Add(ctx, &crudgrpc.AddRequest{Resource: "webhooks", Entity: vetoed})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	added, err := server.Add(ctx, &crudgrpc.AddRequest{Resource: "webhooks", Entity: subscription("https://example.com/hook")})
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = server.Delete(ctx, &crudgrpc.DeleteRequest{Resource: "webhooks", Id: added.Value.GetStringValue()})
+	assert.Nil(t, err)
+	assert.Equal(t, []crud.EntityKey{added.Value.GetStringValue()}, deleted)
+}
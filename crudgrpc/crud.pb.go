@@ -0,0 +1,824 @@
+// The CRUD protocol as a gRPC service. Every request names the resource it applies to, as registered in the
+// crud.Registry of the server. Entities are passed as JSON objects.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: crud.proto
+
+package crudgrpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// State is the high level result of an operation. Failed operations are reported through the gRPC status instead.
+type State int32
+
+const (
+	State_STATE_UNKNOWN             State = 0
+	State_OK                        State = 1
+	State_VALIDATION_FAILED         State = 2
+	State_ERROR                     State = 3
+	State_NOT_FOUND                 State = 4
+	State_CONFLICT                  State = 5
+	State_NOT_SUPPORTED_BY_RESOURCE State = 6
+	State_CREATED                   State = 7
+	State_ACCEPTED                  State = 8
+)
+
+// Enum value maps for State.
+var (
+	State_name = map[int32]string{
+		0: "STATE_UNKNOWN",
+		1: "OK",
+		2: "VALIDATION_FAILED",
+		3: "ERROR",
+		4: "NOT_FOUND",
+		5: "CONFLICT",
+		6: "NOT_SUPPORTED_BY_RESOURCE",
+		7: "CREATED",
+		8: "ACCEPTED",
+	}
+	State_value = map[string]int32{
+		"STATE_UNKNOWN":             0,
+		"OK":                        1,
+		"VALIDATION_FAILED":         2,
+		"ERROR":                     3,
+		"NOT_FOUND":                 4,
+		"CONFLICT":                  5,
+		"NOT_SUPPORTED_BY_RESOURCE": 6,
+		"CREATED":                   7,
+		"ACCEPTED":                  8,
+	}
+)
+
+func (x State) Enum() *State {
+	p := new(State)
+	*p = x
+	return p
+}
+
+func (x State) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (State) Descriptor() protoreflect.EnumDescriptor {
+	return file_crud_proto_enumTypes[0].Descriptor()
+}
+
+func (State) Type() protoreflect.EnumType {
+	return &file_crud_proto_enumTypes[0]
+}
+
+func (x State) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use State.Descriptor instead.
+func (State) EnumDescriptor() ([]byte, []int) {
+	return file_crud_proto_rawDescGZIP(), []int{0}
+}
+
+type DataSetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PageSize      int32                  `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageNumber    int32                  `protobuf:"varint,2,opt,name=page_number,json=pageNumber,proto3" json:"page_number,omitempty"`
+	SortColumn    string                 `protobuf:"bytes,3,opt,name=sort_column,json=sortColumn,proto3" json:"sort_column,omitempty"`
+	SortDirection string                 `protobuf:"bytes,4,opt,name=sort_direction,json=sortDirection,proto3" json:"sort_direction,omitempty"`
+	Filters       map[string]string      `protobuf:"bytes,5,rep,name=filters,proto3" json:"filters,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DataSetRequest) Reset() {
+	*x = DataSetRequest{}
+	mi := &file_crud_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DataSetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DataSetRequest) ProtoMessage() {}
+
+func (x *DataSetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_crud_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DataSetRequest.ProtoReflect.Descriptor instead.
+func (*DataSetRequest) Descriptor() ([]byte, []int) {
+	return file_crud_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *DataSetRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *DataSetRequest) GetPageNumber() int32 {
+	if x != nil {
+		return x.PageNumber
+	}
+	return 0
+}
+
+func (x *DataSetRequest) GetSortColumn() string {
+	if x != nil {
+		return x.SortColumn
+	}
+	return ""
+}
+
+func (x *DataSetRequest) GetSortDirection() string {
+	if x != nil {
+		return x.SortDirection
+	}
+	return ""
+}
+
+func (x *DataSetRequest) GetFilters() map[string]string {
+	if x != nil {
+		return x.Filters
+	}
+	return nil
+}
+
+type PagingInfo struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	SupportsPaging       bool                   `protobuf:"varint,1,opt,name=supports_paging,json=supportsPaging,proto3" json:"supports_paging,omitempty"`
+	DoesKnowTotalRecords bool                   `protobuf:"varint,2,opt,name=does_know_total_records,json=doesKnowTotalRecords,proto3" json:"does_know_total_records,omitempty"`
+	PageSize             int32                  `protobuf:"varint,3,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageNumber           int32                  `protobuf:"varint,4,opt,name=page_number,json=pageNumber,proto3" json:"page_number,omitempty"`
+	TotalRecordsCount    int32                  `protobuf:"varint,5,opt,name=total_records_count,json=totalRecordsCount,proto3" json:"total_records_count,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *PagingInfo) Reset() {
+	*x = PagingInfo{}
+	mi := &file_crud_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PagingInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PagingInfo) ProtoMessage() {}
+
+func (x *PagingInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_crud_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PagingInfo.ProtoReflect.Descriptor instead.
+func (*PagingInfo) Descriptor() ([]byte, []int) {
+	return file_crud_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PagingInfo) GetSupportsPaging() bool {
+	if x != nil {
+		return x.SupportsPaging
+	}
+	return false
+}
+
+func (x *PagingInfo) GetDoesKnowTotalRecords() bool {
+	if x != nil {
+		return x.DoesKnowTotalRecords
+	}
+	return false
+}
+
+func (x *PagingInfo) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *PagingInfo) GetPageNumber() int32 {
+	if x != nil {
+		return x.PageNumber
+	}
+	return 0
+}
+
+func (x *PagingInfo) GetTotalRecordsCount() int32 {
+	if x != nil {
+		return x.TotalRecordsCount
+	}
+	return 0
+}
+
+type DataSet struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Items         []*structpb.Struct     `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	PagingInfo    *PagingInfo            `protobuf:"bytes,2,opt,name=paging_info,json=pagingInfo,proto3" json:"paging_info,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DataSet) Reset() {
+	*x = DataSet{}
+	mi := &file_crud_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DataSet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DataSet) ProtoMessage() {}
+
+func (x *DataSet) ProtoReflect() protoreflect.Message {
+	mi := &file_crud_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DataSet.ProtoReflect.Descriptor instead.
+func (*DataSet) Descriptor() ([]byte, []int) {
+	return file_crud_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DataSet) GetItems() []*structpb.Struct {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *DataSet) GetPagingInfo() *PagingInfo {
+	if x != nil {
+		return x.PagingInfo
+	}
+	return nil
+}
+
+type GetAllRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Resource      string                 `protobuf:"bytes,1,opt,name=resource,proto3" json:"resource,omitempty"`
+	Request       *DataSetRequest        `protobuf:"bytes,2,opt,name=request,proto3" json:"request,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetAllRequest) Reset() {
+	*x = GetAllRequest{}
+	mi := &file_crud_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAllRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAllRequest) ProtoMessage() {}
+
+func (x *GetAllRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_crud_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAllRequest.ProtoReflect.Descriptor instead.
+func (*GetAllRequest) Descriptor() ([]byte, []int) {
+	return file_crud_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetAllRequest) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+func (x *GetAllRequest) GetRequest() *DataSetRequest {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+type GetAllResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	State         State                  `protobuf:"varint,1,opt,name=state,proto3,enum=crud.State" json:"state,omitempty"`
+	DataSet       *DataSet               `protobuf:"bytes,2,opt,name=data_set,json=dataSet,proto3" json:"data_set,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetAllResponse) Reset() {
+	*x = GetAllResponse{}
+	mi := &file_crud_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAllResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAllResponse) ProtoMessage() {}
+
+func (x *GetAllResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_crud_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAllResponse.ProtoReflect.Descriptor instead.
+func (*GetAllResponse) Descriptor() ([]byte, []int) {
+	return file_crud_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetAllResponse) GetState() State {
+	if x != nil {
+		return x.State
+	}
+	return State_STATE_UNKNOWN
+}
+
+func (x *GetAllResponse) GetDataSet() *DataSet {
+	if x != nil {
+		return x.DataSet
+	}
+	return nil
+}
+
+type GetByIDRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Resource      string                 `protobuf:"bytes,1,opt,name=resource,proto3" json:"resource,omitempty"`
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetByIDRequest) Reset() {
+	*x = GetByIDRequest{}
+	mi := &file_crud_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetByIDRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetByIDRequest) ProtoMessage() {}
+
+func (x *GetByIDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_crud_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetByIDRequest.ProtoReflect.Descriptor instead.
+func (*GetByIDRequest) Descriptor() ([]byte, []int) {
+	return file_crud_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetByIDRequest) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+func (x *GetByIDRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type AddRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Resource      string                 `protobuf:"bytes,1,opt,name=resource,proto3" json:"resource,omitempty"`
+	Entity        *structpb.Struct       `protobuf:"bytes,2,opt,name=entity,proto3" json:"entity,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddRequest) Reset() {
+	*x = AddRequest{}
+	mi := &file_crud_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddRequest) ProtoMessage() {}
+
+func (x *AddRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_crud_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddRequest.ProtoReflect.Descriptor instead.
+func (*AddRequest) Descriptor() ([]byte, []int) {
+	return file_crud_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *AddRequest) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+func (x *AddRequest) GetEntity() *structpb.Struct {
+	if x != nil {
+		return x.Entity
+	}
+	return nil
+}
+
+type UpdateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Resource      string                 `protobuf:"bytes,1,opt,name=resource,proto3" json:"resource,omitempty"`
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Entity        *structpb.Struct       `protobuf:"bytes,3,opt,name=entity,proto3" json:"entity,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateRequest) Reset() {
+	*x = UpdateRequest{}
+	mi := &file_crud_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateRequest) ProtoMessage() {}
+
+func (x *UpdateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_crud_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateRequest.ProtoReflect.Descriptor instead.
+func (*UpdateRequest) Descriptor() ([]byte, []int) {
+	return file_crud_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *UpdateRequest) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+func (x *UpdateRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *UpdateRequest) GetEntity() *structpb.Struct {
+	if x != nil {
+		return x.Entity
+	}
+	return nil
+}
+
+type DeleteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Resource      string                 `protobuf:"bytes,1,opt,name=resource,proto3" json:"resource,omitempty"`
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteRequest) Reset() {
+	*x = DeleteRequest{}
+	mi := &file_crud_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRequest) ProtoMessage() {}
+
+func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_crud_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRequest.ProtoReflect.Descriptor instead.
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return file_crud_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DeleteRequest) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+func (x *DeleteRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+// OperationResponse is the result of an operation on a single entity. The value is the entity for GetByID and Update,
+// the ID of the new entity for Add, and the asynchronous operation for operations in the ACCEPTED state.
+type OperationResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	State         State                  `protobuf:"varint,1,opt,name=state,proto3,enum=crud.State" json:"state,omitempty"`
+	Value         *structpb.Value        `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OperationResponse) Reset() {
+	*x = OperationResponse{}
+	mi := &file_crud_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OperationResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OperationResponse) ProtoMessage() {}
+
+func (x *OperationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_crud_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OperationResponse.ProtoReflect.Descriptor instead.
+func (*OperationResponse) Descriptor() ([]byte, []int) {
+	return file_crud_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *OperationResponse) GetState() State {
+	if x != nil {
+		return x.State
+	}
+	return State_STATE_UNKNOWN
+}
+
+func (x *OperationResponse) GetValue() *structpb.Value {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+var File_crud_proto protoreflect.FileDescriptor
+
+const file_crud_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"crud.proto\x12\x04crud\x1a\x1cgoogle/protobuf/struct.proto\"\x8f\x02\n" +
+	"\x0eDataSetRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1f\n" +
+	"\vpage_number\x18\x02 \x01(\x05R\n" +
+	"pageNumber\x12\x1f\n" +
+	"\vsort_column\x18\x03 \x01(\tR\n" +
+	"sortColumn\x12%\n" +
+	"\x0esort_direction\x18\x04 \x01(\tR\rsortDirection\x12;\n" +
+	"\afilters\x18\x05 \x03(\v2!.crud.DataSetRequest.FiltersEntryR\afilters\x1a:\n" +
+	"\fFiltersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xda\x01\n" +
+	"\n" +
+	"PagingInfo\x12'\n" +
+	"\x0fsupports_paging\x18\x01 \x01(\bR\x0esupportsPaging\x125\n" +
+	"\x17does_know_total_records\x18\x02 \x01(\bR\x14doesKnowTotalRecords\x12\x1b\n" +
+	"\tpage_size\x18\x03 \x01(\x05R\bpageSize\x12\x1f\n" +
+	"\vpage_number\x18\x04 \x01(\x05R\n" +
+	"pageNumber\x12.\n" +
+	"\x13total_records_count\x18\x05 \x01(\x05R\x11totalRecordsCount\"k\n" +
+	"\aDataSet\x12-\n" +
+	"\x05items\x18\x01 \x03(\v2\x17.google.protobuf.StructR\x05items\x121\n" +
+	"\vpaging_info\x18\x02 \x01(\v2\x10.crud.PagingInfoR\n" +
+	"pagingInfo\"[\n" +
+	"\rGetAllRequest\x12\x1a\n" +
+	"\bresource\x18\x01 \x01(\tR\bresource\x12.\n" +
+	"\arequest\x18\x02 \x01(\v2\x14.crud.DataSetRequestR\arequest\"]\n" +
+	"\x0eGetAllResponse\x12!\n" +
+	"\x05state\x18\x01 \x01(\x0e2\v.crud.StateR\x05state\x12(\n" +
+	"\bdata_set\x18\x02 \x01(\v2\r.crud.DataSetR\adataSet\"<\n" +
+	"\x0eGetByIDRequest\x12\x1a\n" +
+	"\bresource\x18\x01 \x01(\tR\bresource\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\"Y\n" +
+	"\n" +
+	"AddRequest\x12\x1a\n" +
+	"\bresource\x18\x01 \x01(\tR\bresource\x12/\n" +
+	"\x06entity\x18\x02 \x01(\v2\x17.google.protobuf.StructR\x06entity\"l\n" +
+	"\rUpdateRequest\x12\x1a\n" +
+	"\bresource\x18\x01 \x01(\tR\bresource\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12/\n" +
+	"\x06entity\x18\x03 \x01(\v2\x17.google.protobuf.StructR\x06entity\";\n" +
+	"\rDeleteRequest\x12\x1a\n" +
+	"\bresource\x18\x01 \x01(\tR\bresource\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\"d\n" +
+	"\x11OperationResponse\x12!\n" +
+	"\x05state\x18\x01 \x01(\x0e2\v.crud.StateR\x05state\x12,\n" +
+	"\x05value\x18\x02 \x01(\v2\x16.google.protobuf.ValueR\x05value*\x9b\x01\n" +
+	"\x05State\x12\x11\n" +
+	"\rSTATE_UNKNOWN\x10\x00\x12\x06\n" +
+	"\x02OK\x10\x01\x12\x15\n" +
+	"\x11VALIDATION_FAILED\x10\x02\x12\t\n" +
+	"\x05ERROR\x10\x03\x12\r\n" +
+	"\tNOT_FOUND\x10\x04\x12\f\n" +
+	"\bCONFLICT\x10\x05\x12\x1d\n" +
+	"\x19NOT_SUPPORTED_BY_RESOURCE\x10\x06\x12\v\n" +
+	"\aCREATED\x10\a\x12\f\n" +
+	"\bACCEPTED\x10\b2\x97\x02\n" +
+	"\x04Crud\x123\n" +
+	"\x06GetAll\x12\x13.crud.GetAllRequest\x1a\x14.crud.GetAllResponse\x128\n" +
+	"\aGetByID\x12\x14.crud.GetByIDRequest\x1a\x17.crud.OperationResponse\x120\n" +
+	"\x03Add\x12\x10.crud.AddRequest\x1a\x17.crud.OperationResponse\x126\n" +
+	"\x06Update\x12\x13.crud.UpdateRequest\x1a\x17.crud.OperationResponse\x126\n" +
+	"\x06Delete\x12\x13.crud.DeleteRequest\x1a\x17.crud.OperationResponseB2Z0github.com/Travix-International/crud-go/crudgrpcb\x06proto3"
+
+var (
+	file_crud_proto_rawDescOnce sync.Once
+	file_crud_proto_rawDescData []byte
+)
+
+func file_crud_proto_rawDescGZIP() []byte {
+	file_crud_proto_rawDescOnce.Do(func() {
+		file_crud_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_crud_proto_rawDesc), len(file_crud_proto_rawDesc)))
+	})
+	return file_crud_proto_rawDescData
+}
+
+var file_crud_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_crud_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_crud_proto_goTypes = []any{
+	(State)(0),                // 0: crud.State
+	(*DataSetRequest)(nil),    // 1: crud.DataSetRequest
+	(*PagingInfo)(nil),        // 2: crud.PagingInfo
+	(*DataSet)(nil),           // 3: crud.DataSet
+	(*GetAllRequest)(nil),     // 4: crud.GetAllRequest
+	(*GetAllResponse)(nil),    // 5: crud.GetAllResponse
+	(*GetByIDRequest)(nil),    // 6: crud.GetByIDRequest
+	(*AddRequest)(nil),        // 7: crud.AddRequest
+	(*UpdateRequest)(nil),     // 8: crud.UpdateRequest
+	(*DeleteRequest)(nil),     // 9: crud.DeleteRequest
+	(*OperationResponse)(nil), // 10: crud.OperationResponse
+	nil,                       // 11: crud.DataSetRequest.FiltersEntry
+	(*structpb.Struct)(nil),   // 12: google.protobuf.Struct
+	(*structpb.Value)(nil),    // 13: google.protobuf.Value
+}
+var file_crud_proto_depIdxs = []int32{
+	11, // 0: crud.DataSetRequest.filters:type_name -> crud.DataSetRequest.FiltersEntry
+	12, // 1: crud.DataSet.items:type_name -> google.protobuf.Struct
+	2,  // 2: crud.DataSet.paging_info:type_name -> crud.PagingInfo
+	1,  // 3: crud.GetAllRequest.request:type_name -> crud.DataSetRequest
+	0,  // 4: crud.GetAllResponse.state:type_name -> crud.State
+	3,  // 5: crud.GetAllResponse.data_set:type_name -> crud.DataSet
+	12, // 6: crud.AddRequest.entity:type_name -> google.protobuf.Struct
+	12, // 7: crud.UpdateRequest.entity:type_name -> google.protobuf.Struct
+	0,  // 8: crud.OperationResponse.state:type_name -> crud.State
+	13, // 9: crud.OperationResponse.value:type_name -> google.protobuf.Value
+	4,  // 10: crud.Crud.GetAll:input_type -> crud.GetAllRequest
+	6,  // 11: crud.Crud.GetByID:input_type -> crud.GetByIDRequest
+	7,  // 12: crud.Crud.Add:input_type -> crud.AddRequest
+	8,  // 13: crud.Crud.Update:input_type -> crud.UpdateRequest
+	9,  // 14: crud.Crud.Delete:input_type -> crud.DeleteRequest
+	5,  // 15: crud.Crud.GetAll:output_type -> crud.GetAllResponse
+	10, // 16: crud.Crud.GetByID:output_type -> crud.OperationResponse
+	10, // 17: crud.Crud.Add:output_type -> crud.OperationResponse
+	10, // 18: crud.Crud.Update:output_type -> crud.OperationResponse
+	10, // 19: crud.Crud.Delete:output_type -> crud.OperationResponse
+	15, // [15:20] is the sub-list for method output_type
+	10, // [10:15] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_crud_proto_init() }
+func file_crud_proto_init() {
+	if File_crud_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_crud_proto_rawDesc), len(file_crud_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_crud_proto_goTypes,
+		DependencyIndexes: file_crud_proto_depIdxs,
+		EnumInfos:         file_crud_proto_enumTypes,
+		MessageInfos:      file_crud_proto_msgTypes,
+	}.Build()
+	File_crud_proto = out.File
+	file_crud_proto_goTypes = nil
+	file_crud_proto_depIdxs = nil
+}
@@ -0,0 +1,199 @@
+package crudtest
+
+import (
+	"encoding/json"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// ConformanceEntityCount is the number of entities added to check paging.
+const ConformanceEntityCount = 5
+
+// ServiceSubject describes a Service under test
+type ServiceSubject struct {
+	// Service is the implementation under test. It must be empty.
+	Service crud.Service
+	// NewEntity creates a valid entity. Different values of n must lead to different entities.
+	NewEntity func(n int) crud.Entity
+	// InvalidEntity creates an entity that fails validation. Optional; the validation checks are skipped if nil.
+	InvalidEntity func() crud.Entity
+	// MissingID is an ID that never exists. Defaults to "does-not-exist".
+	MissingID crud.EntityKey
+}
+
+// RunServiceConformance checks the behaviour of a Service implementation against the CRUD protocol. The factory is called
+// for each check, and must return a subject with a fresh, empty Service.
+//
+// Operations for which the Service returns NotSupportedByResource are skipped, as are checks that need the ID of an added
+// entity when Add doesn't return it (see crud.CreatedWithIDResult).
+func RunServiceConformance(t *testing.T, factory func() *ServiceSubject) {
+	t.Run("GetAllOnEmptyService", func(t *testing.T) {
+		subject := factory()
+		result := supported(t, subject.Service.GetAll(firstPage(10)))
+
+		dataSet := requireDataSet(t, result)
+		assert.NotNil(t, dataSet.Items, "Items should be an empty collection, not nil")
+		assert.Equal(t, 0, len(dataSet.Items))
+		if dataSet.PagingInfo.DoesKnowTotalRecords {
+			assert.Equal(t, 0, dataSet.PagingInfo.TotalRecordsCount)
+		}
+	})
+
+	t.Run("AddReturnsCreated", func(t *testing.T) {
+		subject := factory()
+		result := supported(t, subject.Service.Add(subject.NewEntity(1)))
+
+		assert.Equal(t, crud.Created, result.State(), "Add should return Created, not Ok")
+		assert.Nil(t, result.Error())
+	})
+
+	t.Run("AddInvalidReturnsValidationFailed", func(t *testing.T) {
+		subject := factory()
+		if subject.InvalidEntity == nil {
+			t.Skip("no InvalidEntity provided")
+		}
+		result := supported(t, subject.Service.Add(subject.InvalidEntity()))
+
+		assert.Equal(t, crud.ValidationFailed, result.State())
+		assert.NotNil(t, result.Error(), "ValidationFailed should explain what's wrong")
+	})
+
+	t.Run("GetByIDReturnsAddedEntity", func(t *testing.T) {
+		subject := factory()
+		id := addEntity(t, subject, 1)
+		result := supported(t, subject.Service.GetByID(id))
+
+		assert.Equal(t, crud.Ok, result.State())
+		assert.NotNil(t, result.Value())
+	})
+
+	t.Run("MissingEntityIsNotFound", func(t *testing.T) {
+		subject := factory()
+		missing := missingID(subject)
+
+		assertNotFound(t, "GetByID", subject.Service.GetByID(missing))
+		assertNotFound(t, "Update", subject.Service.Update(missing, subject.NewEntity(1)))
+		assertNotFound(t, "Delete", subject.Service.Delete(missing))
+	})
+
+	t.Run("UpdateExistingEntity", func(t *testing.T) {
+		subject := factory()
+		id := addEntity(t, subject, 1)
+		result := supported(t, subject.Service.Update(id, subject.NewEntity(2)))
+		assert.Equal(t, crud.Ok, result.State())
+
+		result = subject.Service.GetByID(id)
+		assert.Equal(t, crud.Ok, result.State(), "An updated entity should still exist")
+	})
+
+	t.Run("DeleteExistingEntity", func(t *testing.T) {
+		subject := factory()
+		id := addEntity(t, subject, 1)
+		result := supported(t, subject.Service.Delete(id))
+		assert.Equal(t, crud.Ok, result.State())
+
+		assertNotFound(t, "GetByID after Delete", subject.Service.GetByID(id))
+		assertNotFound(t, "Delete after Delete", subject.Service.Delete(id))
+	})
+
+	t.Run("PagingInfoInvariants", func(t *testing.T) {
+		subject := factory()
+		for n := 1; n <= ConformanceEntityCount; n++ {
+			supported(t, subject.Service.Add(subject.NewEntity(n)))
+		}
+
+		first := requireDataSet(t, supported(t, subject.Service.GetAll(firstPage(2))))
+		paging := first.PagingInfo
+		assert.Equal(t, 1, paging.PageNumber)
+		assert.True(t, paging.PageSize >= 1, "PageSize should be at least 1")
+		if paging.DoesKnowTotalRecords {
+			assert.Equal(t, ConformanceEntityCount, paging.TotalRecordsCount)
+		} else {
+			assert.Equal(t, 0, paging.TotalRecordsCount, "TotalRecordsCount should be zero if it's not known")
+		}
+
+		if !paging.SupportsPaging {
+			assert.Equal(t, ConformanceEntityCount, len(first.Items), "All results should be on page 1 if paging isn't supported")
+			return
+		}
+		assert.True(t, len(first.Items) <= paging.PageSize, "A page shouldn't hold more items than its PageSize")
+
+		// Walk all pages, every entity should be seen exactly once
+		seen := make(map[string]bool)
+		for page := 1; page <= ConformanceEntityCount; page++ {
+			dataSet := requireDataSet(t, subject.Service.GetAll(&crud.DataSetRequest{PageSize: paging.PageSize, PageNumber: page, SortColumn: "id", SortDirection: string(crud.Asc)}))
+			assert.Equal(t, page, dataSet.PagingInfo.PageNumber)
+			for _, item := range dataSet.Items {
+				key := fingerprint(item)
+				assert.False(t, seen[key], "Entity returned on more than one page: %v", key)
+				seen[key] = true
+			}
+		}
+		assert.Equal(t, ConformanceEntityCount, len(seen))
+
+		pastEnd := subject.Service.GetAll(&crud.DataSetRequest{PageSize: paging.PageSize, PageNumber: 100, SortColumn: "id", SortDirection: string(crud.Asc)})
+		assert.Equal(t, crud.Ok, pastEnd.State(), "Paging past the end should be Ok, not %v", pastEnd.State())
+		assert.Equal(t, 0, len(requireDataSet(t, pastEnd).Items))
+	})
+}
+
+// supported skips the test if the Service doesn't support the operation.
+func supported(t *testing.T, result crud.OperationResult) crud.OperationResult {
+	if result == nil {
+		t.Fatal("Service returned a nil OperationResult")
+	}
+	if result.State() == crud.NotSupportedByResource {
+		t.Skip("operation not supported by resource")
+	}
+	return result
+}
+
+func addEntity(t *testing.T, subject *ServiceSubject, n int) crud.EntityKey {
+	result := supported(t, subject.Service.Add(subject.NewEntity(n)))
+	if result.State() != crud.Created && result.State() != crud.Ok {
+		t.Fatalf("Add failed with state %v: %v", result.State(), result.Error())
+	}
+	if result.Value() == nil {
+		t.Skip("Add doesn't return the ID of the new entity")
+	}
+	return result.Value()
+}
+
+func requireDataSet(t *testing.T, result crud.OperationResult) *crud.DataSet {
+	if result.State() != crud.Ok {
+		t.Fatalf("GetAll returned state %v instead of Ok: %v", result.State(), result.Error())
+	}
+	switch v := result.Value().(type) {
+	case *crud.DataSet:
+		return v
+	case crud.DataSet:
+		return &v
+	}
+	t.Fatalf("GetAll returned %T instead of a DataSet", result.Value())
+	return nil
+}
+
+func assertNotFound(t *testing.T, operation string, result crud.OperationResult) {
+	if result.State() == crud.NotSupportedByResource {
+		return
+	}
+	assert.Equal(t, crud.NotFound, result.State(), "%v of a missing entity should be NotFound, not %v", operation, result.State())
+}
+
+func missingID(subject *ServiceSubject) crud.EntityKey {
+	if subject.MissingID != nil {
+		return subject.MissingID
+	}
+	return "does-not-exist"
+}
+
+func firstPage(pageSize int) *crud.DataSetRequest {
+	return &crud.DataSetRequest{PageSize: pageSize, PageNumber: 1, SortColumn: "id", SortDirection: string(crud.Asc)}
+}
+
+func fingerprint(item interface{}) string {
+	data, _ := json.Marshal(item)
+	return string(data)
+}
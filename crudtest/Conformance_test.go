@@ -0,0 +1,70 @@
+package crudtest_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/Travix-International/crud-go/crudtest"
+	servicefoundation "github.com/Travix-International/go-servicefoundation"
+	"github.com/Travix-International/logger"
+	"github.com/gorilla/mux"
+)
+
+func newSubscription(n int) *crud.WebhookSubscription {
+	return &crud.WebhookSubscription{URL: fmt.Sprintf("http://example.com/%v", n), Resource: "things", Active: true}
+}
+
+func TestWebhookSubscriptionServiceConformance(t *testing.T) {
+	crudtest.RunServiceConformance(t, func() *crudtest.ServiceSubject {
+		return &crudtest.ServiceSubject{
+			Service:       crud.NewWebhookSubscriptionService(),
+			NewEntity:     func(n int) crud.Entity { return newSubscription(n) },
+			InvalidEntity: func() crud.Entity { return &crud.WebhookSubscription{} },
+		}
+	})
+}
+
+func TestWebhookSubscriptionHandlerConformance(t *testing.T) {
+	loggy, _ := logger.New(make(map[string]string))
+	ctx := &servicefoundation.ContextBase{}
+	ctx.SetLogger(loggy)
+
+	crudtest.RunHandlerConformance(t, func() *crudtest.HandlerSubject {
+		svc := crud.NewWebhookSubscriptionService()
+		router := mux.NewRouter()
+		router.HandleFunc("/webhooks", crud.CreateCrudHandlerGetList(ctx, svc, "webhooks", crud.Recovery)).Methods("GET")
+		router.HandleFunc("/webhooks", crud.CreateCrudHandlerCreateEntity(ctx, svc, "webhooks", crud.Recovery, crud.NewWebhookSubscription)).Methods("POST")
+		router.HandleFunc("/webhooks/{id}", crud.CreateCrudHandlerGetByID(ctx, svc, "webhooks", crud.Recovery)).Methods("GET")
+		router.HandleFunc("/webhooks/{id}", crud.CreateCrudHandlerUpdateEntity(ctx, svc, "webhooks", crud.Recovery, crud.NewWebhookSubscription)).Methods("PUT")
+		router.HandleFunc("/webhooks/{id}", crud.CreateCrudHandlerDeleteByID(ctx, svc, "webhooks", crud.Recovery)).Methods("DELETE")
+
+		return &crudtest.HandlerSubject{
+			Handler:       router,
+			ResourcePath:  "/webhooks",
+			NewEntity:     func(n int) interface{} { return newSubscription(n) },
+			InvalidEntity: func() interface{} { return map[string]string{"url": "nope"} },
+		}
+	})
+}
+
+func TestReadOnlyHandlerConformance(t *testing.T) {
+	crudtest.RunHandlerConformance(t, func() *crudtest.HandlerSubject {
+		router := mux.NewRouter()
+		router.HandleFunc("/lookups", func(w http.ResponseWriter, r *http.Request) {
+			crud.WriteOperationResult(w, r, crud.OkResult(&crud.DataSet{Items: []interface{}{}, PagingInfo: crud.PagingInfo{PageSize: 15, PageNumber: 1}}))
+		}).Methods("GET")
+		router.HandleFunc("/lookups", crud.ActionNotAvailableHandler).Methods("POST")
+		router.HandleFunc("/lookups/{id}", func(w http.ResponseWriter, r *http.Request) {
+			crud.WriteOperationResult(w, r, crud.NotFoundResult())
+		}).Methods("GET")
+		router.HandleFunc("/lookups/{id}", crud.ActionNotAvailableHandler).Methods("PUT", "DELETE")
+
+		return &crudtest.HandlerSubject{
+			Handler:      router,
+			ResourcePath: "/lookups",
+			NewEntity:    func(n int) interface{} { return map[string]int{"n": n} },
+		}
+	})
+}
@@ -0,0 +1,195 @@
+package crudtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	crud "github.com/Travix-International/crud-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// HandlerSubject describes a resource served by the CRUD handlers
+type HandlerSubject struct {
+	// Handler serves the resource, typically the router the CRUD handlers were registered on. The resource must be empty.
+	Handler http.Handler
+	// ResourcePath is the path of the resource, e.g. /api/bookings. Entities are expected at ResourcePath/{id}.
+	ResourcePath string
+	// NewEntity creates the body of a valid entity. Different values of n must lead to different entities.
+	NewEntity func(n int) interface{}
+	// InvalidEntity creates the body of an entity that fails validation. Optional; the validation checks are skipped if nil.
+	InvalidEntity func() interface{}
+	// UpdateMethod is the HTTP method routed to the update handler. Defaults to PUT.
+	UpdateMethod string
+}
+
+// RunHandlerConformance checks the HTTP behaviour of registered CRUD handlers against the CRUD protocol. The factory is
+// called for each check, and must return a subject serving a fresh, empty resource.
+//
+// Operations answered with 405 Method Not Allowed are skipped, as are checks that need the ID of a created entity when the
+// create handler doesn't return it.
+func RunHandlerConformance(t *testing.T, factory func() *HandlerSubject) {
+	t.Run("GetListOnEmptyResource", func(t *testing.T) {
+		subject := factory()
+		w := serve(t, subject, "GET", subject.ResourcePath, nil)
+		skipIfNotAllowed(t, w)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var dataSet struct {
+			Items      []json.RawMessage `json:"items"`
+			PagingInfo *crud.PagingInfo  `json:"pagingInfo"`
+		}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &dataSet), "The list should be a DataSet")
+		assert.NotNil(t, dataSet.Items, "items should be an empty array, not null")
+		assert.NotNil(t, dataSet.PagingInfo, "pagingInfo is required")
+	})
+
+	t.Run("CreateReturns201", func(t *testing.T) {
+		subject := factory()
+		w := serve(t, subject, "POST", subject.ResourcePath, subject.NewEntity(1))
+		skipIfNotAllowed(t, w)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("CreateMalformedBodyReturns400", func(t *testing.T) {
+		subject := factory()
+		w := serve(t, subject, "POST", subject.ResourcePath, json.RawMessage("{not json"))
+		skipIfNotAllowed(t, w)
+
+		assertErrorDetails(t, w, http.StatusBadRequest)
+	})
+
+	t.Run("CreateInvalidReturns400", func(t *testing.T) {
+		subject := factory()
+		if subject.InvalidEntity == nil {
+			t.Skip("no InvalidEntity provided")
+		}
+		w := serve(t, subject, "POST", subject.ResourcePath, subject.InvalidEntity())
+		skipIfNotAllowed(t, w)
+
+		assertErrorDetails(t, w, http.StatusBadRequest)
+	})
+
+	t.Run("MissingEntityReturns404", func(t *testing.T) {
+		subject := factory()
+		path := entityPath(subject, "does-not-exist")
+
+		for _, method := range []string{"GET", updateMethod(subject), "DELETE"} {
+			var body interface{}
+			if method != "GET" && method != "DELETE" {
+				body = subject.NewEntity(1)
+			}
+			w := serve(t, subject, method, path, body)
+			if w.Code == http.StatusMethodNotAllowed {
+				continue
+			}
+			assert.Equal(t, http.StatusNotFound, w.Code, "%v of a missing entity", method)
+			assert.Equal(t, 0, len(bytes.TrimSpace(w.Body.Bytes())), "404 should have an empty body")
+		}
+	})
+
+	t.Run("CreatedEntityLifecycle", func(t *testing.T) {
+		subject := factory()
+		path := entityPath(subject, createEntity(t, subject, 1))
+
+		w := serve(t, subject, "GET", path, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = serve(t, subject, updateMethod(subject), path, subject.NewEntity(2))
+		if w.Code != http.StatusMethodNotAllowed {
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+
+		w = serve(t, subject, "DELETE", path, nil)
+		skipIfNotAllowed(t, w)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = serve(t, subject, "GET", path, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("PagingPastTheEnd", func(t *testing.T) {
+		subject := factory()
+		for n := 1; n <= ConformanceEntityCount; n++ {
+			skipIfNotAllowed(t, serve(t, subject, "POST", subject.ResourcePath, subject.NewEntity(n)))
+		}
+
+		w := serve(t, subject, "GET", subject.ResourcePath+"?pageSize=2&pageNumber=100", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var dataSet crud.DataSet
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &dataSet))
+		assert.Equal(t, 0, len(dataSet.Items))
+	})
+}
+
+func serve(t *testing.T, subject *HandlerSubject, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	switch v := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case json.RawMessage:
+		reader = bytes.NewReader(v)
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	r, err := http.NewRequest(method, path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	subject.Handler.ServeHTTP(w, r)
+	return w
+}
+
+func createEntity(t *testing.T, subject *HandlerSubject, n int) string {
+	w := serve(t, subject, "POST", subject.ResourcePath, subject.NewEntity(n))
+	skipIfNotAllowed(t, w)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Create returned %v instead of 201: %v", w.Code, w.Body.String())
+	}
+
+	decoder := json.NewDecoder(w.Body)
+	decoder.UseNumber()
+	var id interface{}
+	if err := decoder.Decode(&id); err != nil || id == nil {
+		t.Skip("create handler doesn't return the ID of the new entity")
+	}
+	return fmt.Sprint(id)
+}
+
+func assertErrorDetails(t *testing.T, w *httptest.ResponseRecorder, statusCode int) {
+	assert.Equal(t, statusCode, w.Code)
+	var details crud.ErrorDetails
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &details), "The body should hold the ErrorDetails")
+	assert.NotEqual(t, "", details.Message, "The ErrorDetails should have a message")
+}
+
+func skipIfNotAllowed(t *testing.T, w *httptest.ResponseRecorder) {
+	if w.Code == http.StatusMethodNotAllowed {
+		t.Skip("operation not supported by resource")
+	}
+}
+
+func entityPath(subject *HandlerSubject, id string) string {
+	return strings.TrimRight(subject.ResourcePath, "/") + "/" + url.PathEscape(id)
+}
+
+func updateMethod(subject *HandlerSubject) string {
+	if subject.UpdateMethod != "" {
+		return subject.UpdateMethod
+	}
+	return "PUT"
+}
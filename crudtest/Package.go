@@ -0,0 +1,3 @@
+// Package crudtest provides conformance suites that check whether Service implementations, and the handlers
+// registered for them, behave according to the CRUD protocol.
+package crudtest